@@ -0,0 +1,81 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"sika/api/http/handlers"
+	"sika/config"
+	"sika/pkg/auth"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// routeGroups are the route groups whose authentication is configured by
+// config.Auth.Groups.
+var routeGroups = []string{config.RouteGroupUsers, config.RouteGroupDocs}
+
+// authMiddleware returns the Authenticate middleware of each route group.
+// Methods are tried in the order of the group, and a group not configured
+// accepts every configured method, the docs only AuthMethodNone. The users
+// routes are only open when their group is AuthMethodNone, without any
+// method configured they are an error.
+func authMiddleware(cfg config.Auth) (map[string]fiber.Handler, error) {
+	authenticators := map[string]auth.Authenticator{}
+	var configured []string
+	if len(cfg.APIKeys) > 0 {
+		keys, err := auth.NewAPIKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators[config.AuthMethodAPIKey] = keys
+		configured = append(configured, config.AuthMethodAPIKey)
+	}
+	if cfg.JWT.Enabled() {
+		verifier, err := auth.NewJWTVerifier(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		authenticators[config.AuthMethodJWT] = verifier
+		configured = append(configured, config.AuthMethodJWT)
+	}
+
+	for group := range cfg.Groups {
+		if !slices.Contains(routeGroups, group) {
+			return nil, fmt.Errorf("unknown route group %q, expected one of %v", group, routeGroups)
+		}
+	}
+	middleware := map[string]fiber.Handler{}
+	for _, group := range routeGroups {
+		methods, ok := cfg.Groups[group]
+		if !ok {
+			methods = configured
+			if group == config.RouteGroupDocs {
+				methods = []string{config.AuthMethodNone}
+			}
+		}
+		if len(methods) == 0 {
+			return nil, fmt.Errorf("route group %s: no auth method configured, set api_keys or jwt, or the group to [%s] to accept unauthenticated requests", group, config.AuthMethodNone)
+		}
+
+		var groupAuthenticators []auth.Authenticator
+		for _, method := range methods {
+			if method == config.AuthMethodNone {
+				if len(methods) > 1 {
+					return nil, fmt.Errorf("route group %s: %s cannot be combined with other methods", group, config.AuthMethodNone)
+				}
+				continue
+			}
+			a, ok := authenticators[method]
+			if !ok {
+				return nil, fmt.Errorf("route group %s: auth method %q is not configured", group, method)
+			}
+			groupAuthenticators = append(groupAuthenticators, a)
+		}
+		if len(groupAuthenticators) == 0 && group != config.RouteGroupDocs {
+			log.Printf("warning: the %s routes accept unauthenticated requests", group)
+		}
+		middleware[group] = handlers.Authenticate(groupAuthenticators...)
+	}
+	return middleware, nil
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"fmt"
+	"sika/internal/address"
+	"sika/pkg/storage/entities"
+	"sika/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// addressSetRequest is an element of the body of PUT /users/:UserID/addresses.
+// Elements with an address_id update that address of the user, the others
+// create one.
+type addressSetRequest struct {
+	ID int `json:"address_id"`
+	addressRequest
+}
+
+// GetUserAddresses serves GET /users/:UserID/addresses.
+func GetUserAddresses(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		addrs, err := userService.GetUserAddresses(c.UserContext(), c.Params("UserID"))
+		if err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(addrs)
+	}
+}
+
+// ReplaceAddresses serves PUT /users/:UserID/addresses, replacing the whole
+// address set of the user at once. If-Match is required, see ifMatchVersion.
+// Address writes change the version of the user, so the entity tag is that of
+// the user.
+func ReplaceAddresses(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		var req []addressSetRequest
+		if err := decodeBody(c, &req); err != nil {
+			return err
+		}
+
+		addrs := make([]entities.Address, len(req))
+		for i, r := range req {
+			addrs[i] = r.entity()
+			addrs[i].ID = r.ID
+		}
+		if err := userService.ReplaceAddresses(c.UserContext(), c.Params("UserID"), version, addrs); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(addrs)
+	}
+}
+
+// CreateAddress serves POST /users/:UserID/addresses. If-Match is required.
+func CreateAddress(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		var req addressRequest
+		if err := decodeBody(c, &req); err != nil {
+			return err
+		}
+
+		a := req.entity()
+		a.UserID = c.Params("UserID")
+		if err := userService.CreateAddress(c.UserContext(), &a, version); err != nil {
+			return err
+		}
+
+		c.Location(fmt.Sprintf("/users/%s/addresses/%d", a.UserID, a.ID))
+		return c.Status(fiber.StatusCreated).JSON(a)
+	}
+}
+
+// GetUserAddress serves GET /users/:UserID/addresses/:AddressID. An address
+// of another user is not found.
+func GetUserAddress(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := addressID(c)
+		if !ok {
+			return address.ErrNotFound
+		}
+		a, err := userService.GetUserAddress(c.UserContext(), c.Params("UserID"), id)
+		if err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(a)
+	}
+}
+
+// ReplaceAddress serves PUT /users/:UserID/addresses/:AddressID. If-Match is
+// required.
+func ReplaceAddress(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := addressID(c)
+		if !ok {
+			return address.ErrNotFound
+		}
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		var req addressRequest
+		if err := decodeBody(c, &req); err != nil {
+			return err
+		}
+
+		a := req.entity()
+		a.ID = id
+		a.UserID = c.Params("UserID")
+		if err := userService.ReplaceAddress(c.UserContext(), &a, version); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(a)
+	}
+}
+
+// PatchAddress serves PATCH /users/:UserID/addresses/:AddressID with a JSON
+// Merge Patch of the address fields. If-Match is required.
+func PatchAddress(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := addressID(c)
+		if !ok {
+			return address.ErrNotFound
+		}
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		if err := checkMergePatch(c); err != nil {
+			return err
+		}
+
+		a, err := userService.PatchAddress(c.UserContext(), c.Params("UserID"), id, version, c.Body())
+		if err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(a)
+	}
+}
+
+// DeleteAddress serves DELETE /users/:UserID/addresses/:AddressID. If-Match
+// is required.
+func DeleteAddress(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := addressID(c)
+		if !ok {
+			return address.ErrNotFound
+		}
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		if err := userService.DeleteAddress(c.UserContext(), c.Params("UserID"), id, version); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// addressID returns the AddressID parameter, not ok when it cannot name an
+// address.
+func addressID(c *fiber.Ctx) (int, bool) {
+	id, err := c.ParamsInt("AddressID")
+	return id, err == nil && id > 0
+}
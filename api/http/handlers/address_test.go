@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/service"
+	"sika/test/mocks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAddressTestApp(userService *service.UserService) *fiber.App {
+	app := newTestApp()
+	app.Get("/users/:UserID/addresses", GetUserAddresses(userService))
+	app.Post("/users/:UserID/addresses", CreateAddress(userService))
+	app.Put("/users/:UserID/addresses", ReplaceAddresses(userService))
+	app.Get("/users/:UserID/addresses/:AddressID", GetUserAddress(userService))
+	app.Put("/users/:UserID/addresses/:AddressID", ReplaceAddress(userService))
+	app.Patch("/users/:UserID/addresses/:AddressID", PatchAddress(userService))
+	app.Delete("/users/:UserID/addresses/:AddressID", DeleteAddress(userService))
+	return app
+}
+
+func TestAddressHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testUser := &entities.User{ID: "123", Version: 3, Addresses: []entities.Address{{ID: 7, UserID: "123", Street: "1 Test St"}}}
+	// the version of testUser after an address write
+	written := &entities.User{ID: "123", Version: 4}
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		contentType      string
+		ifMatch          string
+		setupMocks       func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo)
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     map[string]interface{}
+	}{
+		{
+			name:   "list",
+			method: "GET",
+			path:   "/users/123/addresses",
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:   "list of unknown user",
+			method: "GET",
+			path:   "/users/999/addresses",
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "999").Return(nil, user.ErrNotFound)
+			},
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "user not found"),
+		},
+		{
+			name:   "get",
+			method: "GET",
+			path:   "/users/123/addresses/7",
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().GetAddress(gomock.Any(), "123", 7).Return(&testUser.Addresses[0], nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:   "get address of another user",
+			method: "GET",
+			path:   "/users/123/addresses/8",
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().GetAddress(gomock.Any(), "123", 8).Return(nil, address.ErrNotFound)
+			},
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "address not found"),
+		},
+		{
+			name:           "invalid address ID",
+			method:         "GET",
+			path:           "/users/123/addresses/abc",
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:    "create",
+			method:  "POST",
+			path:    "/users/123/addresses",
+			body:    `{"street":" 2 Test St ","country":"AR"}`,
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().CreateAddress(gomock.Any(), &entities.Address{UserID: "123", Street: "2 Test St", Country: "AR"}).
+					DoAndReturn(func(_ interface{}, a *entities.Address) error {
+						a.ID = 8
+						return nil
+					})
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(written, nil)
+			},
+			expectedStatus:   fiber.StatusCreated,
+			expectedLocation: "/users/123/addresses/8",
+		},
+		{
+			name:           "create empty address",
+			method:         "POST",
+			path:           "/users/123/addresses",
+			body:           `{"street":" "}`,
+			ifMatch:        `"3"`,
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+		},
+		{
+			name:    "replace",
+			method:  "PUT",
+			path:    "/users/123/addresses/7",
+			body:    `{"street":"3 Test St"}`,
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().UpdateAddress(gomock.Any(), &entities.Address{ID: 7, UserID: "123", Street: "3 Test St"}).Return(nil)
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(written, nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:    "replace address of another user",
+			method:  "PUT",
+			path:    "/users/123/addresses/8",
+			body:    `{"street":"3 Test St"}`,
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().UpdateAddress(gomock.Any(), gomock.Any()).Return(address.ErrNotFound)
+			},
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "address not found"),
+		},
+		{
+			name:        "patch",
+			method:      "PATCH",
+			path:        "/users/123/addresses/7",
+			body:        `{"city":"Glendale","street":null}`,
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().GetAddress(gomock.Any(), "123", 7).Return(&testUser.Addresses[0], nil)
+				addressRepo.EXPECT().UpdateAddress(gomock.Any(), &entities.Address{ID: 7, UserID: "123", City: "Glendale"}).Return(nil)
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(written, nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "patch user ID",
+			method:         "PATCH",
+			path:           "/users/123/addresses/7",
+			body:           `{"user_id":"456"}`,
+			contentType:    "application/merge-patch+json",
+			ifMatch:        `"3"`,
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+		},
+		{
+			name:    "delete",
+			method:  "DELETE",
+			path:    "/users/123/addresses/7",
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().DeleteAddress(gomock.Any(), "123", 7).Return(nil)
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(written, nil)
+			},
+			expectedStatus: fiber.StatusNoContent,
+		},
+		{
+			name:    "delete address of another user",
+			method:  "DELETE",
+			path:    "/users/123/addresses/8",
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().DeleteAddress(gomock.Any(), "123", 8).Return(address.ErrNotFound)
+			},
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:    "replace set",
+			method:  "PUT",
+			path:    "/users/123/addresses",
+			body:    `[{"address_id":7,"street":"1 Test St"},{"city":"Glendale"}]`,
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().ReplaceAddresses(gomock.Any(), "123", []entities.Address{
+					{ID: 7, Street: "1 Test St"},
+					{City: "Glendale"},
+				}).Return(nil)
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(written, nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:    "replace set with address of another user",
+			method:  "PUT",
+			path:    "/users/123/addresses",
+			body:    `[{"address_id":8,"street":"1 Test St"}]`,
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+			},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "addresses[0].address_id", "message": "is not an address of this user"},
+			),
+		},
+		{
+			name:           "replace set with repeated ID",
+			method:         "PUT",
+			path:           "/users/123/addresses",
+			body:           `[{"address_id":7,"street":"a"},{"address_id":7,"street":"b"}]`,
+			ifMatch:        `"3"`,
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+		},
+		{
+			name:           "create without If-Match",
+			method:         "POST",
+			path:           "/users/123/addresses",
+			body:           `{"street":"2 Test St"}`,
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusPreconditionRequired,
+		},
+		{
+			name:    "create at a stale version",
+			method:  "POST",
+			path:    "/users/123/addresses",
+			body:    `{"street":"2 Test St"}`,
+			ifMatch: `"2"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+			},
+			expectedStatus: fiber.StatusPreconditionFailed,
+		},
+		{
+			name:    "replace while the user changes",
+			method:  "PUT",
+			path:    "/users/123/addresses/7",
+			body:    `{"street":"3 Test St"}`,
+			ifMatch: `"3"`,
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().UpdateAddress(gomock.Any(), gomock.Any()).Return(nil)
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(&entities.User{ID: "123", Version: 5}, nil)
+			},
+			expectedStatus: fiber.StatusPreconditionFailed,
+		},
+		{
+			name:           "patch without If-Match",
+			method:         "PATCH",
+			path:           "/users/123/addresses/7",
+			body:           `{"city":"Glendale"}`,
+			contentType:    "application/merge-patch+json",
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusPreconditionRequired,
+		},
+		{
+			name:    "delete with any version",
+			method:  "DELETE",
+			path:    "/users/123/addresses/7",
+			ifMatch: "*",
+			setupMocks: func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {
+				userRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(testUser, nil)
+				addressRepo.EXPECT().DeleteAddress(gomock.Any(), "123", 7).Return(nil)
+				userRepo.EXPECT().GetUserByIDWithDeleted(gomock.Any(), "123").Return(written, nil)
+			},
+			expectedStatus: fiber.StatusNoContent,
+		},
+		{
+			name:           "replace set without If-Match",
+			method:         "PUT",
+			path:           "/users/123/addresses",
+			body:           `[]`,
+			setupMocks:     func(userRepo *mocks.MockUserRepo, addressRepo *mocks.MockAddressRepo) {},
+			expectedStatus: fiber.StatusPreconditionRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+			tt.setupMocks(mockUserRepo, mockAddressRepo)
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			app := newAddressTestApp(userService)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = fiber.MIMEApplicationJSON
+			}
+			req.Header.Set("Content-Type", contentType)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			// Execute
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedLocation != "" {
+				assert.Equal(t, tt.expectedLocation, resp.Header.Get("Location"))
+			}
+			if tt.expectedBody != nil {
+				var body map[string]interface{}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, tt.expectedBody, body)
+			}
+		})
+	}
+}
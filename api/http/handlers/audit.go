@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"sika/internal/audit"
+	"sika/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// AnonymousActor is recorded for requests without an authenticated caller.
+const AnonymousActor = "anonymous"
+
+// AuditContext puts the request ID of the request and AnonymousActor into
+// its user context, where UserService picks them up for the audit log. The
+// actor is never taken from the request, only Authenticate replaces it with
+// the verified principal. It must run after the requestid middleware.
+func AuditContext() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := audit.WithActor(c.UserContext(), AnonymousActor)
+		if requestID, ok := c.Locals(requestid.ConfigDefault.ContextKey).(string); ok {
+			ctx = audit.WithRequestID(ctx, requestID)
+		}
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+func GetUserAudit(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Params("UserID")
+		if userID == "" {
+			return badRequest("userID is required")
+		}
+
+		entries, err := userService.GetUserAudit(c.UserContext(), userID)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(fiber.StatusOK).JSON(entries)
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/service"
+	"sika/test/mocks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name          string
+		actor         string
+		expectedActor string
+	}{
+		{
+			name:          "anonymous without a principal",
+			expectedActor: AnonymousActor,
+		},
+		{
+			name:          "actor header is ignored",
+			actor:         "alice",
+			expectedActor: AnonymousActor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a new Fiber app
+			app := newTestApp()
+
+			// Create mock repositories
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+			mockAuditRepo := mocks.NewMockAuditRepo(ctrl)
+
+			var recorded *entities.AuditEntry
+			mockUserRepo.EXPECT().GetUserProjection(gomock.Any(), "123", gomock.Any(), false).Return(&entities.User{ID: "123"}, nil)
+			mockAuditRepo.EXPECT().AppendEntry(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, entry *entities.AuditEntry) error {
+					recorded = entry
+					return nil
+				})
+
+			// Create service with mocks
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			userService.SetAuditOps(audit.NewOps(mockAuditRepo))
+
+			// Setup route
+			app.Use(requestid.New())
+			app.Use(AuditContext())
+			app.Get("/users/:UserID", GetUserByID(userService))
+
+			// Create request
+			req := httptest.NewRequest("GET", "/users/123", nil)
+			if tt.actor != "" {
+				req.Header.Set("X-Actor", tt.actor)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert the entry carries the actor and the request ID
+			assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+			require.NotNil(t, recorded)
+			assert.Equal(t, tt.expectedActor, recorded.Actor)
+			assert.Equal(t, audit.ActionView, recorded.Action)
+			assert.Equal(t, "123", recorded.UserID)
+			assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), recorded.RequestID)
+			assert.NotEmpty(t, recorded.RequestID)
+		})
+	}
+}
+
+func TestGetUserAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		mockEntries    []entities.AuditEntry
+		mockError      error
+		expectedStatus int
+		expectedLen    int
+	}{
+		{
+			name: "entries of the user",
+			mockEntries: []entities.AuditEntry{
+				{ID: 1, Actor: "alice", Action: audit.ActionCreate, UserID: "123", ChangedFields: []string{"name"}},
+				{ID: 2, Actor: "bob", Action: audit.ActionView, UserID: "123"},
+			},
+			expectedStatus: fiber.StatusOK,
+			expectedLen:    2,
+		},
+		{
+			name:           "no entries",
+			expectedStatus: fiber.StatusOK,
+			expectedLen:    0,
+		},
+		{
+			name:           "repository error",
+			mockError:      assert.AnError,
+			expectedStatus: fiber.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a new Fiber app
+			app := newTestApp()
+
+			// Create mock repositories
+			mockAuditRepo := mocks.NewMockAuditRepo(ctrl)
+			mockAuditRepo.EXPECT().GetEntriesByUser(gomock.Any(), "123").Return(tt.mockEntries, tt.mockError)
+
+			// Create service with mocks
+			userService := service.NewUserService(user.NewOps(mocks.NewMockUserRepo(ctrl)), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+			userService.SetAuditOps(audit.NewOps(mockAuditRepo))
+
+			// Setup route
+			app.Get("/users/:UserID/audit", GetUserAudit(userService))
+
+			// Create request
+			req := httptest.NewRequest("GET", "/users/123/audit", nil)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert status code
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			// Assert response body
+			var entries []entities.AuditEntry
+			err = json.NewDecoder(resp.Body).Decode(&entries)
+			require.NoError(t, err)
+			assert.NotNil(t, entries)
+			assert.Len(t, entries, tt.expectedLen)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"sika/internal/audit"
+	"sika/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authenticate admits the requests authenticated by one of authenticators.
+// The principal goes into the user context, where handlers and UserService
+// read it with auth.PrincipalFrom, and replaces AnonymousActor as the actor
+// of the audit log. The first authenticator finding credentials of its method
+// decides. Without authenticators every request is admitted. It must run
+// after AuditContext.
+func Authenticate(authenticators ...auth.Authenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if len(authenticators) == 0 {
+			return c.Next()
+		}
+		header := func(name string) string { return c.Get(name) }
+		for _, a := range authenticators {
+			principal, err := a.Authenticate(c.UserContext(), header)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				log.Printf("%s %s: authentication failed: %v", c.Method(), c.Path(), err)
+				return unauthorized(c, authenticators, "invalid credentials")
+			}
+			ctx := auth.WithPrincipal(c.UserContext(), principal)
+			c.SetUserContext(audit.WithActor(ctx, principal.Subject))
+			return c.Next()
+		}
+		return unauthorized(c, authenticators, "authentication required")
+	}
+}
+
+// unauthorized returns the 401 problem with a challenge for each of
+// authenticators.
+func unauthorized(c *fiber.Ctx, authenticators []auth.Authenticator, detail string) error {
+	for _, a := range authenticators {
+		c.Append(fiber.HeaderWWWAuthenticate, a.Challenge())
+	}
+	return newProblem(fiber.StatusUnauthorized, detail)
+}
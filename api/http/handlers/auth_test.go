@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"sika/internal/audit"
+	"sika/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headerAuthenticator accepts the token "valid" in its header.
+type headerAuthenticator struct {
+	header string
+}
+
+func (a headerAuthenticator) Authenticate(ctx context.Context, header func(string) string) (auth.Principal, error) {
+	switch header(a.header) {
+	case "":
+		return auth.Principal{}, auth.ErrNoCredentials
+	case "valid":
+		return auth.Principal{Method: a.header, Subject: "alice"}, nil
+	}
+	return auth.Principal{}, fmt.Errorf("%w: not valid", auth.ErrInvalidCredentials)
+}
+
+func (a headerAuthenticator) Challenge() string {
+	return a.header
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name              string
+		authenticators    []auth.Authenticator
+		headers           map[string]string
+		expectedStatus    int
+		expectedBody      map[string]interface{}
+		expectedChallenge string
+		expectedActor     string
+	}{
+		{
+			name:           "no authenticators",
+			headers:        map[string]string{"X-Actor": "bob"},
+			expectedStatus: fiber.StatusOK,
+			expectedActor:  AnonymousActor,
+		},
+		{
+			name:           "first method",
+			authenticators: []auth.Authenticator{headerAuthenticator{"X-One"}, headerAuthenticator{"X-Two"}},
+			headers:        map[string]string{"X-One": "valid", "X-Actor": "bob"},
+			expectedStatus: fiber.StatusOK,
+			expectedActor:  "alice",
+		},
+		{
+			name:           "second method",
+			authenticators: []auth.Authenticator{headerAuthenticator{"X-One"}, headerAuthenticator{"X-Two"}},
+			headers:        map[string]string{"X-Two": "valid"},
+			expectedStatus: fiber.StatusOK,
+			expectedActor:  "alice",
+		},
+		{
+			name:              "no credentials",
+			authenticators:    []auth.Authenticator{headerAuthenticator{"X-One"}, headerAuthenticator{"X-Two"}},
+			headers:           map[string]string{"X-Actor": "bob"},
+			expectedStatus:    fiber.StatusUnauthorized,
+			expectedBody:      problemBody(fiber.StatusUnauthorized, "authentication required"),
+			expectedChallenge: "X-One, X-Two",
+		},
+		{
+			name:              "invalid credentials are not retried with another method",
+			authenticators:    []auth.Authenticator{headerAuthenticator{"X-One"}, headerAuthenticator{"X-Two"}},
+			headers:           map[string]string{"X-One": "forged", "X-Two": "valid"},
+			expectedStatus:    fiber.StatusUnauthorized,
+			expectedBody:      problemBody(fiber.StatusUnauthorized, "invalid credentials"),
+			expectedChallenge: "X-One, X-Two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			app.Use(AuditContext())
+			var principal auth.Principal
+			var actor string
+			app.Get("/", Authenticate(tt.authenticators...), func(c *fiber.Ctx) error {
+				principal, _ = auth.PrincipalFrom(c.UserContext())
+				actor = audit.Actor(c.UserContext())
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			// Execute
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedBody != nil {
+				var body map[string]interface{}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, tt.expectedBody, body)
+				assert.Equal(t, tt.expectedChallenge, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+				return
+			}
+			assert.Equal(t, tt.expectedActor, actor)
+			if len(tt.authenticators) > 0 {
+				assert.Equal(t, "alice", principal.Subject)
+			} else {
+				assert.Zero(t, principal)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"sika/pkg/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReadYourWritesHeader makes the reads of a request go to the primary when
+// set to true, so a client sees its own writes despite replication lag.
+const ReadYourWritesHeader = "X-Read-Your-Writes"
+
+// ReadYourWrites routes the reads of requests carrying ReadYourWritesHeader
+// to the primary database.
+func ReadYourWrites() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get(ReadYourWritesHeader) == "true" {
+			c.SetUserContext(storage.WithPrimary(c.UserContext()))
+		}
+		return c.Next()
+	}
+}
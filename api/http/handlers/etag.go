@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userETag returns the strong entity tag of u as rendered for p. The version
+// of a user changes on every write of it or of its addresses, so it tags the
+// full representation. Other projections render differently and add a hash
+// of the projection, so their tags never match a write precondition.
+func userETag(u *entities.User, p user.Projection) string {
+	if len(p.Fields) == 0 && p.IncludeAddresses {
+		return fmt.Sprintf(`"%d"`, u.Version)
+	}
+	fields := slices.Clone(p.Fields)
+	slices.Sort(fields)
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s;%t", strings.Join(fields, ","), p.IncludeAddresses)
+	return fmt.Sprintf(`"%d-%08x"`, u.Version, h.Sum32())
+}
+
+// etagMatches reports whether the If-None-Match header matches etag with
+// the weak comparison of RFC 9110, which ignores the W/ prefix.
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the version a write of a user is conditioned on by
+// If-Match, 0 for "*". The header is required so that a client cannot
+// overwrite changes it has not seen. Only a tag of the full representation
+// names a version, any other fails the precondition. Of a list of tags the
+// first naming a version is used.
+func ifMatchVersion(c *fiber.Ctx) (int64, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	switch header {
+	case "":
+		return 0, newProblem(fiber.StatusPreconditionRequired, "If-Match is required")
+	case "*":
+		return 0, nil
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 3 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue // weak tags never match strongly
+		}
+		if version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+	return 0, newProblem(fiber.StatusPreconditionFailed, user.ErrVersionMismatch.Error())
+}
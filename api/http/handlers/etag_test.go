@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/service"
+	"sika/test/mocks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserETag(t *testing.T) {
+	u := &entities.User{ID: "123", Version: 7}
+
+	full := userETag(u, user.Projection{IncludeAddresses: true})
+	assert.Equal(t, `"7"`, full)
+
+	sparse := userETag(u, user.Projection{Fields: []string{"name", "email"}})
+	assert.Regexp(t, `^"7-[0-9a-f]{8}"$`, sparse)
+	assert.Equal(t, sparse, userETag(u, user.Projection{Fields: []string{"email", "name"}}), "field order does not matter")
+	assert.NotEqual(t, sparse, userETag(u, user.Projection{Fields: []string{"name", "email"}, IncludeAddresses: true}))
+	assert.NotEqual(t, full, userETag(u, user.Projection{}))
+}
+
+func TestGetUserByID_Conditional(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		query          string
+		ifNoneMatch    string
+		expectedStatus int
+		expectedETag   string
+	}{
+		{name: "no precondition", expectedStatus: fiber.StatusOK, expectedETag: `"7"`},
+		{name: "matching ETag", ifNoneMatch: `"7"`, expectedStatus: fiber.StatusNotModified, expectedETag: `"7"`},
+		{name: "weak comparison", ifNoneMatch: `"6", W/"7"`, expectedStatus: fiber.StatusNotModified, expectedETag: `"7"`},
+		{name: "any", ifNoneMatch: "*", expectedStatus: fiber.StatusNotModified, expectedETag: `"7"`},
+		{name: "stale ETag", ifNoneMatch: `"6"`, expectedStatus: fiber.StatusOK, expectedETag: `"7"`},
+		{name: "ETag of another projection", query: "fields=name", ifNoneMatch: `"7"`, expectedStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockUserRepo.EXPECT().GetUserProjection(gomock.Any(), "123", gomock.Any(), false).
+				Return(&entities.User{ID: "123", Name: "Test User", Version: 7}, nil)
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+			app.Get("/users/:UserID", GetUserByID(userService))
+
+			// Execute
+			req := httptest.NewRequest("GET", "/users/123?"+tt.query, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			etag := resp.Header.Get("ETag")
+			if tt.expectedETag != "" {
+				assert.Equal(t, tt.expectedETag, etag)
+			} else {
+				assert.NotEmpty(t, etag)
+				assert.NotEqual(t, tt.ifNoneMatch, etag)
+			}
+			if resp.StatusCode == fiber.StatusNotModified {
+				assert.Zero(t, resp.ContentLength)
+			}
+		})
+	}
+}
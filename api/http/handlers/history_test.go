@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sika/internal/address"
+	"sika/internal/history"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/service"
+	"sika/test/mocks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestGetUserByIDAsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		asOf           string
+		expectedAt     time.Time
+		mockVersion    *entities.UserVersion
+		mockError      error
+		expectedStatus int
+		expectedEmail  string
+	}{
+		{
+			name:           "timestamp",
+			asOf:           "2026-01-02T03:04:05Z",
+			expectedAt:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			mockVersion:    &entities.UserVersion{UserID: "123", Email: "old@example.com"},
+			expectedStatus: fiber.StatusOK,
+			expectedEmail:  "old@example.com",
+		},
+		{
+			name:           "date means end of day",
+			asOf:           "2026-01-02",
+			expectedAt:     time.Date(2026, 1, 2, 23, 59, 59, 999999999, time.UTC),
+			mockVersion:    &entities.UserVersion{UserID: "123", Email: "old@example.com"},
+			expectedStatus: fiber.StatusOK,
+			expectedEmail:  "old@example.com",
+		},
+		{
+			name:           "no version at that time",
+			asOf:           "2020-01-01",
+			expectedAt:     time.Date(2020, 1, 1, 23, 59, 59, 999999999, time.UTC),
+			mockError:      gorm.ErrRecordNotFound,
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:           "invalid timestamp",
+			asOf:           "yesterday",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a new Fiber app
+			app := newTestApp()
+
+			// Create mock repositories
+			mockHistoryRepo := mocks.NewMockHistoryRepo(ctrl)
+			if !tt.expectedAt.IsZero() {
+				mockHistoryRepo.EXPECT().
+					GetVersionAt(gomock.Any(), "123", tt.expectedAt).
+					Return(tt.mockVersion, tt.mockError)
+			}
+
+			// Create service with mocks
+			userService := service.NewUserService(user.NewOps(mocks.NewMockUserRepo(ctrl)), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+			userService.SetHistoryOps(history.NewOps(mockHistoryRepo))
+
+			// Setup route
+			app.Get("/users/:UserID", GetUserByID(userService))
+
+			// Create request
+			req := httptest.NewRequest("GET", "/users/123?as_of="+tt.asOf, nil)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert status code
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			// Assert response body
+			var got entities.User
+			err = json.NewDecoder(resp.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedEmail, got.Email)
+		})
+	}
+}
+
+func TestGetUserHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validTo := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		mockVersions   []entities.UserVersion
+		expectedStatus int
+	}{
+		{
+			name: "versions of the user",
+			mockVersions: []entities.UserVersion{
+				{UserID: "123", Version: 1, Email: "old@example.com", ValidTo: &validTo},
+				{UserID: "123", Version: 2, Email: "new@example.com", ValidFrom: validTo},
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "unknown user",
+			expectedStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a new Fiber app
+			app := newTestApp()
+
+			// Create mock repositories
+			mockHistoryRepo := mocks.NewMockHistoryRepo(ctrl)
+			mockHistoryRepo.EXPECT().GetVersions(gomock.Any(), "123").Return(tt.mockVersions, nil)
+
+			// Create service with mocks
+			userService := service.NewUserService(user.NewOps(mocks.NewMockUserRepo(ctrl)), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+			userService.SetHistoryOps(history.NewOps(mockHistoryRepo))
+
+			// Setup route
+			app.Get("/users/:UserID/history", GetUserHistory(userService))
+
+			// Create request
+			req := httptest.NewRequest("GET", "/users/123/history", nil)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert status code
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			// Assert response body
+			var got []entities.UserVersion
+			err = json.NewDecoder(resp.Body).Decode(&got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.mockVersions, got)
+		})
+	}
+}
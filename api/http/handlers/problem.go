@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sika/internal/address"
+	"sika/internal/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProblemContentType is the media type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem detail. Handlers return it as an error and
+// ErrorHandler writes it. Errors lists the invalid fields of a 422.
+type Problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Detail string            `json:"detail,omitempty"`
+	Errors []user.FieldError `json:"errors,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// newProblem returns a problem without a type URI, whose title is the
+// standard text of status.
+func newProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func badRequest(detail string) *Problem {
+	return newProblem(fiber.StatusBadRequest, detail)
+}
+
+// invalidFields returns the 422 problem listing errs.
+func invalidFields(errs ...user.FieldError) *Problem {
+	p := newProblem(fiber.StatusUnprocessableEntity, "validation failed")
+	p.Errors = errs
+	return p
+}
+
+// problemStatuses maps the domain errors to statuses, the more specific
+// errors first. The detail of the response is the message of the error
+// matched, so it never shows database internals.
+var problemStatuses = []struct {
+	err    error
+	status int
+}{
+	{user.ErrIDTaken, fiber.StatusConflict},
+	{user.ErrEmailTaken, fiber.StatusConflict},
+	{address.ErrNotFound, fiber.StatusNotFound},
+	{user.ErrNotFound, fiber.StatusNotFound},
+	{user.ErrVersionMismatch, fiber.StatusPreconditionFailed},
+	{user.ErrConflict, fiber.StatusConflict},
+	{address.ErrConflict, fiber.StatusConflict},
+	{user.ErrUnavailable, fiber.StatusServiceUnavailable},
+	{address.ErrUnavailable, fiber.StatusServiceUnavailable},
+}
+
+// problemOf returns the problem detail answering err.
+func problemOf(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return newProblem(fiberErr.Code, fiberErr.Message)
+	}
+	var userErr *user.ValidationError
+	if errors.As(err, &userErr) {
+		return invalidFields(userErr.Errors...)
+	}
+	var addressErr *address.ValidationError
+	if errors.As(err, &addressErr) {
+		return invalidFields(addressErr.Errors...)
+	}
+	for _, s := range problemStatuses {
+		if errors.Is(err, s.err) {
+			return newProblem(s.status, s.err.Error())
+		}
+	}
+	return newProblem(fiber.StatusInternalServerError, "internal server error")
+}
+
+// ErrorHandler is the Fiber error handler. It writes every error returned by
+// a handler as an application/problem+json response and logs server errors.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	p := problemOf(err)
+	if p.Status >= fiber.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+	}
+	return c.Status(p.Status).JSON(p, ProblemContentType)
+}
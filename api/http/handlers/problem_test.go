@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTestApp returns an app answering errors like the server does.
+func newTestApp() *fiber.App {
+	return fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+}
+
+// problemBody is the decoded problem+json body of an error response.
+func problemBody(status int, detail string, errs ...interface{}) map[string]interface{} {
+	body := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": float64(status),
+		"detail": detail,
+	}
+	if len(errs) > 0 {
+		body["errors"] = errs
+	}
+	return body
+}
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:           "user not found",
+			err:            fmt.Errorf("failed to get user: %w", fmt.Errorf("%w: %w", user.ErrNotFound, gorm.ErrRecordNotFound)),
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "user not found"),
+		},
+		{
+			name:           "address not found",
+			err:            fmt.Errorf("failed to get address: %w", address.ErrNotFound),
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "address not found"),
+		},
+		{
+			name:           "ID taken",
+			err:            fmt.Errorf("%w: %w", user.ErrIDTaken, gorm.ErrDuplicatedKey),
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   problemBody(fiber.StatusConflict, "a user with this ID already exists"),
+		},
+		{
+			name:           "conflict",
+			err:            fmt.Errorf("%w: %w", address.ErrConflict, gorm.ErrDuplicatedKey),
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   problemBody(fiber.StatusConflict, "address conflicts with a stored address"),
+		},
+		{
+			name:           "unavailable",
+			err:            fmt.Errorf("%w: %w", address.ErrUnavailable, context.DeadlineExceeded),
+			expectedStatus: fiber.StatusServiceUnavailable,
+			expectedBody:   problemBody(fiber.StatusServiceUnavailable, "address storage is unavailable"),
+		},
+		{
+			name:           "invalid address",
+			err:            &address.ValidationError{Errors: []user.FieldError{{Field: "street", Message: "must be at most 255 characters"}}},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "street", "message": "must be at most 255 characters"},
+			),
+		},
+		{
+			name:           "fiber error",
+			err:            fiber.ErrMethodNotAllowed,
+			expectedStatus: fiber.StatusMethodNotAllowed,
+			expectedBody:   problemBody(fiber.StatusMethodNotAllowed, "Method Not Allowed"),
+		},
+		{
+			name:           "unknown error is not shown",
+			err:            errors.New("pq: relation \"users\" does not exist"),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedBody:   problemBody(fiber.StatusInternalServerError, "internal server error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return tt.err
+			})
+
+			// Execute
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, ProblemContentType, resp.Header.Get(fiber.HeaderContentType))
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
@@ -1,27 +1,295 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
 	"sika/service"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// GetUserByID serves GET /users/:UserID?fields=&include=. The addresses are
+// embedded unless fields or include is given without them. The current user
+// has an ETag and is answered with 304 when If-None-Match matches it; a user
+// read as_of a past time has none.
 func GetUserByID(userService *service.UserService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userID := c.Params("UserID")
 		if userID == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"message": "userID is required",
-			})
+			return badRequest("userID is required")
+		}
+		p, err := userProjection(c, true)
+		if err != nil {
+			return err
+		}
+		includeDeleted := c.QueryBool("include_deleted")
+
+		getUser := func(ctx context.Context, id string) (*entities.User, error) {
+			return userService.GetUserProjection(ctx, id, p, includeDeleted)
+		}
+		if asOf := c.Query("as_of"); asOf != "" {
+			at, err := parseAsOf(asOf)
+			if err != nil {
+				return badRequest("as_of must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			}
+			getUser = func(ctx context.Context, id string) (*entities.User, error) {
+				u, err := userService.GetUserAsOf(ctx, id, at, includeDeleted)
+				if err != nil {
+					return nil, err
+				}
+				projected := p.Apply(*u)
+				return &projected, nil
+			}
+		}
+
+		u, err := getUser(c.UserContext(), userID)
+		if err != nil {
+			return err
+		}
+
+		if c.Query("as_of") == "" {
+			etag := userETag(u, p)
+			c.Set(fiber.HeaderETag, etag)
+			if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+				return c.SendStatus(fiber.StatusNotModified)
+			}
+		}
+		return c.Status(fiber.StatusOK).JSON(projectUser(*u, p))
+	}
+}
+
+// userProjection parses ?fields= and ?include=. Addresses are embedded when
+// include names them, and by default when neither parameter is given.
+func userProjection(c *fiber.Ctx, defaultAddresses bool) (user.Projection, error) {
+	var p user.Projection
+	var err error
+	fields, include := c.Query("fields"), c.Query("include")
+	if fields != "" {
+		if p.Fields, err = user.ParseFields(fields); err != nil {
+			return p, badRequest(err.Error())
+		}
+	}
+	switch {
+	case include != "":
+		if p.IncludeAddresses, err = user.ParseInclude(include); err != nil {
+			return p, badRequest(err.Error())
+		}
+	case fields == "":
+		p.IncludeAddresses = defaultAddresses
+	}
+	return p, nil
+}
+
+// projectUser renders the ID and the fields of p, and the addresses when p
+// includes them. Without fields the whole user is rendered.
+func projectUser(u entities.User, p user.Projection) interface{} {
+	if len(p.Fields) == 0 {
+		return u
+	}
+	var whole map[string]json.RawMessage
+	b, _ := json.Marshal(u)
+	_ = json.Unmarshal(b, &whole)
+
+	projected := make(map[string]json.RawMessage, len(p.Fields)+2)
+	for _, f := range p.Columns() {
+		if raw, ok := whole[f]; ok {
+			projected[f] = raw
+		}
+	}
+	if p.IncludeAddresses {
+		projected[user.IncludeAddresses] = whole["addresses"]
+	}
+	return projected
+}
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+	maxBatchGetIDs   = 500
+)
+
+// ListUsers serves GET /users?limit=&sort=&order=&cursor=&include_addresses=&filter=.
+// The cursor of the next page is returned in the body, in X-Next-Cursor and
+// as a Link header, and is absent on the last page.
+func ListUsers(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p, err := userProjection(c, false)
+		if err != nil {
+			return err
+		}
+		p.IncludeAddresses = p.IncludeAddresses || c.QueryBool("include_addresses")
+		q := user.ListQuery{
+			SortBy:     c.Query("sort", user.SortByID),
+			Limit:      c.QueryInt("limit", defaultPageLimit),
+			Projection: p,
+		}
+		switch order := c.Query("order", "asc"); order {
+		case "asc":
+		case "desc":
+			q.Descending = true
+		default:
+			return badRequest("order must be asc or desc")
+		}
+		if expr := c.Query("filter"); expr != "" {
+			f, err := user.ParseFilter(expr)
+			if err != nil {
+				return badRequest(err.Error())
+			}
+			q.Filter = f
+		}
+		if q.Limit > maxPageLimit {
+			return badRequest(fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+		}
+		if token := c.Query("cursor"); token != "" {
+			after, err := user.DecodeCursor(token)
+			if err != nil {
+				return badRequest(err.Error())
+			}
+			q.After = &after
+		}
+		if err := q.Validate(); err != nil {
+			return badRequest(err.Error())
+		}
+
+		users, next, err := userService.ListUsers(c.UserContext(), q)
+		if err != nil {
+			return err
+		}
+
+		var nextCursor *string
+		if next != nil {
+			token := next.Encode()
+			nextCursor = &token
+			c.Set("X-Next-Cursor", token)
+			c.Set(fiber.HeaderLink, fmt.Sprintf(`<%s?%s>; rel="next"`, c.Path(), nextPageQuery(c, token)))
+		}
+		projected := make([]interface{}, len(users))
+		for i, u := range users {
+			projected[i] = projectUser(u, p)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"users":       projected,
+			"next_cursor": nextCursor,
+		})
+	}
+}
+
+// nextPageQuery is the query string of the request with the cursor replaced.
+func nextPageQuery(c *fiber.Ctx, cursor string) string {
+	values := url.Values{}
+	for k, v := range c.Queries() {
+		values.Set(k, v)
+	}
+	values.Set("cursor", cursor)
+	return values.Encode()
+}
+
+// SearchUsers serves GET /users/search?q=&limit=&offset=. next_offset is null
+// on the last page.
+func SearchUsers(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			return badRequest("q is required")
+		}
+		limit := c.QueryInt("limit", defaultPageLimit)
+		offset := c.QueryInt("offset", 0)
+		if limit < 1 || limit > maxPageLimit || offset < 0 {
+			return badRequest(fmt.Sprintf("limit must be between 1 and %d and offset must not be negative", maxPageLimit))
 		}
 
-		user, err := userService.GetUserByID(c.Context(), userID)
+		matches, more, err := userService.SearchUsers(c.UserContext(), query, limit, offset)
 		if err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"message": "user not found",
-			})
+			return err
 		}
 
-		return c.Status(fiber.StatusOK).JSON(user)
+		var nextOffset *int
+		if more {
+			next := offset + len(matches)
+			nextOffset = &next
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"users":       matches,
+			"limit":       limit,
+			"offset":      offset,
+			"next_offset": nextOffset,
+		})
+	}
+}
+
+// batchGetRequest is the body of POST /users:batchGet.
+type batchGetRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// BatchGetUsers serves POST /users:batchGet with up to maxBatchGetIDs IDs. It
+// answers the active users found, with their addresses and in the order of
+// the request, and the IDs that were not found.
+func BatchGetUsers(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req batchGetRequest
+		if err := decodeBody(c, &req); err != nil {
+			return err
+		}
+
+		var errs []user.FieldError
+		switch {
+		case len(req.IDs) == 0:
+			errs = append(errs, user.FieldError{Field: "ids", Message: "is required"})
+		case len(req.IDs) > maxBatchGetIDs:
+			errs = append(errs, user.FieldError{Field: "ids", Message: fmt.Sprintf("must have at most %d IDs", maxBatchGetIDs)})
+		}
+		for i, id := range req.IDs {
+			if id == "" {
+				errs = append(errs, user.FieldError{Field: fmt.Sprintf("ids[%d]", i), Message: "must not be empty"})
+			}
+		}
+		if len(errs) > 0 {
+			return invalidFields(errs...)
+		}
+
+		users, missing, err := userService.GetUsersByIDs(c.UserContext(), req.IDs)
+		if err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"users":   users,
+			"missing": missing,
+		})
+	}
+}
+
+func GetUserHistory(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Params("UserID")
+		if userID == "" {
+			return badRequest("userID is required")
+		}
+
+		versions, err := userService.GetUserHistory(c.UserContext(), userID)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(fiber.StatusOK).JSON(versions)
+	}
+}
+
+// parseAsOf accepts an RFC 3339 timestamp, or a date meaning what was held at
+// the end of that day in UTC.
+func parseAsOf(v string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return at, nil
+	}
+	day, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
 }
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"sika/internal/address"
 	"sika/internal/user"
+	"sika/pkg/filter"
 	"sika/pkg/storage/entities"
 	"sika/service"
 	"sika/test/mocks"
@@ -15,6 +21,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestGetUserByID(t *testing.T) {
@@ -24,6 +31,9 @@ func TestGetUserByID(t *testing.T) {
 	tests := []struct {
 		name           string
 		userID         string
+		includeDeleted bool
+		query          string
+		projection     *user.Projection
 		mockUser       *entities.User
 		mockError      error
 		expectedStatus int
@@ -46,33 +56,135 @@ func TestGetUserByID(t *testing.T) {
 				"email":        "test@example.com",
 				"phone_number": "1234567890",
 				"addresses":    nil,
+				"created_at":   "0001-01-01T00:00:00Z",
+				"updated_at":   "0001-01-01T00:00:00Z",
+				"deleted_at":   nil,
 			},
 		},
+		{
+			name:           "get soft-deleted user",
+			userID:         "456",
+			includeDeleted: true,
+			mockUser: &entities.User{
+				ID:        "456",
+				Name:      "Deleted User",
+				DeletedAt: gorm.DeletedAt{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+			},
+			mockError:      nil,
+			expectedStatus: fiber.StatusOK,
+			expectedBody: map[string]interface{}{
+				"id":           "456",
+				"name":         "Deleted User",
+				"email":        "",
+				"phone_number": "",
+				"addresses":    nil,
+				"created_at":   "0001-01-01T00:00:00Z",
+				"updated_at":   "0001-01-01T00:00:00Z",
+				"deleted_at":   "2026-01-02T03:04:05Z",
+			},
+		},
+		{
+			name:       "sparse fieldset",
+			userID:     "123",
+			query:      "fields=name,email",
+			projection: &user.Projection{Fields: []string{"name", "email"}},
+			mockUser: &entities.User{
+				ID:    "123",
+				Name:  "Test User",
+				Email: "test@example.com",
+			},
+			expectedStatus: fiber.StatusOK,
+			expectedBody: map[string]interface{}{
+				"id":    "123",
+				"name":  "Test User",
+				"email": "test@example.com",
+			},
+		},
+		{
+			name:       "sparse fieldset with addresses",
+			userID:     "123",
+			query:      "fields=name&include=addresses",
+			projection: &user.Projection{Fields: []string{"name"}, IncludeAddresses: true},
+			mockUser: &entities.User{
+				ID:        "123",
+				Name:      "Test User",
+				Addresses: []entities.Address{{ID: 7, UserID: "123", Street: "1 Test St"}},
+			},
+			expectedStatus: fiber.StatusOK,
+			expectedBody: map[string]interface{}{
+				"id":   "123",
+				"name": "Test User",
+				"addresses": []interface{}{map[string]interface{}{
+					"address_id": float64(7),
+					"user_id":    "123",
+					"street":     "1 Test St",
+					"city":       "",
+					"state":      "",
+					"zip_code":   "",
+					"country":    "",
+					"created_at": "0001-01-01T00:00:00Z",
+					"updated_at": "0001-01-01T00:00:00Z",
+					"deleted_at": nil,
+				}},
+			},
+		},
+		{
+			name:           "unknown field",
+			userID:         "123",
+			query:          "fields=name,password",
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   problemBody(fiber.StatusBadRequest, `unknown field "password", expected some of id, name, email, phone_number, created_at, updated_at, deleted_at`),
+		},
+		{
+			name:           "unknown relation",
+			userID:         "123",
+			query:          "include=orders",
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   problemBody(fiber.StatusBadRequest, `unknown relation "orders", expected addresses`),
+		},
 		{
 			name:           "user not found",
 			userID:         "999",
 			mockUser:       nil,
-			mockError:      assert.AnError,
+			mockError:      user.ErrNotFound,
 			expectedStatus: fiber.StatusNotFound,
-			expectedBody: map[string]interface{}{
-				"message": "user not found",
-			},
+			expectedBody:   problemBody(fiber.StatusNotFound, "user not found"),
+		},
+		{
+			name:           "database unavailable",
+			userID:         "123",
+			mockUser:       nil,
+			mockError:      fmt.Errorf("%w: %w", user.ErrUnavailable, context.DeadlineExceeded),
+			expectedStatus: fiber.StatusServiceUnavailable,
+			expectedBody:   problemBody(fiber.StatusServiceUnavailable, "user storage is unavailable"),
+		},
+		{
+			name:           "database error",
+			userID:         "123",
+			mockUser:       nil,
+			mockError:      assert.AnError,
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedBody:   problemBody(fiber.StatusInternalServerError, "internal server error"),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create a new Fiber app
-			app := fiber.New()
+			app := newTestApp()
 
 			// Create mock repositories
 			mockUserRepo := mocks.NewMockUserRepo(ctrl)
 			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
 
 			// Setup mock expectations only if we expect a call
-			if tt.userID != "" {
+			if tt.expectedStatus != fiber.StatusBadRequest {
+				projection := user.Projection{IncludeAddresses: true}
+				if tt.projection != nil {
+					projection = *tt.projection
+				}
 				mockUserRepo.EXPECT().
-					GetUserByID(gomock.Any(), tt.userID).
+					GetUserProjection(gomock.Any(), tt.userID, projection, tt.includeDeleted).
 					Return(tt.mockUser, tt.mockError).
 					Times(1)
 			}
@@ -86,7 +198,11 @@ func TestGetUserByID(t *testing.T) {
 			app.Get("/users/:UserID", GetUserByID(userService))
 
 			// Create request
-			req := httptest.NewRequest("GET", "/users/"+tt.userID, nil)
+			url := "/users/" + tt.userID + "?" + tt.query
+			if tt.includeDeleted {
+				url += "&include_deleted=true"
+			}
+			req := httptest.NewRequest("GET", url, nil)
 			resp, err := app.Test(req)
 			require.NoError(t, err)
 
@@ -103,3 +219,352 @@ func TestGetUserByID(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	matches := []entities.UserMatch{
+		{User: entities.User{ID: "1", Name: "Anna"}, Score: 1},
+		{User: entities.User{ID: "2", Name: "Anna Smith"}, Score: 0.5},
+		{User: entities.User{ID: "3", Name: "Hanna"}, Score: 0.4},
+	}
+
+	tests := []struct {
+		name               string
+		query              string
+		expectSearch       bool
+		limit, offset      int
+		mockMatches        []entities.UserMatch
+		mockError          error
+		expectedStatus     int
+		expectedIDs        []string
+		expectedNextOffset interface{}
+	}{
+		{
+			name:               "first page",
+			query:              "?q=anna&limit=2",
+			expectSearch:       true,
+			limit:              3,
+			mockMatches:        matches,
+			expectedStatus:     fiber.StatusOK,
+			expectedIDs:        []string{"1", "2"},
+			expectedNextOffset: float64(2),
+		},
+		{
+			name:               "last page",
+			query:              "?q=anna&limit=2&offset=2",
+			expectSearch:       true,
+			limit:              3,
+			offset:             2,
+			mockMatches:        matches[2:],
+			expectedStatus:     fiber.StatusOK,
+			expectedIDs:        []string{"3"},
+			expectedNextOffset: nil,
+		},
+		{
+			name:               "default limit",
+			query:              "?q=nobody",
+			expectSearch:       true,
+			limit:              21,
+			mockMatches:        []entities.UserMatch{},
+			expectedStatus:     fiber.StatusOK,
+			expectedIDs:        []string{},
+			expectedNextOffset: nil,
+		},
+		{
+			name:           "missing query",
+			query:          "?q=%20",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			query:          "?q=anna&limit=1000",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "search fails",
+			query:          "?q=anna",
+			expectSearch:   true,
+			limit:          21,
+			mockError:      assert.AnError,
+			expectedStatus: fiber.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+
+			if tt.expectSearch {
+				mockUserRepo.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Any(), tt.limit, tt.offset).
+					Return(tt.mockMatches, tt.mockError).
+					Times(1)
+			}
+
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			app.Get("/users/search", SearchUsers(userService))
+
+			req := httptest.NewRequest("GET", "/users/search"+tt.query, nil)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			var responseBody struct {
+				Users      []entities.UserMatch `json:"users"`
+				NextOffset interface{}          `json:"next_offset"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&responseBody))
+			ids := []string{}
+			for _, m := range responseBody.Users {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, tt.expectedNextOffset, responseBody.NextOffset)
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := []entities.User{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}, {ID: "3", Name: "Carol"}}
+	cursor := user.CursorAfter(users[1], user.SortByName, true)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedQuery  *user.ListQuery
+		mockUsers      []entities.User
+		expectedStatus int
+		expectedIDs    []string
+		expectedNext   *user.Cursor
+	}{
+		{
+			name:           "first page has a next cursor",
+			query:          "?limit=2&sort=name&order=desc",
+			expectedQuery:  &user.ListQuery{SortBy: user.SortByName, Descending: true, Limit: 3},
+			mockUsers:      users,
+			expectedStatus: fiber.StatusOK,
+			expectedIDs:    []string{"1", "2"},
+			expectedNext:   &cursor,
+		},
+		{
+			name:           "last page",
+			query:          "?limit=2&sort=name&order=desc&include_addresses=true&cursor=" + cursor.Encode(),
+			expectedQuery:  &user.ListQuery{SortBy: user.SortByName, Descending: true, Limit: 3, After: &cursor, Projection: user.Projection{IncludeAddresses: true}},
+			mockUsers:      users[2:],
+			expectedStatus: fiber.StatusOK,
+			expectedIDs:    []string{"3"},
+		},
+		{
+			name:           "defaults",
+			query:          "",
+			expectedQuery:  &user.ListQuery{SortBy: user.SortByID, Limit: 21},
+			mockUsers:      []entities.User{},
+			expectedStatus: fiber.StatusOK,
+			expectedIDs:    []string{},
+		},
+		{
+			name:           "filter",
+			query:          "?filter=" + url.QueryEscape("country = AR and address_count > 2"),
+			expectedQuery:  &user.ListQuery{SortBy: user.SortByID, Limit: 21, Filter: mustParseFilter(t, "country = AR and address_count > 2")},
+			mockUsers:      users[:1],
+			expectedStatus: fiber.StatusOK,
+			expectedIDs:    []string{"1"},
+		},
+		{
+			name:           "sparse fieldset with addresses",
+			query:          "?fields=name&include=addresses",
+			expectedQuery:  &user.ListQuery{SortBy: user.SortByID, Limit: 21, Projection: user.Projection{Fields: []string{"name"}, IncludeAddresses: true}},
+			mockUsers:      users[:1],
+			expectedStatus: fiber.StatusOK,
+			expectedIDs:    []string{"1"},
+		},
+		{
+			name:           "unknown field",
+			query:          "?fields=password",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "filter on unknown field",
+			query:          "?filter=" + url.QueryEscape("password = x"),
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "unknown sort field",
+			query:          "?sort=phone_number",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "cursor of another sort",
+			query:          "?sort=email&cursor=" + cursor.Encode(),
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "malformed cursor",
+			query:          "?cursor=%25%25",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			query:          "?limit=1000",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+
+			if tt.expectedQuery != nil {
+				mockUserRepo.EXPECT().
+					ListUsers(gomock.Any(), *tt.expectedQuery).
+					Return(tt.mockUsers, nil).
+					Times(1)
+			}
+
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			app.Get("/users", ListUsers(userService))
+
+			req := httptest.NewRequest("GET", "/users"+tt.query, nil)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			var responseBody struct {
+				Users      []entities.User `json:"users"`
+				NextCursor *string         `json:"next_cursor"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&responseBody))
+			ids := []string{}
+			for _, u := range responseBody.Users {
+				ids = append(ids, u.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+
+			if tt.expectedNext == nil {
+				assert.Nil(t, responseBody.NextCursor)
+				assert.Empty(t, resp.Header.Get("Link"))
+				return
+			}
+			require.NotNil(t, responseBody.NextCursor)
+			next, err := user.DecodeCursor(*responseBody.NextCursor)
+			require.NoError(t, err)
+			assert.Equal(t, *tt.expectedNext, next)
+			assert.Equal(t, *responseBody.NextCursor, resp.Header.Get("X-Next-Cursor"))
+			assert.Contains(t, resp.Header.Get("Link"), "cursor="+*responseBody.NextCursor)
+			assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+		})
+	}
+}
+
+func mustParseFilter(t *testing.T, s string) filter.Expr {
+	f, err := user.ParseFilter(s)
+	require.NoError(t, err)
+	return f
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name            string
+		body            string
+		setupMocks      func(repo *mocks.MockUserRepo)
+		expectedStatus  int
+		expectedUserIDs []string
+		expectedMissing []string
+	}{
+		{
+			name: "found and missing in request order",
+			body: `{"ids":["2","404","1","2"]}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUsersByIDs(gomock.Any(), []string{"2", "404", "1", "2"}).Return([]entities.User{
+					{ID: "1", Addresses: []entities.Address{{ID: 1, UserID: "1"}}},
+					{ID: "2"},
+				}, nil)
+			},
+			expectedStatus:  fiber.StatusOK,
+			expectedUserIDs: []string{"2", "1"},
+			expectedMissing: []string{"404"},
+		},
+		{
+			name:           "no IDs",
+			body:           `{"ids":[]}`,
+			setupMocks:     func(repo *mocks.MockUserRepo) {},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+		},
+		{
+			name:           "too many IDs",
+			body:           `{"ids":["1"` + strings.Repeat(`,"1"`, maxBatchGetIDs) + `]}`,
+			setupMocks:     func(repo *mocks.MockUserRepo) {},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+		},
+		{
+			name:           "empty ID",
+			body:           `{"ids":["1",""]}`,
+			setupMocks:     func(repo *mocks.MockUserRepo) {},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+		},
+		{
+			name: "database error",
+			body: `{"ids":["1"]}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUsersByIDs(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
+			},
+			expectedStatus: fiber.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			tt.setupMocks(mockUserRepo)
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+			app.Post("/users\\:batchGet", BatchGetUsers(userService))
+
+			req := httptest.NewRequest("POST", "/users:batchGet", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Execute
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+			var body struct {
+				Users   []entities.User `json:"users"`
+				Missing []string        `json:"missing"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			var ids []string
+			for _, u := range body.Users {
+				ids = append(ids, u.ID)
+			}
+			assert.Equal(t, tt.expectedUserIDs, ids)
+			assert.Equal(t, tt.expectedMissing, body.Missing)
+			assert.Len(t, body.Users[1].Addresses, 1)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sika/internal/user"
+	"sika/pkg/mergepatch"
+	"sika/pkg/storage/entities"
+	"sika/service"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userRequest is the body of POST /users and PUT /users/:UserID.
+type userRequest struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Email       string           `json:"email"`
+	PhoneNumber string           `json:"phone_number"`
+	Addresses   []addressRequest `json:"addresses"`
+}
+
+type addressRequest struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	ZipCode string `json:"zip_code"`
+	Country string `json:"country"`
+}
+
+func (r userRequest) entity() *entities.User {
+	u := &entities.User{
+		ID:          r.ID,
+		Name:        r.Name,
+		Email:       r.Email,
+		PhoneNumber: r.PhoneNumber,
+	}
+	for _, a := range r.Addresses {
+		u.Addresses = append(u.Addresses, a.entity())
+	}
+	return u
+}
+
+func (r addressRequest) entity() entities.Address {
+	return entities.Address{
+		Street:  r.Street,
+		City:    r.City,
+		State:   r.State,
+		ZipCode: r.ZipCode,
+		Country: r.Country,
+	}
+}
+
+// CreateUser serves POST /users. The ID is generated when the body has none.
+func CreateUser(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req userRequest
+		if err := decodeBody(c, &req); err != nil {
+			return err
+		}
+
+		u := req.entity()
+		if err := userService.CreateUser(c.UserContext(), u); err != nil {
+			return err
+		}
+
+		c.Location("/users/" + u.ID)
+		c.Set(fiber.HeaderETag, userETag(u, user.Projection{IncludeAddresses: true}))
+		return c.Status(fiber.StatusCreated).JSON(u)
+	}
+}
+
+// ReplaceUser serves PUT /users/:UserID, replacing the name, email and phone
+// number of an existing user. Addresses have their own endpoints. If-Match
+// is required, see ifMatchVersion.
+func ReplaceUser(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Params("UserID")
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		var req userRequest
+		if err := decodeBody(c, &req); err != nil {
+			return err
+		}
+		if req.ID != "" && req.ID != userID {
+			return invalidFields(user.FieldError{Field: "id", Message: "does not match the URL"})
+		}
+
+		u := req.entity()
+		u.ID, u.Version = userID, version
+		if err := userService.ReplaceUser(c.UserContext(), u); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderETag, userETag(u, user.Projection{IncludeAddresses: true}))
+		return c.Status(fiber.StatusOK).JSON(u)
+	}
+}
+
+// PatchUser serves PATCH /users/:UserID with a JSON Merge Patch of the name,
+// email and phone number. If-Match is required.
+func PatchUser(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		if err := checkMergePatch(c); err != nil {
+			return err
+		}
+
+		u, err := userService.PatchUser(c.UserContext(), c.Params("UserID"), version, c.Body())
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderETag, userETag(u, user.Projection{IncludeAddresses: true}))
+		return c.Status(fiber.StatusOK).JSON(u)
+	}
+}
+
+// DeleteUser serves DELETE /users/:UserID, a soft delete. If-Match is
+// required.
+func DeleteUser(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		if err := userService.DeleteUser(c.UserContext(), c.Params("UserID"), version); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// checkMergePatch checks the content type and syntax of a merge patch body
+// and returns the problem to answer when they are wrong.
+func checkMergePatch(c *fiber.Ctx) error {
+	contentType := strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0])
+	if contentType != mergepatch.ContentType && contentType != fiber.MIMEApplicationJSON {
+		return newProblem(fiber.StatusUnsupportedMediaType, "content type must be "+mergepatch.ContentType)
+	}
+	if !json.Valid(c.Body()) {
+		return badRequest("request body is not valid JSON")
+	}
+	return nil
+}
+
+// decodeBody decodes the JSON body into v, rejecting unknown fields. It
+// returns the problem to answer when the body does not fit v.
+func decodeBody(c *fiber.Ctx, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(c.Body()))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return invalidFields(user.FieldError{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return invalidFields(user.FieldError{Field: field, Message: "is not a writable field"})
+	default:
+		return badRequest("request body is not valid JSON")
+	}
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/service"
+	"sika/test/mocks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		body           string
+		setupMocks     func(repo *mocks.MockUserRepo)
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name: "created",
+			body: `{"id":"123","name":" Test User ","email":"test@example.com","phone_number":"+1 555 0100","addresses":[{"street":"1 Test St"}]}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByEmail(gomock.Any(), "test@example.com").Return(nil, user.ErrNotFound)
+				repo.EXPECT().CreateBatchUsers(gomock.Any(), []entities.User{{
+					ID:          "123",
+					Name:        "Test User",
+					Email:       "test@example.com",
+					PhoneNumber: "+1 555 0100",
+					Addresses:   []entities.Address{{UserID: "123", Street: "1 Test St"}},
+				}}).Return(nil)
+			},
+			expectedStatus: fiber.StatusCreated,
+		},
+		{
+			name: "duplicate ID",
+			body: `{"id":"123","name":"Test User","email":"test@example.com"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByEmail(gomock.Any(), "test@example.com").Return(nil, user.ErrNotFound)
+				repo.EXPECT().CreateBatchUsers(gomock.Any(), gomock.Any()).Return(user.ErrIDTaken)
+			},
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   problemBody(fiber.StatusConflict, "a user with this ID already exists"),
+		},
+		{
+			name: "duplicate email",
+			body: `{"id":"123","name":"Test User","email":"TEST@example.com"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByEmail(gomock.Any(), "TEST@example.com").Return(&entities.User{ID: "456"}, nil)
+			},
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   problemBody(fiber.StatusConflict, "email is already used by another user"),
+		},
+		{
+			name:           "invalid fields",
+			body:           `{"id":"a/b","email":"not an email","phone_number":"12"}`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "id", "message": "may only contain letters, digits, '-' and '_'"},
+				map[string]interface{}{"field": "name", "message": "is required"},
+				map[string]interface{}{"field": "email", "message": "is not a valid email address"},
+				map[string]interface{}{"field": "phone_number", "message": "must have 7 to 15 digits, optionally with a leading '+', spaces, '-', '.' and parentheses"},
+			),
+		},
+		{
+			name:           "unknown field",
+			body:           `{"name":"Test User","email":"test@example.com","created_at":"2026-01-01T00:00:00Z"}`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "created_at", "message": "is not a writable field"},
+			),
+		},
+		{
+			name:           "wrong type",
+			body:           `{"name":42}`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "name", "message": "must be a string"},
+			),
+		},
+		{
+			name:           "malformed JSON",
+			body:           `{"name":`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   problemBody(fiber.StatusBadRequest, "request body is not valid JSON"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockUserRepo)
+			}
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			app.Post("/users", CreateUser(userService))
+
+			// Execute
+			req := httptest.NewRequest("POST", "/users", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			var responseBody map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&responseBody))
+			if tt.expectedStatus == fiber.StatusCreated {
+				assert.Equal(t, "/users/123", resp.Header.Get("Location"))
+				assert.Equal(t, "123", responseBody["id"])
+				assert.Equal(t, "Test User", responseBody["name"])
+				assert.Len(t, responseBody["addresses"], 1)
+				return
+			}
+			assert.Equal(t, tt.expectedBody, responseBody)
+		})
+	}
+}
+
+func TestCreateUserGeneratesID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	app := newTestApp()
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Return(nil, user.ErrNotFound)
+	mockUserRepo.EXPECT().CreateBatchUsers(gomock.Any(), gomock.Any()).Return(nil)
+	userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+	app.Post("/users", CreateUser(userService))
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Test User","email":"test@example.com"}`))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var created entities.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Len(t, created.ID, 36)
+	assert.Equal(t, "/users/"+created.ID, resp.Header.Get("Location"))
+}
+
+func TestReplaceUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	current := &entities.User{ID: "123", Name: "Old Name", Email: "old@example.com", PhoneNumber: "5550100123", Version: 3}
+
+	tests := []struct {
+		name           string
+		ifMatch        string
+		body           string
+		setupMocks     func(repo *mocks.MockUserRepo)
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:    "replaced",
+			ifMatch: `"3"`,
+			body:    `{"name":"New Name","email":"new@example.com"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current, nil)
+				repo.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(nil, user.ErrNotFound)
+				repo.EXPECT().UpdateUser(gomock.Any(), &entities.User{ID: "123", Name: "New Name", Email: "new@example.com", Version: 3}).Return(nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:    "unchanged email is not checked",
+			ifMatch: `"3"`,
+			body:    `{"id":"123","name":"New Name","email":"OLD@example.com"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current, nil)
+				repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:    "user not found",
+			ifMatch: `"3"`,
+			body:    `{"name":"New Name","email":"new@example.com"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(nil, user.ErrNotFound)
+			},
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "user not found"),
+		},
+		{
+			name:           "ID does not match the URL",
+			ifMatch:        `"3"`,
+			body:           `{"id":"456","name":"New Name","email":"new@example.com"}`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "id", "message": "does not match the URL"},
+			),
+		},
+		{
+			name:           "addresses are not replaced",
+			ifMatch:        `"3"`,
+			body:           `{"name":"New Name","email":"new@example.com","addresses":[{"street":"1 Test St"}]}`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "addresses", "message": "cannot be replaced with the user"},
+			),
+		},
+		{
+			name:    "missing required fields",
+			ifMatch: `"3"`,
+			body:    `{"phone_number":"5550100123"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current, nil)
+			},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "name", "message": "is required"},
+				map[string]interface{}{"field": "email", "message": "is required"},
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockUserRepo)
+			}
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			app.Put("/users/:UserID", ReplaceUser(userService))
+
+			// Execute
+			req := httptest.NewRequest("PUT", "/users/123", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			var responseBody map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&responseBody))
+			if tt.expectedStatus == fiber.StatusOK {
+				assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+				assert.Equal(t, "123", responseBody["id"])
+				assert.Equal(t, "New Name", responseBody["name"])
+				return
+			}
+			assert.Equal(t, tt.expectedBody, responseBody)
+		})
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	current := func() *entities.User {
+		return &entities.User{ID: "123", Name: "Old Name", Email: "old@example.com", PhoneNumber: "5550100123", Version: 3}
+	}
+
+	tests := []struct {
+		name           string
+		ifMatch        string
+		contentType    string
+		body           string
+		setupMocks     func(repo *mocks.MockUserRepo)
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:        "patched",
+			ifMatch:     `"3"`,
+			contentType: "application/merge-patch+json",
+			body:        `{"name":"New Name","phone_number":null}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current(), nil)
+				repo.EXPECT().UpdateUser(gomock.Any(), &entities.User{ID: "123", Name: "New Name", Email: "old@example.com", Version: 3}).Return(nil)
+			},
+			expectedStatus: fiber.StatusOK,
+			expectedBody: map[string]interface{}{
+				"name":         "New Name",
+				"email":        "old@example.com",
+				"phone_number": "",
+			},
+		},
+		{
+			name:        "stale version",
+			ifMatch:     `W/"3", "2"`,
+			contentType: "application/merge-patch+json",
+			body:        `{"name":"New Name"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current(), nil)
+			},
+			expectedStatus: fiber.StatusPreconditionFailed,
+			expectedBody:   problemBody(fiber.StatusPreconditionFailed, "user has been changed since the version given"),
+		},
+		{
+			name:           "If-Match missing",
+			contentType:    "application/merge-patch+json",
+			body:           `{"name":"New Name"}`,
+			expectedStatus: fiber.StatusPreconditionRequired,
+			expectedBody:   problemBody(fiber.StatusPreconditionRequired, "If-Match is required"),
+		},
+		{
+			name:        "email taken",
+			ifMatch:     `"3"`,
+			contentType: "application/merge-patch+json",
+			body:        `{"email":"taken@example.com"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current(), nil)
+				repo.EXPECT().GetUserByEmail(gomock.Any(), "taken@example.com").Return(&entities.User{ID: "456"}, nil)
+			},
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   problemBody(fiber.StatusConflict, "email is already used by another user"),
+		},
+		{
+			name:        "required field cleared",
+			ifMatch:     `"3"`,
+			contentType: "application/json",
+			body:        `{"email":null}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(current(), nil)
+			},
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "email", "message": "is required"},
+			),
+		},
+		{
+			name:           "read-only fields",
+			ifMatch:        `"3"`,
+			contentType:    "application/merge-patch+json",
+			body:           `{"id":"456","addresses":[]}`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "addresses", "message": "cannot be patched"},
+				map[string]interface{}{"field": "id", "message": "cannot be patched"},
+			),
+		},
+		{
+			name:           "not an object",
+			ifMatch:        `"3"`,
+			contentType:    "application/merge-patch+json",
+			body:           `["name"]`,
+			expectedStatus: fiber.StatusUnprocessableEntity,
+			expectedBody: problemBody(fiber.StatusUnprocessableEntity, "validation failed",
+				map[string]interface{}{"field": "body", "message": "must be a JSON object"},
+			),
+		},
+		{
+			name:        "user not found",
+			ifMatch:     `"3"`,
+			contentType: "application/merge-patch+json",
+			body:        `{"name":"New Name"}`,
+			setupMocks: func(repo *mocks.MockUserRepo) {
+				repo.EXPECT().GetUserByID(gomock.Any(), "123").Return(nil, user.ErrNotFound)
+			},
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   problemBody(fiber.StatusNotFound, "user not found"),
+		},
+		{
+			name:           "unsupported content type",
+			ifMatch:        `"3"`,
+			contentType:    "text/plain",
+			body:           `{"name":"New Name"}`,
+			expectedStatus: fiber.StatusUnsupportedMediaType,
+			expectedBody:   problemBody(fiber.StatusUnsupportedMediaType, "content type must be application/merge-patch+json"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockUserRepo)
+			}
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+			app.Patch("/users/:UserID", PatchUser(userService))
+
+			// Execute
+			req := httptest.NewRequest("PATCH", "/users/123", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			var responseBody map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&responseBody))
+			if tt.expectedStatus == fiber.StatusOK {
+				assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+				for k, v := range tt.expectedBody {
+					assert.Equal(t, v, responseBody[k], k)
+				}
+				return
+			}
+			assert.Equal(t, tt.expectedBody, responseBody)
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		ifMatch        string
+		version        int64
+		mockError      error
+		expectedStatus int
+	}{
+		{name: "deleted", ifMatch: `"3"`, version: 3, expectedStatus: fiber.StatusNoContent},
+		{name: "any version", ifMatch: "*", version: 0, expectedStatus: fiber.StatusNoContent},
+		{name: "stale version", ifMatch: `"2"`, version: 2, mockError: user.ErrVersionMismatch, expectedStatus: fiber.StatusPreconditionFailed},
+		{name: "If-Match missing", expectedStatus: fiber.StatusPreconditionRequired},
+		{name: "user not found", ifMatch: `"3"`, version: 3, mockError: user.ErrNotFound, expectedStatus: fiber.StatusNotFound},
+		{name: "database error", ifMatch: `"3"`, version: 3, mockError: assert.AnError, expectedStatus: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newTestApp()
+			mockUserRepo := mocks.NewMockUserRepo(ctrl)
+			if tt.ifMatch != "" {
+				mockUserRepo.EXPECT().DeleteUser(gomock.Any(), "123", tt.version).Return(tt.mockError)
+			}
+			userService := service.NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+			app.Delete("/users/:UserID", DeleteUser(userService))
+
+			// Execute
+			req := httptest.NewRequest("DELETE", "/users/123", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
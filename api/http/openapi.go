@@ -0,0 +1,30 @@
+package http
+
+import (
+	_ "embed"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpenAPISpec is the OpenAPI 3 document of the API served by NewApp. It is
+// written by hand, TestOpenAPI fails when the routes or payloads drift from
+// it.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// docsPage renders OpenAPISpec in the browser and sends requests from it. It
+// loads nothing but /openapi.json, so it works offline.
+//
+//go:embed docs.html
+var docsPage []byte
+
+func serveOpenAPI(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(OpenAPISpec)
+}
+
+func serveDocs(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(docsPage)
+}
@@ -0,0 +1,462 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"sika/config"
+	"sika/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openAPI is the part of an OpenAPI document the tests read. Schemas stay
+// generic and are checked by validate.
+type openAPI struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+	raw   map[string]interface{}
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema  map[string]interface{} `json:"schema"`
+			Example interface{}            `json:"example"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+func loadOpenAPI(t *testing.T) *openAPI {
+	t.Helper()
+	var doc openAPI
+	require.NoError(t, json.Unmarshal(OpenAPISpec, &doc))
+	require.NoError(t, json.Unmarshal(OpenAPISpec, &doc.raw))
+	return &doc
+}
+
+// operation returns the operation of method on the path template.
+func (doc *openAPI) operation(t *testing.T, method, path string) openAPIOperation {
+	t.Helper()
+	var op openAPIOperation
+	raw, ok := doc.Paths[path][strings.ToLower(method)]
+	require.True(t, ok, "%s %s is not in the spec", method, path)
+	require.NoError(t, json.Unmarshal(raw, &op))
+	return op
+}
+
+// template returns the path template of the spec that path matches. Literal
+// segments win over parameters, so /users/search is not a user ID.
+func (doc *openAPI) template(path string) (string, bool) {
+	templates := make([]string, 0, len(doc.Paths))
+	for tmpl := range doc.Paths {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+	param := regexp.MustCompile(`\\\{[^}]+\\\}`)
+	for _, tmpl := range templates {
+		pattern := param.ReplaceAllString(regexp.QuoteMeta(tmpl), `[^/]+`)
+		if regexp.MustCompile("^" + pattern + "$").MatchString(path) {
+			return tmpl, true
+		}
+	}
+	return "", false
+}
+
+// resolve follows a local $ref such as #/components/schemas/User.
+func (doc *openAPI) resolve(schema map[string]interface{}) map[string]interface{} {
+	for {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		var node interface{} = doc.raw
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			node = node.(map[string]interface{})[key]
+		}
+		schema = node.(map[string]interface{})
+	}
+}
+
+// validate returns the ways v does not follow schema. Objects may not have
+// properties the schema does not list, so that a field added to a payload
+// has to be added to the spec.
+func (doc *openAPI) validate(schema map[string]interface{}, v interface{}, at string) []string {
+	schema = doc.resolve(schema)
+	if v == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": is null"}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		var problems []string
+		for _, s := range anyOf {
+			p := doc.validate(s.(map[string]interface{}), v, at)
+			if len(p) == 0 {
+				return nil
+			}
+			problems = append(problems, p...)
+		}
+		return append([]string{at + ": matches none of anyOf"}, problems...)
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		var required []interface{}
+		for _, s := range allOf {
+			part := doc.resolve(s.(map[string]interface{}))
+			for name, p := range part["properties"].(map[string]interface{}) {
+				merged["properties"].(map[string]interface{})[name] = p
+			}
+			if r, ok := part["required"].([]interface{}); ok {
+				required = append(required, r...)
+			}
+		}
+		merged["required"] = required
+		schema = merged
+	}
+
+	switch schema["type"] {
+	case "object", nil:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not an object", at, v)}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		if properties == nil {
+			return nil // free-form
+		}
+		var problems []string
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := obj[name.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: %s is missing", at, name))
+				}
+			}
+		}
+		for name, value := range obj {
+			p, ok := properties[name].(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s is not in the spec", at, name))
+				continue
+			}
+			problems = append(problems, doc.validate(p, value, at+"."+name)...)
+		}
+		return problems
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not an array", at, v)}
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, doc.validate(schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not a string", at, v)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", at, s)}
+			}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, s) {
+			return []string{fmt.Sprintf("%s: %q is not one of %v", at, s, enum)}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: %v is not an integer", at, v)}
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return []string{fmt.Sprintf("%s: %v is not a number", at, v)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %v is not a boolean", at, v)}
+		}
+	}
+	return nil
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOpenAPI_RoutesMatchSpec(t *testing.T) {
+	// Setup
+	doc := loadOpenAPI(t)
+	app := newApp(t, config.Config{Auth: openAuth})
+
+	// Execute
+	routes := map[string]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead {
+			continue // Fiber adds a HEAD route for every GET
+		}
+		path := strings.ReplaceAll(r.Path, `\:`, ":")
+		path = regexp.MustCompile(`/:(\w+)`).ReplaceAllString(path, "/{$1}")
+		routes[r.Method+" "+path] = true
+	}
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	// Assert
+	for route := range routes {
+		assert.True(t, documented[route], "route %s is not in openapi.json", route)
+	}
+	for op := range documented {
+		assert.True(t, routes[op], "operation %s of openapi.json has no route", op)
+	}
+}
+
+// specClient sends requests to the app and checks each response against the
+// operation of the spec it answers.
+type specClient struct {
+	t       *testing.T
+	app     *fiber.App
+	doc     *openAPI
+	headers map[string]string
+	covered map[string]bool
+}
+
+// do sends the request with the default headers of the client, which
+// headers override, and returns the status, headers and decoded body of the
+// response after checking them against the spec.
+func (c *specClient) do(method, path string, headers map[string]string, body interface{}) (int, http.Header, interface{}) {
+	t := c.t
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	tmpl, ok := c.doc.template(strings.SplitN(path, "?", 2)[0])
+	require.True(t, ok, "%s is not in the spec", path)
+	op := c.doc.operation(t, method, tmpl)
+	status := fmt.Sprint(resp.StatusCode)
+	rawResponse, ok := op.Responses[status]
+	require.True(t, ok, "%s %s answered %s, which is not in the spec: %s", method, tmpl, status, raw)
+	if resp.StatusCode < 400 {
+		c.covered[method+" "+tmpl] = true
+	}
+
+	var response struct {
+		Headers map[string]map[string]interface{} `json:"headers"`
+		Content map[string]struct {
+			Schema map[string]interface{} `json:"schema"`
+		} `json:"content"`
+	}
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(rawResponse, &schema))
+	require.NoError(t, json.Unmarshal(mustMarshal(t, c.doc.resolve(schema)), &response))
+	for name, h := range response.Headers {
+		if c.doc.resolve(h)["required"] == true {
+			assert.NotEmpty(t, resp.Header.Get(name), "%s %s %s: header %s", method, tmpl, status, name)
+		}
+	}
+	if len(response.Content) == 0 {
+		assert.Empty(t, raw, "%s %s %s has no content in the spec", method, tmpl, status)
+		return resp.StatusCode, resp.Header, nil
+	}
+	contentType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	media, ok := response.Content[contentType]
+	require.True(t, ok, "%s %s %s: content type %s is not in the spec", method, tmpl, status, contentType)
+	if !strings.HasSuffix(contentType, "json") {
+		return resp.StatusCode, resp.Header, string(raw)
+	}
+	var decoded interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	problems := c.doc.validate(media.Schema, decoded, "body")
+	assert.Empty(t, problems, "%s %s %s does not follow the spec: %s", method, tmpl, status, raw)
+	return resp.StatusCode, resp.Header, decoded
+}
+
+// example returns the example request body of an operation.
+func (c *specClient) example(method, tmpl string) interface{} {
+	c.t.Helper()
+	op := c.doc.operation(c.t, method, tmpl)
+	require.NotNil(c.t, op.RequestBody, "%s %s has no request body", method, tmpl)
+	for _, media := range op.RequestBody.Content {
+		if media.Example != nil {
+			return media.Example
+		}
+		if example, ok := c.doc.resolve(media.Schema)["example"]; ok {
+			return example
+		}
+	}
+	c.t.Fatalf("%s %s has no example request body", method, tmpl)
+	return nil
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+// TestOpenAPI_PayloadsMatchSpec runs every operation of the spec, sending the
+// example bodies of the spec, and validates the responses against it.
+func TestOpenAPI_PayloadsMatchSpec(t *testing.T) {
+	// Setup
+	doc := loadOpenAPI(t)
+	apiKey := "spec-key"
+	cfg := config.Config{Auth: config.Auth{APIKeys: []config.APIKey{{Name: "spec", Hash: auth.HashAPIKey(apiKey)}}}}
+	c := &specClient{t: t, app: newApp(t, cfg), doc: doc, headers: map[string]string{auth.APIKeyHeader: apiKey}, covered: map[string]bool{}}
+	anonymous := map[string]string{auth.APIKeyHeader: ""}
+	ifMatch := func(etag string) map[string]string {
+		return map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": etag}
+	}
+
+	// Execute and assert: docs, which need no authentication
+	status, _, _ := c.do("GET", "/openapi.json", anonymous, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, page := c.do("GET", "/docs", anonymous, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, page, "openapi.json")
+
+	// authentication
+	status, _, _ = c.do("GET", "/users", anonymous, nil)
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	status, _, _ = c.do("POST", "/users", map[string]string{auth.APIKeyHeader: "wrong"}, c.example("POST", "/users"))
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+
+	// users
+	status, headers, created := c.do("POST", "/users", nil, c.example("POST", "/users"))
+	require.Equal(t, fiber.StatusCreated, status)
+	userURL := headers.Get("Location")
+	id := created.(map[string]interface{})["id"].(string)
+	status, _, _ = c.do("POST", "/users", nil, c.example("POST", "/users"))
+	assert.Equal(t, fiber.StatusConflict, status)
+	status, _, _ = c.do("POST", "/users", nil, map[string]interface{}{"name": 1})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, _, _ = c.do("GET", "/users?include=addresses", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("GET", "/users?fields=name&limit=1", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("GET", "/users?order=sideways", nil, nil)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _, _ = c.do("GET", "/users/search?q=Ada", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("POST", "/users:batchGet", nil, c.example("POST", "/users:batchGet"))
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("POST", "/users:batchGet", nil, map[string]interface{}{"ids": []string{id}})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, headers, _ = c.do("GET", userURL, nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	etag := headers.Get("ETag")
+	status, _, _ = c.do("GET", userURL, map[string]string{"If-None-Match": etag}, nil)
+	assert.Equal(t, fiber.StatusNotModified, status)
+	status, _, _ = c.do("GET", userURL+"?fields=email,created_at", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("GET", "/users/missing", nil, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	replacement := map[string]interface{}{"name": "Ada King", "email": "ada@example.com"}
+	status, _, _ = c.do("PUT", userURL, nil, replacement)
+	assert.Equal(t, fiber.StatusPreconditionRequired, status)
+	status, headers, _ = c.do("PUT", userURL, map[string]string{"If-Match": etag}, replacement)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("PATCH", userURL, ifMatch(etag), c.example("PATCH", "/users/{UserID}"))
+	assert.Equal(t, fiber.StatusPreconditionFailed, status)
+	status, _, _ = c.do("PATCH", userURL, map[string]string{"Content-Type": "text/plain", "If-Match": "*"}, c.example("PATCH", "/users/{UserID}"))
+	assert.Equal(t, fiber.StatusUnsupportedMediaType, status)
+	status, headers, _ = c.do("PATCH", userURL, ifMatch(headers.Get("ETag")), c.example("PATCH", "/users/{UserID}"))
+	assert.Equal(t, fiber.StatusOK, status)
+	etag = headers.Get("ETag")
+
+	// addresses, written at the current ETag of the user
+	currentETag := func() string {
+		status, headers, _ := c.do("GET", userURL, nil, nil)
+		require.Equal(t, fiber.StatusOK, status)
+		return headers.Get("ETag")
+	}
+	status, _, _ = c.do("GET", userURL+"/addresses", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("POST", userURL+"/addresses", nil, c.example("POST", "/users/{UserID}/addresses"))
+	assert.Equal(t, fiber.StatusPreconditionRequired, status)
+	status, _, _ = c.do("POST", userURL+"/addresses", map[string]string{"If-Match": etag}, c.example("POST", "/users/{UserID}/addresses"))
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _, _ = c.do("POST", userURL+"/addresses", map[string]string{"If-Match": etag}, c.example("POST", "/users/{UserID}/addresses"))
+	assert.Equal(t, fiber.StatusPreconditionFailed, status, "the first address changed the version")
+	status, _, _ = c.do("POST", userURL+"/addresses", map[string]string{"If-Match": "*"}, map[string]interface{}{})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _, set := c.do("PUT", userURL+"/addresses", map[string]string{"If-Match": currentETag()}, c.example("PUT", "/users/{UserID}/addresses"))
+	require.Equal(t, fiber.StatusOK, status)
+	addressURL := fmt.Sprintf("%s/addresses/%.0f", userURL, set.([]interface{})[0].(map[string]interface{})["address_id"])
+	status, _, _ = c.do("GET", addressURL, nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("PUT", addressURL, map[string]string{"If-Match": currentETag()}, c.example("PUT", "/users/{UserID}/addresses/{AddressID}"))
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("PATCH", addressURL, ifMatch(currentETag()), c.example("PATCH", "/users/{UserID}/addresses/{AddressID}"))
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("DELETE", addressURL, map[string]string{"If-Match": currentETag()}, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, _, _ = c.do("GET", addressURL, nil, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	// history, and the delete last
+	status, _, _ = c.do("GET", userURL+"/audit", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _, _ = c.do("GET", userURL+"/history", nil, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, headers, _ = c.do("GET", userURL, nil, nil)
+	require.Equal(t, fiber.StatusOK, status)
+	assert.NotEqual(t, etag, headers.Get("ETag"), "address writes change the version")
+	status, _, _ = c.do("DELETE", userURL, map[string]string{"If-Match": headers.Get("ETag")}, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+
+	// every operation has been run successfully
+	for path, item := range doc.Paths {
+		for method := range item {
+			if op := strings.ToUpper(method) + " " + path; method != "parameters" {
+				assert.True(t, c.covered[op], "%s is not exercised", op)
+			}
+		}
+	}
+}
@@ -1,20 +1,125 @@
 package http
 
 import (
+	"context"
 	"log"
+	"net"
 	"sika/api/http/handlers"
 	"sika/config"
 	"sika/service"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
-func Run(cfg config.Config, app *service.AppContainer) {
-	fiberApp := fiber.New()
-	app, err := service.NewAppContainer(cfg)
+// Run serves fiberApp, built by NewApp, on the address of cfg until ctx is
+// done, then shuts down gracefully. Closing the app container is left to
+// the caller.
+func Run(ctx context.Context, cfg config.Server, fiberApp *fiber.App) error {
+	ln, err := net.Listen("tcp", Addr(cfg))
 	if err != nil {
-		panic(err)
+		return err
 	}
-	fiberApp.Get("/users/:UserID", handlers.GetUserByID(app.UserService()))
-	log.Fatal(fiberApp.Listen("localhost:8080"))
+	return serve(ctx, ln, fiberApp, cfg.ShutdownTimeout)
+}
+
+// Addr returns the address the server listens on, localhost:8080 unless
+// configured otherwise.
+func Addr(cfg config.Server) string {
+	host, port := cfg.Host, cfg.HTTPPort
+	if host == "" {
+		host = "localhost"
+	}
+	if port == 0 {
+		port = 8080
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// serve serves fiberApp on ln until ctx is done. New connections are then
+// refused and in-flight requests get up to timeout, 15s when zero, to finish.
+func serve(ctx context.Context, ln net.Listener, fiberApp *fiber.App, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- fiberApp.Listener(ln)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	log.Printf("shutting down, waiting up to %s for in-flight requests", timeout)
+	if err := fiberApp.ShutdownWithTimeout(timeout); err != nil {
+		return err
+	}
+	return <-errc
+}
+
+// NewApp returns the Fiber app serving the API of app, with the routes
+// described by the OpenAPI document and the limits and authentication of
+// cfg.
+func NewApp(cfg config.Config, app *service.AppContainer) (*fiber.App, error) {
+	authenticate, err := authMiddleware(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	users, docs := authenticate[config.RouteGroupUsers], authenticate[config.RouteGroupDocs]
+
+	fiberApp := fiber.New(fiberConfig(cfg.Server))
+	fiberApp.Use(requestid.New())
+	fiberApp.Use(handlers.AuditContext())
+	fiberApp.Use(handlers.ReadYourWrites())
+	fiberApp.Get("/users", users, handlers.ListUsers(app.UserService()))
+	// registered before /users/:UserID, which would take "search" as an ID
+	fiberApp.Get("/users/search", users, handlers.SearchUsers(app.UserService()))
+	fiberApp.Get("/users/:UserID", users, handlers.GetUserByID(app.UserService()))
+	// the colon of the custom method is escaped, it would start a parameter
+	fiberApp.Post("/users\\:batchGet", users, handlers.BatchGetUsers(app.UserService()))
+	fiberApp.Post("/users", users, handlers.CreateUser(app.UserService()))
+	fiberApp.Put("/users/:UserID", users, handlers.ReplaceUser(app.UserService()))
+	fiberApp.Patch("/users/:UserID", users, handlers.PatchUser(app.UserService()))
+	fiberApp.Delete("/users/:UserID", users, handlers.DeleteUser(app.UserService()))
+	fiberApp.Get("/users/:UserID/addresses", users, handlers.GetUserAddresses(app.UserService()))
+	fiberApp.Post("/users/:UserID/addresses", users, handlers.CreateAddress(app.UserService()))
+	fiberApp.Put("/users/:UserID/addresses", users, handlers.ReplaceAddresses(app.UserService()))
+	fiberApp.Get("/users/:UserID/addresses/:AddressID", users, handlers.GetUserAddress(app.UserService()))
+	fiberApp.Put("/users/:UserID/addresses/:AddressID", users, handlers.ReplaceAddress(app.UserService()))
+	fiberApp.Patch("/users/:UserID/addresses/:AddressID", users, handlers.PatchAddress(app.UserService()))
+	fiberApp.Delete("/users/:UserID/addresses/:AddressID", users, handlers.DeleteAddress(app.UserService()))
+	fiberApp.Get("/users/:UserID/audit", users, handlers.GetUserAudit(app.UserService()))
+	fiberApp.Get("/users/:UserID/history", users, handlers.GetUserHistory(app.UserService()))
+	fiberApp.Get("/openapi.json", docs, serveOpenAPI)
+	fiberApp.Get("/docs", docs, serveDocs)
+	return fiberApp, nil
+}
+
+// fiberConfig returns the Fiber config for the timeouts and body limit of
+// cfg, defaulting those not set.
+func fiberConfig(cfg config.Server) fiber.Config {
+	fc := fiber.Config{
+		ErrorHandler: handlers.ErrorHandler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		BodyLimit:    cfg.BodyLimit,
+	}
+	if fc.ReadTimeout <= 0 {
+		fc.ReadTimeout = 15 * time.Second
+	}
+	if fc.WriteTimeout <= 0 {
+		fc.WriteTimeout = 30 * time.Second
+	}
+	if fc.IdleTimeout <= 0 {
+		fc.IdleTimeout = 60 * time.Second
+	}
+	if fc.BodyLimit <= 0 {
+		fc.BodyLimit = 4 * 1024 * 1024
+	}
+	return fc
 }
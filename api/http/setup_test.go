@@ -0,0 +1,280 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"sika/config"
+	"sika/pkg/auth"
+	"sika/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openAuth accepts unauthenticated requests on the users routes, for the
+// tests that are not about authentication.
+var openAuth = config.Auth{Groups: map[string][]string{config.RouteGroupUsers: {config.AuthMethodNone}}}
+
+// newApp returns the app of an in-memory container configured by cfg.
+func newApp(t *testing.T, cfg config.Config) *fiber.App {
+	t.Helper()
+	container, err := service.NewInMemoryAppContainer(cfg)
+	require.NoError(t, err)
+	app, err := NewApp(cfg, container)
+	require.NoError(t, err)
+	return app
+}
+
+func TestAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Server
+		expected string
+	}{
+		{name: "defaults", expected: "localhost:8080"},
+		{name: "configured", cfg: config.Server{Host: "0.0.0.0", HTTPPort: 9090}, expected: "0.0.0.0:9090"},
+		{name: "IPv6 host", cfg: config.Server{Host: "::1", HTTPPort: 9090}, expected: "[::1]:9090"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Addr(tt.cfg))
+		})
+	}
+}
+
+func TestNewApp_Config(t *testing.T) {
+	fc := newApp(t, config.Config{Auth: openAuth}).Config()
+	assert.Equal(t, 15*time.Second, fc.ReadTimeout)
+	assert.Equal(t, 30*time.Second, fc.WriteTimeout)
+	assert.Equal(t, 60*time.Second, fc.IdleTimeout)
+	assert.Equal(t, 4*1024*1024, fc.BodyLimit)
+
+	server := config.Server{ReadTimeout: time.Second, WriteTimeout: 2 * time.Second, IdleTimeout: 3 * time.Second, BodyLimit: 1024}
+	fc = newApp(t, config.Config{Server: server, Auth: openAuth}).Config()
+	assert.Equal(t, time.Second, fc.ReadTimeout)
+	assert.Equal(t, 2*time.Second, fc.WriteTimeout)
+	assert.Equal(t, 3*time.Second, fc.IdleTimeout)
+	assert.Equal(t, 1024, fc.BodyLimit)
+}
+
+func TestNewApp_BodyLimit(t *testing.T) {
+	// Setup
+	app := newApp(t, config.Config{Server: config.Server{BodyLimit: 64}, Auth: openAuth})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, ln, app, time.Second)
+	}()
+	defer func() {
+		cancel()
+		<-served
+	}()
+
+	// Execute
+	body := `{"name": "` + strings.Repeat("a", 100) + `"}`
+	resp, err := http.Post("http://"+ln.Addr().String()+"/users", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// Assert
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	// Setup
+	app := newApp(t, config.Config{Auth: openAuth})
+	started, release := make(chan struct{}), make(chan struct{})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendString("done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, ln, app, 5*time.Second)
+	}()
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+	<-started
+
+	// Execute
+	cancel()
+
+	// Assert
+	select {
+	case err := <-served:
+		t.Fatalf("serve returned before the in-flight request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, "done", <-responses)
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the in-flight request finished")
+	}
+	_, err = net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	assert.Error(t, err, "the listener is closed")
+}
+
+func TestServe_ShutdownTimeout(t *testing.T) {
+	// Setup
+	app := newApp(t, config.Config{Auth: openAuth})
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	app.Get("/stuck", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, ln, app, 100*time.Millisecond)
+	}()
+	go http.Get("http://" + ln.Addr().String() + "/stuck") //nolint:errcheck
+	<-started
+
+	// Execute
+	cancel()
+
+	// Assert
+	select {
+	case err := <-served:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not give up on the stuck request")
+	}
+}
+
+func TestNewApp_Authentication(t *testing.T) {
+	secret := "test-secret"
+	token := func(sub string) string {
+		claims := jwt.RegisteredClaims{Subject: sub, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+	keys := []config.APIKey{{Name: "ci", Hash: auth.HashAPIKey("ci-key")}}
+
+	tests := []struct {
+		name           string
+		auth           config.Auth
+		path           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{name: "users opened without methods", auth: openAuth, path: "/users", expectedStatus: fiber.StatusOK},
+		{name: "missing credentials", auth: config.Auth{APIKeys: keys}, path: "/users", expectedStatus: fiber.StatusUnauthorized},
+		{name: "api key", auth: config.Auth{APIKeys: keys}, path: "/users", headers: map[string]string{auth.APIKeyHeader: "ci-key"}, expectedStatus: fiber.StatusOK},
+		{name: "wrong api key", auth: config.Auth{APIKeys: keys}, path: "/users", headers: map[string]string{auth.APIKeyHeader: "other"}, expectedStatus: fiber.StatusUnauthorized},
+		{name: "jwt", auth: config.Auth{APIKeys: keys, JWT: config.JWT{HMACSecret: secret}}, path: "/users", headers: map[string]string{"Authorization": token("alice")}, expectedStatus: fiber.StatusOK},
+		{name: "jwt not accepted by the group", auth: config.Auth{APIKeys: keys, JWT: config.JWT{HMACSecret: secret}, Groups: map[string][]string{config.RouteGroupUsers: {config.AuthMethodAPIKey}}}, path: "/users", headers: map[string]string{"Authorization": token("alice")}, expectedStatus: fiber.StatusUnauthorized},
+		{name: "docs open by default", auth: config.Auth{APIKeys: keys}, path: "/openapi.json", expectedStatus: fiber.StatusOK},
+		{name: "docs behind api keys", auth: config.Auth{APIKeys: keys, Groups: map[string][]string{config.RouteGroupDocs: {config.AuthMethodAPIKey}}}, path: "/docs", expectedStatus: fiber.StatusUnauthorized},
+		{name: "users opened explicitly", auth: config.Auth{APIKeys: keys, Groups: map[string][]string{config.RouteGroupUsers: {config.AuthMethodNone}}}, path: "/users", expectedStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			app := newApp(t, config.Config{Auth: tt.auth})
+
+			// Execute
+			req := httptest.NewRequest("GET", tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if resp.StatusCode == fiber.StatusUnauthorized {
+				assert.NotEmpty(t, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
+
+// TestNewApp_EveryUserRouteAuthenticates guards against a route registered
+// without the middleware of its group.
+func TestNewApp_EveryUserRouteAuthenticates(t *testing.T) {
+	app := newApp(t, config.Config{Auth: config.Auth{APIKeys: []config.APIKey{{Name: "ci", Hash: auth.HashAPIKey("ci-key")}}}})
+
+	for _, r := range app.GetRoutes(true) {
+		if !strings.HasPrefix(r.Path, "/users") {
+			continue
+		}
+		path := regexp.MustCompile(`:\w+`).ReplaceAllString(strings.ReplaceAll(r.Path, `\:`, ":"), "1")
+		if strings.HasSuffix(r.Path, "batchGet") {
+			path = "/users:batchGet"
+		}
+		resp, err := app.Test(httptest.NewRequest(r.Method, path, nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, "%s %s", r.Method, r.Path)
+	}
+}
+
+func TestNewApp_AuthConfigErrors(t *testing.T) {
+	keys := []config.APIKey{{Name: "ci", Hash: auth.HashAPIKey("ci-key")}}
+
+	tests := []struct {
+		name          string
+		auth          config.Auth
+		expectedError string
+	}{
+		{name: "no auth configured", expectedError: "route group users: no auth method configured"},
+		{name: "only the docs configured", auth: config.Auth{Groups: map[string][]string{config.RouteGroupDocs: {config.AuthMethodNone}}}, expectedError: "route group users: no auth method configured"},
+		{name: "users without methods", auth: config.Auth{Groups: map[string][]string{config.RouteGroupUsers: {}}}, expectedError: "route group users: no auth method configured"},
+		{name: "unknown group", auth: config.Auth{Groups: map[string][]string{"admin": {config.AuthMethodNone}}}, expectedError: `unknown route group "admin"`},
+		{name: "method not configured", auth: config.Auth{APIKeys: keys, Groups: map[string][]string{config.RouteGroupUsers: {config.AuthMethodJWT}}}, expectedError: `auth method "jwt" is not configured`},
+		{name: "none combined", auth: config.Auth{APIKeys: keys, Groups: map[string][]string{config.RouteGroupUsers: {config.AuthMethodNone, config.AuthMethodAPIKey}}}, expectedError: "cannot be combined"},
+		{name: "plain api key", auth: config.Auth{APIKeys: []config.APIKey{{Name: "ci", Hash: "ci-key"}}}, expectedError: "hash must be"},
+		{name: "missing jwt key file", auth: config.Auth{JWT: config.JWT{PublicKeyFile: "/nonexistent.pem"}}, expectedError: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container, err := service.NewInMemoryAppContainer(config.Config{})
+			require.NoError(t, err)
+
+			_, err = NewApp(config.Config{Auth: tt.auth}, container)
+
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
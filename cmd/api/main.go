@@ -1,21 +1,31 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	http_server "sika/api/http"
 	"sika/config"
 	"sika/pkg/load"
 	"sika/service"
+	"syscall"
 	"time"
 )
 
 var configPath = flag.String("config", "config.yaml", "configuration path")
 var inputFilePath = flag.String("file", "data/users_data.json", "json file path")
+var dbMode = flag.String("db", dbConfig, "database: config for the driver of the config file, postgres or sqlite, or memory")
 
 const importFlagFile = ".data_imported"
 
+const (
+	dbConfig = "config"
+	dbMemory = "memory"
+)
+
 func isDataImported() bool {
 	_, err := os.Stat(importFlagFile)
 	return err == nil
@@ -31,15 +41,32 @@ func markDataAsImported() error {
 
 func main() {
 	flag.Parse()
+	// SIGTERM stops the import between users, or drains in-flight requests,
+	// before the database is closed
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	cfg := readConfig()
-	app, err := service.NewAppContainer(cfg)
+	app, err := newAppContainer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// built before the import, so that a bad auth config stops the start
+	// before any data is written
+	fiberApp, err := http_server.NewApp(cfg, app)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := app.StartEventRelay(); err != nil {
+		log.Fatal(err)
+	}
 
-	if !isDataImported() {
+	// an in-memory database starts empty on every run, so the import flag
+	// file only applies to persistent storage
+	persistent := isPersistent(cfg.DB)
+	if !persistent || !isDataImported() {
 
-		if err := app.UserService().ClearUserAndAddressDataFromDB(); err != nil {
+		if err := app.UserService().ClearUserAndAddressDataFromDB(ctx); err != nil {
 			log.Fatalf("Error clearing existing data: %v", err)
 		}
 		start := time.Now()
@@ -47,21 +74,52 @@ func main() {
 		if err != nil {
 			log.Printf("Error loading data: %v", err)
 		} else {
-			err = app.UserService().ImportUsers(userData)
+			err = app.UserService().ImportUsers(ctx, userData)
 			if err != nil {
 				log.Printf("Error importing users: %v", err)
 			} else {
-				if err := markDataAsImported(); err != nil {
-					log.Printf("Warning: Could not mark data as imported: %v", err)
+				if persistent {
+					if err := markDataAsImported(); err != nil {
+						log.Printf("Warning: Could not mark data as imported: %v", err)
+					}
 				}
+				log.Printf("it took %s to import data successfully", time.Since(start))
 			}
-			log.Printf("it took %s to import data successfully", time.Since(start))
 		}
 	} else {
 		log.Println("data already imported, if you need to import again, please delete the file .data_imported from the root directory and run the program again")
 	}
 
-	http_server.Run(cfg, app)
+	if ctx.Err() == nil {
+		err = http_server.Run(ctx, cfg.Server, fiberApp)
+	}
+	if closeErr := app.Close(); closeErr != nil {
+		log.Printf("closing app failed: %v", closeErr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("server stopped")
+}
+
+func newAppContainer(cfg config.Config) (*service.AppContainer, error) {
+	switch *dbMode {
+	case dbConfig:
+		return service.NewAppContainer(cfg)
+	case dbMemory:
+		return service.NewInMemoryAppContainer(cfg)
+	default:
+		return nil, fmt.Errorf("unknown db %q, expected %s or %s", *dbMode, dbConfig, dbMemory)
+	}
+}
+
+// isPersistent reports whether the data outlives the process, which is not
+// the case of the in-memory store nor of a SQLite database in memory.
+func isPersistent(db config.DB) bool {
+	if *dbMode == dbMemory {
+		return false
+	}
+	return db.Driver != config.DriverSQLite || db.Path != ":memory:"
 }
 
 func readConfig() config.Config {
@@ -81,4 +139,4 @@ func readConfig() config.Config {
 	}
 
 	return cfg
-}
\ No newline at end of file
+}
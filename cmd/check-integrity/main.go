@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"sika/config"
+	"sika/service"
+)
+
+var configPath = flag.String("config", "config.yaml", "configuration path")
+var repair = flag.Bool("repair", false, "delete addresses whose user does not exist")
+
+func main() {
+	flag.Parse()
+	cfg := readConfig()
+	app, err := service.NewAppContainer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	orphans, deleted, err := app.UserService().CheckOrphanAddresses(context.Background(), *repair)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, a := range orphans {
+		log.Printf("orphan address %d references missing user %q", a.ID, a.UserID)
+	}
+	log.Printf("found %d orphan addresses", len(orphans))
+
+	if *repair {
+		log.Printf("deleted %d orphan addresses", deleted)
+		return
+	}
+	if len(orphans) > 0 {
+		os.Exit(1)
+	}
+}
+
+func readConfig() config.Config {
+
+	if cfgPathEnv := os.Getenv("APP_CONFIG_PATH"); len(cfgPathEnv) > 0 {
+		*configPath = cfgPathEnv
+	}
+
+	if len(*configPath) == 0 {
+		log.Fatal("configuration file not found")
+	}
+
+	cfg, err := config.ReadStandard(*configPath)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cfg
+}
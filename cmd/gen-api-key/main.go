@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sika/pkg/auth"
+)
+
+var name = flag.String("name", "", "name of the key holder, recorded as the actor in the audit log")
+
+// main prints a new API key, to hand to its holder, and the config entry
+// holding its hash.
+func main() {
+	flag.Parse()
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("key: %s\n\n", key)
+	fmt.Printf("auth:\n  api_keys:\n    - name: %q\n      hash: %q\n", *name, auth.HashAPIKey(key))
+}
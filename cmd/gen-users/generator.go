@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sika/pkg/load"
+	"strconv"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// distribution picks how many addresses a generated user gets.
+type distribution struct {
+	counts  []int
+	weights []int
+	total   int
+}
+
+// parseDistribution accepts either a uniform range such as "1-5" or a
+// weighted list of count:weight pairs such as "0:5,1:60,2:25,3:10".
+func parseDistribution(spec string) (*distribution, error) {
+	d := &distribution{}
+	if !strings.Contains(spec, ":") {
+		lo, hi, found := strings.Cut(spec, "-")
+		if !found {
+			hi = lo
+		}
+		min, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid address distribution %q: %w", spec, err)
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil {
+			return nil, fmt.Errorf("invalid address distribution %q: %w", spec, err)
+		}
+		if min < 0 || max < min {
+			return nil, fmt.Errorf("invalid address distribution %q: range must be non-negative and ascending", spec)
+		}
+		for n := min; n <= max; n++ {
+			d.counts = append(d.counts, n)
+			d.weights = append(d.weights, 1)
+		}
+		d.total = max - min + 1
+		return d, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		c, w, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid address distribution %q: expected count:weight pairs", spec)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid address count %q in distribution", c)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q in distribution", w)
+		}
+		d.counts = append(d.counts, count)
+		d.weights = append(d.weights, weight)
+		d.total += weight
+	}
+	if d.total == 0 {
+		return nil, fmt.Errorf("invalid address distribution %q: weights sum to zero", spec)
+	}
+	return d, nil
+}
+
+func (d *distribution) pick(f *gofakeit.Faker) int {
+	n := f.Rand.Intn(d.total)
+	for i, w := range d.weights {
+		if n < w {
+			return d.counts[i]
+		}
+		n -= w
+	}
+	return d.counts[len(d.counts)-1]
+}
+
+// generator produces fake users, optionally corrupting or duplicating a
+// share of them so the importer's error handling can be exercised.
+type generator struct {
+	faker        *gofakeit.Faker
+	addresses    *distribution
+	invalidPct   float64
+	duplicatePct float64
+
+	// recentIDs is a bounded pool of already emitted IDs to draw duplicates
+	// from without keeping every ID of a multi-million user run in memory.
+	recentIDs []string
+	next      int
+}
+
+const recentIDPoolSize = 1024
+
+func newGenerator(seed int64, addresses *distribution, invalidPct, duplicatePct float64) *generator {
+	return &generator{
+		faker:        gofakeit.NewUnlocked(seed),
+		addresses:    addresses,
+		invalidPct:   invalidPct,
+		duplicatePct: duplicatePct,
+	}
+}
+
+func (g *generator) user() load.User {
+	numAddresses := g.addresses.pick(g.faker)
+	addresses := make([]load.Address, numAddresses)
+	for j := range addresses {
+		addresses[j] = load.Address{
+			Street:  g.faker.Street(),
+			City:    g.faker.City(),
+			State:   g.faker.State(),
+			ZipCode: g.faker.Zip(),
+			Country: g.faker.Country(),
+		}
+	}
+
+	u := load.User{
+		ID:          g.faker.UUID(),
+		Name:        g.faker.Name(),
+		Email:       g.faker.Email(),
+		PhoneNumber: g.faker.Phone(),
+		Addresses:   addresses,
+	}
+
+	switch {
+	case len(g.recentIDs) > 0 && g.roll(g.duplicatePct):
+		u.ID = g.recentIDs[g.faker.Rand.Intn(len(g.recentIDs))]
+	case g.roll(g.invalidPct):
+		g.corrupt(&u)
+	default:
+		g.remember(u.ID)
+	}
+	return u
+}
+
+func (g *generator) roll(pct float64) bool {
+	return pct > 0 && g.faker.Rand.Float64()*100 < pct
+}
+
+func (g *generator) remember(id string) {
+	if len(g.recentIDs) < recentIDPoolSize {
+		g.recentIDs = append(g.recentIDs, id)
+		return
+	}
+	g.recentIDs[g.next] = id
+	g.next = (g.next + 1) % recentIDPoolSize
+}
+
+// corrupt breaks one field of u in a way the importer should reject.
+func (g *generator) corrupt(u *load.User) {
+	switch g.faker.Rand.Intn(4) {
+	case 0:
+		u.ID = ""
+	case 1:
+		u.Email = strings.ReplaceAll(u.Email, "@", "")
+	case 2:
+		u.Name = ""
+	default:
+		u.PhoneNumber = g.faker.LetterN(10)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"sika/pkg/load"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		wantCounts  []int
+		wantWeights []int
+		wantErr     bool
+	}{
+		{
+			name:        "uniform range",
+			spec:        "1-3",
+			wantCounts:  []int{1, 2, 3},
+			wantWeights: []int{1, 1, 1},
+		},
+		{
+			name:        "fixed count",
+			spec:        "2",
+			wantCounts:  []int{2},
+			wantWeights: []int{1},
+		},
+		{
+			name:        "weighted pairs",
+			spec:        "0:5,1:60,2:35",
+			wantCounts:  []int{0, 1, 2},
+			wantWeights: []int{5, 60, 35},
+		},
+		{
+			name:    "descending range",
+			spec:    "5-1",
+			wantErr: true,
+		},
+		{
+			name:    "zero weights",
+			spec:    "1:0,2:0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := parseDistribution(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCounts, d.counts)
+			assert.Equal(t, tt.wantWeights, d.weights)
+		})
+	}
+}
+
+func TestGenerator_SeedIsDeterministic(t *testing.T) {
+	dist, err := parseDistribution("1-5")
+	require.NoError(t, err)
+
+	a := newGenerator(42, dist, 0, 0)
+	b := newGenerator(42, dist, 0, 0)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, a.user(), b.user())
+	}
+}
+
+func TestGenerator_InjectsDuplicates(t *testing.T) {
+	dist, err := parseDistribution("1")
+	require.NoError(t, err)
+
+	gen := newGenerator(7, dist, 0, 50)
+	seen := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		seen[gen.user().ID]++
+	}
+	assert.Less(t, len(seen), 200)
+}
+
+func TestJSONWriter_OutputIsLoadable(t *testing.T) {
+	dist, err := parseDistribution("0-2")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := newUserWriter("json", &buf)
+	require.NoError(t, err)
+
+	gen := newGenerator(1, dist, 0, 0)
+	var want []load.User
+	for i := 0; i < 5; i++ {
+		u := gen.user()
+		want = append(want, u)
+		require.NoError(t, w.Write(u))
+	}
+	require.NoError(t, w.Close())
+
+	var got []load.User
+	err = load.Stream(&buf, func(u load.User) error {
+		got = append(got, u)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, len(want), len(got))
+	for i := range want {
+		assert.Equal(t, want[i].ID, got[i].ID)
+		assert.Equal(t, len(want[i].Addresses), len(got[i].Addresses))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+var count = flag.Int("count", 10000, "number of users to generate")
+var seed = flag.Int64("seed", 0, "random seed, 0 picks a random one")
+var addressesSpec = flag.String("addresses", "1-5", "addresses per user, either a range like 1-5 or weighted count:weight pairs like 0:5,1:60,2:35")
+var format = flag.String("format", "json", "output format: json, ndjson or csv")
+var outputPath = flag.String("out", "data/users_data.json", "output file path, - for stdout")
+var invalidPct = flag.Float64("invalid-pct", 0, "percentage of users with an invalid field")
+var duplicatePct = flag.Float64("duplicate-pct", 0, "percentage of users reusing an earlier user's ID")
+
+func main() {
+	flag.Parse()
+
+	addresses, err := parseDistribution(*addressesSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "-" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w, err := newUserWriter(*format, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start := time.Now()
+	gen := newGenerator(*seed, addresses, *invalidPct, *duplicatePct)
+	for i := 0; i < *count; i++ {
+		if err := w.Write(gen.user()); err != nil {
+			log.Fatalf("Error writing user: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
+
+	log.Printf("generated %d users in %s", *count, time.Since(start))
+}
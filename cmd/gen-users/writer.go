@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sika/pkg/load"
+)
+
+// userWriter streams users to the output one at a time.
+type userWriter interface {
+	Write(u load.User) error
+	Close() error
+}
+
+func newUserWriter(format string, w io.Writer) (userWriter, error) {
+	buf := bufio.NewWriterSize(w, 1<<20)
+	switch format {
+	case "json":
+		return &jsonWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case "ndjson":
+		return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case "csv":
+		return &csvWriter{buf: buf, w: csv.NewWriter(buf)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected json, ndjson or csv", format)
+	}
+}
+
+// jsonWriter writes a single JSON array that load.LoadData can read, without
+// building the array in memory first.
+type jsonWriter struct {
+	buf   *bufio.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func (w *jsonWriter) Write(u load.User) error {
+	sep := ",\n"
+	if w.count == 0 {
+		sep = "[\n"
+	}
+	w.count++
+	if _, err := w.buf.WriteString(sep); err != nil {
+		return err
+	}
+	return w.enc.Encode(u)
+}
+
+func (w *jsonWriter) Close() error {
+	end := "]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := w.buf.WriteString(end); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(u load.User) error {
+	return w.enc.Encode(u)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.buf.Flush()
+}
+
+// csvWriter writes one row per address, repeating the user columns. Users
+// without addresses get a single row with empty address columns.
+type csvWriter struct {
+	buf           *bufio.Writer
+	w             *csv.Writer
+	headerWritten bool
+}
+
+var csvHeader = []string{"id", "name", "email", "phone_number", "street", "city", "state", "zip_code", "country"}
+
+func (w *csvWriter) Write(u load.User) error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	if len(u.Addresses) == 0 {
+		return w.w.Write([]string{u.ID, u.Name, u.Email, u.PhoneNumber, "", "", "", "", ""})
+	}
+	for _, a := range u.Addresses {
+		err := w.w.Write([]string{u.ID, u.Name, u.Email, u.PhoneNumber, a.Street, a.City, a.State, a.ZipCode, a.Country})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sika/config"
+	"sika/pkg/storage"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var configPath = flag.String("config", "config.yaml", "configuration path")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [--config config.yaml] up | down [steps] | status\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := readConfig()
+	db, err := storage.NewGormConnection(cfg.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		if err := storage.MigrateUp(db); err != nil {
+			log.Fatal(err)
+		}
+		printStatus(db)
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps %q", flag.Arg(1))
+			}
+		}
+		if err := storage.MigrateDown(db, steps); err != nil {
+			log.Fatal(err)
+		}
+		printStatus(db)
+	case "status":
+		printStatus(db)
+		if err := storage.CheckPendingMigrations(db); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func printStatus(db *gorm.DB) {
+	statuses, err := storage.MigrationStatuses(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, applied)
+	}
+}
+
+func readConfig() config.Config {
+
+	if cfgPathEnv := os.Getenv("APP_CONFIG_PATH"); len(cfgPathEnv) > 0 {
+		*configPath = cfgPathEnv
+	}
+
+	if len(*configPath) == 0 {
+		log.Fatal("configuration file not found")
+	}
+
+	cfg, err := config.ReadStandard(*configPath)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cfg
+}
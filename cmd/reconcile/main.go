@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sika/config"
+	"sika/service"
+)
+
+var configPath = flag.String("config", "config.yaml", "configuration path")
+var inputFilePath = flag.String("file", "data/users_data.json", "json file path")
+var maxDrift = flag.Float64("max-drift", 0, "maximum allowed percentage of drifted users before exiting non-zero")
+var verbose = flag.Bool("v", false, "print the full report as JSON")
+
+func main() {
+	flag.Parse()
+	cfg := readConfig()
+	app, err := service.NewAppContainer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := app.UserService().Reconcile(context.Background(), *inputFilePath)
+	if err != nil {
+		log.Fatalf("Error reconciling data: %v", err)
+	}
+
+	if *verbose {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	drift := report.DriftRatio() * 100
+	log.Printf("checked %d users: %d missing, %d extra, %d field mismatches, %d address count differences (%.2f%% drift)",
+		report.Checked, len(report.Missing), len(report.Extra), len(report.Mismatches), len(report.AddressCountDiffs), drift)
+
+	if drift > *maxDrift {
+		log.Printf("drift %.2f%% is above the allowed %.2f%%", drift, *maxDrift)
+		os.Exit(1)
+	}
+}
+
+func readConfig() config.Config {
+
+	if cfgPathEnv := os.Getenv("APP_CONFIG_PATH"); len(cfgPathEnv) > 0 {
+		*configPath = cfgPathEnv
+	}
+
+	if len(*configPath) == 0 {
+		log.Fatal("configuration file not found")
+	}
+
+	cfg, err := config.ReadStandard(*configPath)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cfg
+}
@@ -1,19 +1,145 @@
 package config
 
+import "time"
+
 type Config struct {
 	Server Server `mapstructure:"server"`
 	DB     DB     `mapstructure:"db"`
+	Events Events `mapstructure:"events"`
+	Auth   Auth   `mapstructure:"auth"`
 }
 
 type Server struct {
-	HTTPPort               int    `mapstructure:"http_port"`
-	Host                   string `mapstructure:"host"`
+	HTTPPort int    `mapstructure:"http_port"`
+	Host     string `mapstructure:"host"`
+
+	// Timeouts of a connection, zero keeps the defaults of 15s to read a
+	// request, 30s to write a response and 60s for an idle keep-alive
+	// connection.
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// on shutdown, 15s when zero.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// BodyLimit is the largest request body in bytes, 4 MiB when zero.
+	BodyLimit int `mapstructure:"body_limit"`
 }
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+const (
+	RepositoryGorm = "gorm"
+	RepositoryPgx  = "pgx"
+)
+
 type DB struct {
+	// Driver selects the database, DriverPostgres (the default) or DriverSQLite.
+	Driver string `mapstructure:"driver"`
 	User   string `mapstructure:"user"`
 	Pass   string `mapstructure:"pass"`
 	Host   string `mapstructure:"host"`
 	Port   int    `mapstructure:"port"`
 	DBName string `mapstructure:"db_name"`
-}
\ No newline at end of file
+	// Path is the SQLite database file, or ":memory:" for a throwaway database.
+	Path string `mapstructure:"path"`
+	// Repository selects the repo implementation, RepositoryGorm (the default)
+	// or RepositoryPgx, which talks to Postgres through pgx without GORM.
+	Repository string `mapstructure:"repository"`
+
+	// DSN is a complete Postgres connection string, either key=value pairs or
+	// a postgres:// URL. When set it replaces the connection fields above and
+	// the SSL, timeout and application name options below.
+	DSN              string        `mapstructure:"dsn"`
+	SSLMode          string        `mapstructure:"sslmode"`
+	SSLRootCert      string        `mapstructure:"sslrootcert"`
+	ConnectTimeout   time.Duration `mapstructure:"connect_timeout"`
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+	ApplicationName  string        `mapstructure:"application_name"`
+
+	// Replicas are read replicas of the database above, as Postgres DSNs or
+	// SQLite file paths. Reads are spread over the healthy replicas, writes
+	// and imports stay on the primary.
+	Replicas []string `mapstructure:"replicas"`
+	// ReplicaHealthCheckInterval is how often replicas are pinged, 10s when zero.
+	ReplicaHealthCheckInterval time.Duration `mapstructure:"replica_health_check_interval"`
+
+	// Pool settings for the shared sql.DB, zero keeps the database/sql default.
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+}
+
+const (
+	EventSinkInProcess = "inprocess"
+	EventSinkFile      = "file"
+)
+
+type Events struct {
+	// Sink selects where the outbox relay delivers events, EventSinkInProcess
+	// or EventSinkFile. Without a sink no relay runs and events stay in the
+	// outbox.
+	Sink string `mapstructure:"sink"`
+	// FilePath is the NDJSON file written by the EventSinkFile sink.
+	FilePath string `mapstructure:"file_path"`
+	// RelayInterval is how often the outbox is polled, 1s when zero.
+	RelayInterval time.Duration `mapstructure:"relay_interval"`
+	// BatchSize is the number of events read per poll, 100 when zero.
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+const (
+	AuthMethodNone   = "none"
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+const (
+	RouteGroupUsers = "users"
+	RouteGroupDocs  = "docs"
+)
+
+type Auth struct {
+	// APIKeys are the static keys accepted by AuthMethodAPIKey.
+	APIKeys []APIKey `mapstructure:"api_keys"`
+	// JWT configures the bearer tokens accepted by AuthMethodJWT.
+	JWT JWT `mapstructure:"jwt"`
+	// Groups maps a route group, RouteGroupUsers or RouteGroupDocs, to the
+	// methods it accepts. A group not listed accepts every configured method
+	// for RouteGroupUsers and AuthMethodNone for RouteGroupDocs. With no
+	// method configured, RouteGroupUsers must be listed with AuthMethodNone
+	// to accept unauthenticated requests, else the API does not start.
+	Groups map[string][]string `mapstructure:"groups"`
+}
+
+type APIKey struct {
+	// Name identifies the holder of the key, it becomes the principal.
+	Name string `mapstructure:"name"`
+	// Hash is the key hashed as "sha256:" followed by the hex SHA-256 of the
+	// key, so the config never holds the key itself.
+	Hash string `mapstructure:"hash"`
+}
+
+type JWT struct {
+	// HMACSecret enables HS256 tokens signed with it.
+	HMACSecret string `mapstructure:"hmac_secret"`
+	// PublicKeyFile is a PEM RSA public key and enables RS256 tokens.
+	PublicKeyFile string `mapstructure:"public_key_file"`
+	// JWKSFile is a JSON Web Key Set of RSA and HMAC keys, chosen by the kid
+	// header of a token.
+	JWKSFile string `mapstructure:"jwks_file"`
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+	// Leeway is the clock skew allowed for exp and nbf.
+	Leeway time.Duration `mapstructure:"leeway"`
+}
+
+// Enabled reports whether any JWT key is configured.
+func (j JWT) Enabled() bool {
+	return j.HMACSecret != "" || j.PublicKeyFile != "" || j.JWKSFile != ""
+}
@@ -1,5 +0,0 @@
-module data
-
-go 1.24.3
-
-require github.com/brianvoe/gofakeit/v6 v6.28.0
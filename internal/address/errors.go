@@ -0,0 +1,35 @@
+package address
+
+import (
+	"errors"
+	"sika/internal/user"
+)
+
+// The errors of the address domain. The repos translate database errors into
+// them. A write to the address of a missing user fails with user.ErrNotFound.
+var (
+	// ErrNotFound is returned when an address does not exist or belongs to
+	// another user than the one it was looked up through.
+	ErrNotFound = errors.New("address not found")
+	// ErrConflict is returned when a write clashes with a stored address.
+	ErrConflict = errors.New("address conflicts with a stored address")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("invalid address")
+	// ErrUnavailable is returned when the database cannot be reached or does
+	// not answer in time. The request may succeed when retried.
+	ErrUnavailable = errors.New("address storage is unavailable")
+)
+
+// ValidationError lists every invalid field of one or more addresses. It
+// matches ErrValidation.
+type ValidationError struct {
+	Errors []user.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return ErrValidation.Error() + ": " + user.JoinFieldErrors(e.Errors)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -25,6 +25,33 @@ func (o *Ops) GetAddressByUserID(ctx context.Context, uid string) ([]entities.Ad
 	return o.repo.GetAddressByUser(ctx, uid)
 }
 
+// GetAddress returns the address id of the user, ErrNotFound when it does not
+// exist or belongs to another user.
+func (o *Ops) GetAddress(ctx context.Context, uid string, id int) (*entities.Address, error) {
+	return o.repo.GetAddress(ctx, uid, id)
+}
+
+func (o *Ops) UpdateAddress(ctx context.Context, a *entities.Address) error {
+	return o.repo.UpdateAddress(ctx, a)
+}
+
+func (o *Ops) DeleteAddress(ctx context.Context, uid string, id int) error {
+	return o.repo.DeleteAddress(ctx, uid, id)
+}
+
+func (o *Ops) ReplaceAddresses(ctx context.Context, uid string, addrs []entities.Address) error {
+	return o.repo.ReplaceAddresses(ctx, uid, addrs)
+}
+
+// GetOrphanAddresses returns addresses whose user does not exist.
+func (o *Ops) GetOrphanAddresses(ctx context.Context) ([]entities.Address, error) {
+	return o.repo.GetOrphanAddresses(ctx)
+}
+
+func (o *Ops) DeleteOrphanAddresses(ctx context.Context) (int64, error) {
+	return o.repo.DeleteOrphanAddresses(ctx)
+}
+
 func (o *Ops) ClearAllAddressesDataFromDB() error {
 	return o.repo.ClearAllAddressesDataFromDB()
 }
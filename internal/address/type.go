@@ -5,9 +5,22 @@ import (
 	"sika/pkg/storage/entities"
 )
 
+// Repo stores addresses. Its errors are those of errors.go, wrapping the
+// error of the database.
 type Repo interface {
-	CreateAddress(ctx context.Context, a *entities.Address)error
-	CreateBatchAddresses(ctx context.Context, adds []entities.Address)error
-	GetAddressByUser(ctx context.Context, userID string)([]entities.Address, error)
-	ClearAllAddressesDataFromDB()error
+	CreateAddress(ctx context.Context, a *entities.Address) error
+	CreateBatchAddresses(ctx context.Context, adds []entities.Address) error
+	GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error)
+	// GetAddress returns the address only when it belongs to the user.
+	GetAddress(ctx context.Context, userID string, id int) (*entities.Address, error)
+	// UpdateAddress overwrites the address a.ID of the user a.UserID.
+	UpdateAddress(ctx context.Context, a *entities.Address) error
+	DeleteAddress(ctx context.Context, userID string, id int) error
+	// ReplaceAddresses makes adds the user's whole address set in one
+	// transaction. Addresses with an ID are updated and must belong to the
+	// user, the others are inserted and the missing ones deleted.
+	ReplaceAddresses(ctx context.Context, userID string, adds []entities.Address) error
+	GetOrphanAddresses(ctx context.Context) ([]entities.Address, error)
+	DeleteOrphanAddresses(ctx context.Context) (int64, error)
+	ClearAllAddressesDataFromDB() error
 }
@@ -0,0 +1,56 @@
+package address
+
+import (
+	"fmt"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"strings"
+)
+
+const maxFieldLength = 255
+
+// Normalize trims surrounding spaces from the fields of a that are written
+// through the API.
+func Normalize(a *entities.Address) {
+	a.Street = strings.TrimSpace(a.Street)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.TrimSpace(a.State)
+	a.ZipCode = strings.TrimSpace(a.ZipCode)
+	a.Country = strings.TrimSpace(a.Country)
+}
+
+// Validate checks an address written through the API and returns its
+// problems with field names prefixed by prefix, such as "addresses[0].". Every
+// field is optional but an address needs at least one.
+func Validate(a entities.Address, prefix string) []user.FieldError {
+	var errs []user.FieldError
+	fields := []struct {
+		name, value string
+	}{
+		{"street", a.Street},
+		{"city", a.City},
+		{"state", a.State},
+		{"zip_code", a.ZipCode},
+		{"country", a.Country},
+	}
+	empty := true
+	for _, f := range fields {
+		if f.value != "" {
+			empty = false
+		}
+		if len(f.value) > maxFieldLength {
+			errs = append(errs, user.FieldError{
+				Field:   prefix + f.name,
+				Message: fmt.Sprintf("must be at most %d characters", maxFieldLength),
+			})
+		}
+	}
+	if empty {
+		field := strings.TrimSuffix(prefix, ".")
+		if field == "" {
+			field = "address"
+		}
+		errs = append(errs, user.FieldError{Field: field, Message: "must have at least one of street, city, state, zip_code or country"})
+	}
+	return errs
+}
@@ -0,0 +1,36 @@
+package audit
+
+import "context"
+
+// SystemActor is recorded for actions not made on behalf of a caller, such as
+// imports.
+const SystemActor = "system"
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+// WithActor returns ctx carrying the actor recorded for audited actions.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor set by WithActor, or SystemActor.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+// WithRequestID returns ctx carrying the request ID recorded for audited
+// actions.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID set by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
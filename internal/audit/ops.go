@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"context"
+	"sika/pkg/storage/entities"
+)
+
+type Ops struct {
+	repo Repo
+}
+
+func NewOps(repo Repo) *Ops {
+	return &Ops{repo}
+}
+
+// Record appends an entry for action on userID, taking the actor and request
+// ID from ctx.
+func (o *Ops) Record(ctx context.Context, action, userID string, changedFields ...string) error {
+	return o.repo.AppendEntry(ctx, &entities.AuditEntry{
+		Actor:         Actor(ctx),
+		Action:        action,
+		UserID:        userID,
+		RequestID:     RequestID(ctx),
+		ChangedFields: changedFields,
+	})
+}
+
+// RecordAll appends one entry for action on each of userIDs in a single
+// write, for reads returning many users.
+func (o *Ops) RecordAll(ctx context.Context, action string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	actor, requestID := Actor(ctx), RequestID(ctx)
+	entries := make([]entities.AuditEntry, len(userIDs))
+	for i, id := range userIDs {
+		entries[i] = entities.AuditEntry{Actor: actor, Action: action, UserID: id, RequestID: requestID}
+	}
+	return o.repo.AppendEntries(ctx, entries)
+}
+
+// GetEntriesByUser returns the entries for userID, oldest first.
+func (o *Ops) GetEntriesByUser(ctx context.Context, userID string) ([]entities.AuditEntry, error) {
+	return o.repo.GetEntriesByUser(ctx, userID)
+}
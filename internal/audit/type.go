@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"context"
+	"sika/pkg/storage/entities"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionView    = "view"
+	ActionDelete  = "delete"
+	ActionPurge   = "purge"
+	ActionRestore = "restore"
+)
+
+type Repo interface {
+	AppendEntry(ctx context.Context, entry *entities.AuditEntry) error
+	// AppendEntries appends entries in a single statement. The IDs of the
+	// entries are not set.
+	AppendEntries(ctx context.Context, entries []entities.AuditEntry) error
+	GetEntriesByUser(ctx context.Context, userID string) ([]entities.AuditEntry, error)
+}
@@ -0,0 +1,86 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"sika/pkg/storage/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Ops struct {
+	repo Repo
+}
+
+func NewOps(repo Repo) *Ops {
+	return &Ops{repo}
+}
+
+// RecordVersion stores u as the user's version from at on. Nothing is
+// stored when u matches the current version, so re-importing unchanged data
+// does not grow the history. It must run in the transaction that wrote u:
+// reading the current version locks the user, so concurrent writes append
+// their versions in the order they were applied.
+func (o *Ops) RecordVersion(ctx context.Context, u *entities.User, at time.Time) error {
+	v := &entities.UserVersion{
+		UserID:      u.ID,
+		Name:        u.Name,
+		Email:       u.Email,
+		PhoneNumber: u.PhoneNumber,
+		Addresses:   u.Addresses,
+		ValidFrom:   at,
+	}
+	if u.DeletedAt.Valid {
+		deletedAt := u.DeletedAt.Time
+		v.DeletedAt = &deletedAt
+	}
+
+	current, err := o.repo.GetCurrentVersion(ctx, u.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if current != nil && sameContent(current, v) {
+		return nil
+	}
+	return o.repo.AppendVersion(ctx, v)
+}
+
+// GetVersions returns all versions of the user, oldest first.
+func (o *Ops) GetVersions(ctx context.Context, userID string) ([]entities.UserVersion, error) {
+	return o.repo.GetVersions(ctx, userID)
+}
+
+// GetVersionAt returns the version valid at at, gorm.ErrRecordNotFound when
+// the user did not exist yet.
+func (o *Ops) GetVersionAt(ctx context.Context, userID string, at time.Time) (*entities.UserVersion, error) {
+	return o.repo.GetVersionAt(ctx, userID, at)
+}
+
+// DeleteVersions erases the history of the user.
+func (o *Ops) DeleteVersions(ctx context.Context, userID string) error {
+	return o.repo.DeleteVersions(ctx, userID)
+}
+
+// CloseVersions ends the current version of every user at at, for users
+// removed all at once.
+func (o *Ops) CloseVersions(ctx context.Context, at time.Time) error {
+	return o.repo.CloseVersions(ctx, at)
+}
+
+// sameContent compares the held data of two versions, ignoring timestamps
+// that change on every write.
+func sameContent(a, b *entities.UserVersion) bool {
+	if a.Name != b.Name || a.Email != b.Email || a.PhoneNumber != b.PhoneNumber ||
+		(a.DeletedAt == nil) != (b.DeletedAt == nil) || len(a.Addresses) != len(b.Addresses) {
+		return false
+	}
+	for i := range a.Addresses {
+		x, y := a.Addresses[i], b.Addresses[i]
+		if x.ID != y.ID || x.Street != y.Street || x.City != y.City || x.State != y.State ||
+			x.ZipCode != y.ZipCode || x.Country != y.Country {
+			return false
+		}
+	}
+	return true
+}
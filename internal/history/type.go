@@ -0,0 +1,21 @@
+package history
+
+import (
+	"context"
+	"sika/pkg/storage/entities"
+	"time"
+)
+
+type Repo interface {
+	// AppendVersion closes the current version of v.UserID at v.ValidFrom
+	// and stores v as the next version.
+	AppendVersion(ctx context.Context, v *entities.UserVersion) error
+	// GetCurrentVersion returns the version without ValidTo and locks the
+	// user until the end of the transaction of ctx.
+	GetCurrentVersion(ctx context.Context, userID string) (*entities.UserVersion, error)
+	GetVersions(ctx context.Context, userID string) ([]entities.UserVersion, error)
+	GetVersionAt(ctx context.Context, userID string, at time.Time) (*entities.UserVersion, error)
+	DeleteVersions(ctx context.Context, userID string) error
+	// CloseVersions sets ValidTo of the current version of every user to at.
+	CloseVersions(ctx context.Context, at time.Time) error
+}
@@ -0,0 +1,26 @@
+package outbox
+
+import (
+	"encoding/json"
+	"sika/pkg/storage/entities"
+)
+
+// NewEvent returns an outbox row for eventType on userID with payload
+// encoded as JSON, for repos to insert along with the change.
+func NewEvent(eventType, userID string, payload any) (entities.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return entities.OutboxEvent{}, err
+	}
+	return entities.OutboxEvent{
+		EventType: eventType,
+		UserID:    userID,
+		Payload:   string(data),
+	}, nil
+}
+
+// IDPayload is the payload of events that only identify the user, such as
+// deletes.
+type IDPayload struct {
+	ID string `json:"id"`
+}
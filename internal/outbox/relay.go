@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sika/pkg/storage/entities"
+	"time"
+)
+
+// Relay moves events from the outbox to an EventPublisher. Events of a user
+// are published in sequence order, and an event that fails to publish holds
+// back the later events of its user until it succeeds. Only one relay may run
+// against a database, two would race on the order.
+type Relay struct {
+	repo      Repo
+	publisher EventPublisher
+	batchSize int
+}
+
+func NewRelay(repo Repo, publisher EventPublisher, batchSize int) *Relay {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		batchSize: batchSize,
+	}
+}
+
+// RelayOnce publishes pending events and removes the published ones from
+// the outbox. It returns how many events were published.
+//
+// A user whose event fails to publish is skipped by the reads that follow,
+// so that its held back events do not fill the batch. While a full batch
+// blocks a user, the next batch is read right away, so that other users'
+// events get through even when one user's events fill the batch.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	var blocked []string
+	published := 0
+	for {
+		pending, err := r.repo.GetPendingEvents(ctx, r.batchSize, blocked)
+		if err != nil {
+			return published, err
+		}
+
+		var sequences []int64
+		newlyBlocked := false
+		skip := make(map[string]struct{})
+		for _, e := range pending {
+			if _, ok := skip[e.UserID]; ok {
+				continue
+			}
+			if err := r.publisher.Publish(ctx, toEvent(e)); err != nil {
+				log.Printf("publishing outbox event %d of user %s failed: %v", e.Sequence, e.UserID, err)
+				skip[e.UserID] = struct{}{}
+				blocked = append(blocked, e.UserID)
+				newlyBlocked = true
+				continue
+			}
+			sequences = append(sequences, e.Sequence)
+		}
+
+		if len(sequences) > 0 {
+			if err := r.repo.DeleteEvents(ctx, sequences); err != nil {
+				return published, err
+			}
+			published += len(sequences)
+		}
+		if len(pending) < r.batchSize || !newlyBlocked {
+			return published, nil
+		}
+	}
+}
+
+// Run relays events every interval until ctx is done. When a full batch was
+// published the next one follows right away, otherwise, and in particular
+// when nothing could be published, it waits for the interval.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("relaying outbox events failed: %v", err)
+		}
+		if err == nil && n >= r.batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(interval)
+		}
+	}
+}
+
+func toEvent(e entities.OutboxEvent) Event {
+	return Event{
+		Sequence:  e.Sequence,
+		Type:      e.EventType,
+		UserID:    e.UserID,
+		Payload:   json.RawMessage(e.Payload),
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,123 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sika/internal/outbox"
+	"sika/pkg/storage/entities"
+	"sika/pkg/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	published []outbox.Event
+	failUser  string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event outbox.Event) error {
+	if event.UserID == p.failUser {
+		return errors.New("sink unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepo(store)
+	outboxRepo := memory.NewOutboxRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2"}))
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	require.NoError(t, userRepo.DeleteUser(ctx, "2", 0))
+
+	publisher := &recordingPublisher{failUser: "1"}
+	relay := outbox.NewRelay(outboxRepo, publisher, 10)
+
+	// Execute: user 1 cannot be delivered
+	n, err := relay.RelayOnce(ctx)
+
+	// Assert: user 2 is delivered in order, user 1 stays pending
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Len(t, publisher.published, 2)
+	assert.Equal(t, outbox.EventUserCreated, publisher.published[0].Type)
+	assert.Equal(t, outbox.EventUserDeleted, publisher.published[1].Type)
+	assert.JSONEq(t, `{"id":"2"}`, string(publisher.published[1].Payload))
+
+	pending, err := outboxRepo.GetPendingEvents(ctx, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "1", pending[0].UserID)
+
+	// Execute: the sink recovers
+	publisher.failUser = ""
+	publisher.published = nil
+	_, err = relay.RelayOnce(ctx)
+
+	// Assert: user 1 is delivered in order and the outbox is empty
+	require.NoError(t, err)
+	require.Len(t, publisher.published, 2)
+	assert.Equal(t, outbox.EventUserCreated, publisher.published[0].Type)
+	assert.Equal(t, outbox.EventUserDeleted, publisher.published[1].Type)
+	assert.Less(t, publisher.published[0].Sequence, publisher.published[1].Sequence)
+
+	pending, err = outboxRepo.GetPendingEvents(ctx, 10, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+// countingRepo counts the reads of pending events.
+type countingRepo struct {
+	outbox.Repo
+	reads atomic.Int64
+}
+
+func (r *countingRepo) GetPendingEvents(ctx context.Context, limit int, skipUsers []string) ([]entities.OutboxEvent, error) {
+	r.reads.Add(1)
+	return r.Repo.GetPendingEvents(ctx, limit, skipUsers)
+}
+
+func TestRelay_BlockedUserFillsBatch(t *testing.T) {
+	// Setup: user 1 has more events than a batch ahead of user 2
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepo(store)
+	outboxRepo := &countingRepo{Repo: memory.NewOutboxRepo(store)}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	}
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2"}))
+
+	publisher := &recordingPublisher{failUser: "1"}
+	relay := outbox.NewRelay(outboxRepo, publisher, 2)
+
+	// Execute
+	n, err := relay.RelayOnce(ctx)
+
+	// Assert: user 2 is delivered past the held back events of user 1
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Len(t, publisher.published, 1)
+	assert.Equal(t, "2", publisher.published[0].UserID)
+
+	// Execute: run with nothing but user 1's events pending
+	outboxRepo.reads.Store(0)
+	runCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	relay.Run(runCtx, time.Hour)
+
+	// Assert: nothing was published, so the relay waits for the interval
+	// instead of reading again right away
+	assert.Equal(t, int64(2), outboxRepo.reads.Load())
+}
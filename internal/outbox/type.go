@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"sika/pkg/storage/entities"
+	"time"
+)
+
+const (
+	EventUserCreated    = "user.created"
+	EventUserUpdated    = "user.updated"
+	EventUserDeleted    = "user.deleted"
+	EventUserRestored   = "user.restored"
+	EventUserPurged     = "user.purged"
+	EventAddressSaved   = "address.saved"
+	EventAddressDeleted = "address.deleted"
+)
+
+// Event is an outbox event as handed to an EventPublisher.
+type Event struct {
+	Sequence  int64           `json:"sequence"`
+	Type      string          `json:"type"`
+	UserID    string          `json:"user_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// EventPublisher delivers events downstream. An event is only removed from
+// the outbox once Publish returned nil, so it may be delivered more than
+// once and publishers should be idempotent or tolerate duplicates.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+type Repo interface {
+	// GetPendingEvents returns up to limit events in sequence order, leaving
+	// out the events of skipUsers.
+	GetPendingEvents(ctx context.Context, limit int, skipUsers []string) ([]entities.OutboxEvent, error)
+	DeleteEvents(ctx context.Context, sequences []int64) error
+}
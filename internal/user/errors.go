@@ -0,0 +1,39 @@
+package user
+
+import "errors"
+
+// The errors of the user domain. The repos translate database errors into
+// them, so callers need not know the storage in use.
+var (
+	// ErrNotFound is returned when a user does not exist or is soft-deleted.
+	ErrNotFound = errors.New("user not found")
+	// ErrConflict is returned when a write clashes with a stored user.
+	ErrConflict = errors.New("user conflicts with a stored user")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("invalid user")
+	// ErrVersionMismatch is returned when a write expects another version of
+	// the user than the stored one.
+	ErrVersionMismatch = errors.New("user has been changed since the version given")
+	// ErrUnavailable is returned when the database cannot be reached or does
+	// not answer in time. The request may succeed when retried.
+	ErrUnavailable = errors.New("user storage is unavailable")
+)
+
+// ErrIDTaken is an ErrConflict returned when a user with the ID exists, even
+// soft-deleted.
+var ErrIDTaken error = conflict("a user with this ID already exists")
+
+// ErrEmailTaken is an ErrConflict returned when another active user has the
+// email.
+var ErrEmailTaken error = conflict("email is already used by another user")
+
+// conflict is an ErrConflict with its own message.
+type conflict string
+
+func (c conflict) Error() string {
+	return string(c)
+}
+
+func (c conflict) Is(target error) bool {
+	return target == ErrConflict
+}
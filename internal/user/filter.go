@@ -0,0 +1,74 @@
+package user
+
+import (
+	"sika/pkg/filter"
+	"sika/pkg/storage/entities"
+)
+
+// GroupAddress is the field group of address fields. A comparison on them
+// holds when any active address of the user matches.
+const GroupAddress = "address"
+
+// FilterFields are the fields a user filter can reference.
+var FilterFields = filter.Fields{
+	{Name: "id", Type: filter.String},
+	{Name: "name", Type: filter.String},
+	{Name: "email", Type: filter.String},
+	{Name: "phone_number", Type: filter.String},
+	{Name: "created_at", Type: filter.Time},
+	{Name: "updated_at", Type: filter.Time},
+	{Name: "street", Type: filter.String, Group: GroupAddress},
+	{Name: "city", Type: filter.String, Group: GroupAddress},
+	{Name: "state", Type: filter.String, Group: GroupAddress},
+	{Name: "zip_code", Type: filter.String, Group: GroupAddress},
+	{Name: "country", Type: filter.String, Group: GroupAddress},
+	{Name: "address_count", Type: filter.Int},
+}
+
+// ParseFilter parses a user filter expression.
+func ParseFilter(s string) (filter.Expr, error) {
+	return filter.Parse(s, FilterFields)
+}
+
+// FilterRecord is a user and its active addresses as seen by filter.Eval, for
+// backends that filter in Go.
+type FilterRecord struct {
+	User      entities.User
+	Addresses []entities.Address
+}
+
+func (r FilterRecord) Values(field filter.Field) []any {
+	switch field.Name {
+	case "id":
+		return []any{r.User.ID}
+	case "name":
+		return []any{r.User.Name}
+	case "email":
+		return []any{r.User.Email}
+	case "phone_number":
+		return []any{r.User.PhoneNumber}
+	case "created_at":
+		return []any{r.User.CreatedAt}
+	case "updated_at":
+		return []any{r.User.UpdatedAt}
+	case "address_count":
+		return []any{int64(len(r.Addresses))}
+	}
+
+	values := make([]any, len(r.Addresses))
+	for i, a := range r.Addresses {
+		switch field.Name {
+		case "street":
+			values[i] = a.Street
+		case "city":
+			values[i] = a.City
+		case "state":
+			values[i] = a.State
+		case "zip_code":
+			values[i] = a.ZipCode
+		case "country":
+			values[i] = a.Country
+		}
+	}
+	return values
+}
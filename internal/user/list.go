@@ -0,0 +1,132 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sika/pkg/filter"
+	"sika/pkg/storage/entities"
+	"strings"
+	"time"
+)
+
+// Fields users can be listed by. Users with equal values are ordered by ID.
+const (
+	SortByID        = "id"
+	SortByName      = "name"
+	SortByEmail     = "email"
+	SortByCreatedAt = "created_at"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery selects a page of active users. Pages are read with keyset
+// pagination, After is the cursor of the previous page and nil for the first.
+// Filter, parsed with ParseFilter, restricts the users listed when set. The
+// projection selects what is read of each user; the sort field is read too,
+// for the cursor.
+type ListQuery struct {
+	SortBy     string
+	Descending bool
+	Limit      int
+	After      *Cursor
+	Filter     filter.Expr
+	Projection
+}
+
+// Validate checks the limit, the sort field and that the cursor was issued for the same
+// order.
+func (q ListQuery) Validate() error {
+	if q.Limit < 1 {
+		return fmt.Errorf("limit must be positive, got %d", q.Limit)
+	}
+	switch q.SortBy {
+	case SortByID, SortByName, SortByEmail, SortByCreatedAt:
+	default:
+		return fmt.Errorf("unknown sort field %q, expected one of id, name, email, created_at", q.SortBy)
+	}
+	if q.After != nil && (q.After.SortBy != q.SortBy || q.After.Descending != q.Descending) {
+		return fmt.Errorf("%w: it was issued for a different sort order", ErrInvalidCursor)
+	}
+	return nil
+}
+
+// Cursor is the position of the last user of a page in its sort order.
+type Cursor struct {
+	SortBy     string     `json:"s"`
+	Descending bool       `json:"d,omitempty"`
+	ID         string     `json:"id"`
+	Value      string     `json:"v,omitempty"`
+	CreatedAt  *time.Time `json:"t,omitempty"`
+}
+
+// CursorAfter returns the cursor of the page ending with u.
+func CursorAfter(u entities.User, sortBy string, descending bool) Cursor {
+	c := Cursor{SortBy: sortBy, Descending: descending, ID: u.ID}
+	switch sortBy {
+	case SortByName:
+		c.Value = u.Name
+	case SortByEmail:
+		c.Value = u.Email
+	case SortByCreatedAt:
+		createdAt := u.CreatedAt
+		c.CreatedAt = &createdAt
+	}
+	return c
+}
+
+// User returns a user holding the cursor's position, for comparing with
+// CompareUsers.
+func (c Cursor) User() entities.User {
+	u := entities.User{ID: c.ID}
+	switch c.SortBy {
+	case SortByName:
+		u.Name = c.Value
+	case SortByEmail:
+		u.Email = c.Value
+	case SortByCreatedAt:
+		u.CreatedAt = *c.CreatedAt
+	}
+	return u
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a token returned by Encode.
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	if c.SortBy == SortByCreatedAt && c.CreatedAt == nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// CompareUsers orders a and b by sortBy and then by ID, ascending. It matches
+// the order of the database listing for backends that sort in Go.
+func CompareUsers(a, b entities.User, sortBy string) int {
+	var c int
+	switch sortBy {
+	case SortByName:
+		c = strings.Compare(a.Name, b.Name)
+	case SortByEmail:
+		c = strings.Compare(a.Email, b.Email)
+	case SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
@@ -0,0 +1,53 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.FixedZone("", -3*3600))
+	u := entities.User{ID: "42", Name: "Anna", Email: "anna@example.com", CreatedAt: created}
+
+	for _, sortBy := range []string{SortByID, SortByName, SortByEmail, SortByCreatedAt} {
+		c := CursorAfter(u, sortBy, true)
+		decoded, err := DecodeCursor(c.Encode())
+		require.NoError(t, err, sortBy)
+		assert.Equal(t, 0, CompareUsers(u, decoded.User(), sortBy), sortBy)
+		assert.True(t, decoded.Descending)
+	}
+
+	_, err := DecodeCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestListQuery_Validate(t *testing.T) {
+	cursor := CursorAfter(entities.User{ID: "1"}, SortByName, false)
+
+	tests := []struct {
+		name    string
+		query   ListQuery
+		wantErr bool
+	}{
+		{name: "valid", query: ListQuery{SortBy: SortByName, Limit: 10, After: &cursor}},
+		{name: "unknown field", query: ListQuery{SortBy: "phone_number", Limit: 10}, wantErr: true},
+		{name: "zero limit", query: ListQuery{SortBy: SortByID}, wantErr: true},
+		{name: "cursor of another sort", query: ListQuery{SortBy: SortByEmail, Limit: 10, After: &cursor}, wantErr: true},
+		{name: "cursor of another order", query: ListQuery{SortBy: SortByName, Descending: true, Limit: 10, After: &cursor}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -13,7 +13,6 @@ func NewOps(repo Repo) *Ops {
 	return &Ops{repo}
 }
 
-
 func (o *Ops) CreateUser(ctx context.Context, user *entities.User) error {
 	return o.repo.CreateUser(ctx, user)
 }
@@ -26,6 +25,63 @@ func (o *Ops) GetUserByID(ctx context.Context, uid string) (*entities.User, erro
 	return o.repo.GetUserByID(ctx, uid)
 }
 
-func (o *Ops) ClearAllUsersDataFromDB() error {
-	return o.repo.ClearAllUsersDataFromDB()
+func (o *Ops) GetAllUserIDs(ctx context.Context) ([]string, error) {
+	return o.repo.GetAllUserIDs(ctx)
+}
+
+// ListUsers returns a page of active users in the order of q.
+func (o *Ops) ListUsers(ctx context.Context, q ListQuery) ([]entities.User, error) {
+	return o.repo.ListUsers(ctx, q)
+}
+
+// GetUserByIDWithDeleted is GetUserByID that also finds soft-deleted users.
+func (o *Ops) GetUserByIDWithDeleted(ctx context.Context, uid string) (*entities.User, error) {
+	return o.repo.GetUserByIDWithDeleted(ctx, uid)
+}
+
+// GetUserProjection returns what p selects of the user, soft-deleted users
+// included when includeDeleted is set.
+func (o *Ops) GetUserProjection(ctx context.Context, uid string, p Projection, includeDeleted bool) (*entities.User, error) {
+	return o.repo.GetUserProjection(ctx, uid, p, includeDeleted)
+}
+
+// GetUsersByIDs returns the active users among ids with their addresses.
+func (o *Ops) GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error) {
+	return o.repo.GetUsersByIDs(ctx, ids)
+}
+
+// GetUserByEmail finds the active user with the email, ignoring case.
+func (o *Ops) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	return o.repo.GetUserByEmail(ctx, email)
+}
+
+// UpdateUser updates the fields of an active user, not its addresses.
+func (o *Ops) UpdateUser(ctx context.Context, user *entities.User) error {
+	return o.repo.UpdateUser(ctx, user)
+}
+
+// DeleteUser soft-deletes the user, keeping it and its addresses restorable.
+// A version other than 0 must be the stored one.
+func (o *Ops) DeleteUser(ctx context.Context, uid string, version int64) error {
+	return o.repo.DeleteUser(ctx, uid, version)
+}
+
+// PurgeUser permanently removes the user, its addresses are removed by the
+// database.
+func (o *Ops) PurgeUser(ctx context.Context, uid string) error {
+	return o.repo.PurgeUser(ctx, uid)
+}
+
+// RestoreUser undoes a soft delete.
+func (o *Ops) RestoreUser(ctx context.Context, uid string) error {
+	return o.repo.RestoreUser(ctx, uid)
+}
+
+// SearchUsers finds active users by name, email or phone number.
+func (o *Ops) SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error) {
+	return o.repo.SearchUsers(ctx, query, limit, offset)
+}
+
+func (o *Ops) ClearAllUsersDataFromDB(ctx context.Context) error {
+	return o.repo.ClearAllUsersDataFromDB(ctx)
 }
@@ -0,0 +1,96 @@
+package user
+
+import (
+	"fmt"
+	"sika/pkg/storage/entities"
+	"slices"
+	"strings"
+)
+
+// Fields of a user a projection can select. They are both the JSON names of
+// entities.User and the columns of the users table.
+var Fields = []string{"id", "name", "email", "phone_number", "created_at", "updated_at", "deleted_at"}
+
+// IncludeAddresses is the relation embedded by ?include=addresses.
+const IncludeAddresses = "addresses"
+
+// Projection selects what is read of a user. Fields lists the columns read,
+// every column when it is empty, and the ID is read whatever it lists.
+// IncludeAddresses loads the addresses of the user.
+type Projection struct {
+	Fields           []string
+	IncludeAddresses bool
+}
+
+// ParseFields parses a comma-separated list of fields such as
+// "id,name,email". Repeated fields are kept once.
+func ParseFields(v string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(Fields, f) {
+			return nil, fmt.Errorf("unknown field %q, expected some of %s", f, strings.Join(Fields, ", "))
+		}
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// ParseInclude parses a comma-separated list of relations to embed. Only
+// IncludeAddresses is known.
+func ParseInclude(v string) (addresses bool, err error) {
+	for _, rel := range strings.Split(v, ",") {
+		if rel = strings.TrimSpace(rel); rel != IncludeAddresses {
+			return false, fmt.Errorf("unknown relation %q, expected %s", rel, IncludeAddresses)
+		}
+	}
+	return true, nil
+}
+
+// Columns returns the columns read for p: the ID, the fields of p and extra,
+// each once, and the version the ETag is made of.
+func (p Projection) Columns(extra ...string) []string {
+	if len(p.Fields) == 0 {
+		return append(slices.Clone(Fields), "version")
+	}
+	cols := []string{"id"}
+	for _, f := range append(slices.Clone(p.Fields), extra...) {
+		if !slices.Contains(cols, f) {
+			cols = append(cols, f)
+		}
+	}
+	return append(cols, "version")
+}
+
+// Apply returns u holding only what p reads and the fields in extra, for
+// backends that read whole users.
+func (p Projection) Apply(u entities.User, extra ...string) entities.User {
+	if !p.IncludeAddresses {
+		u.Addresses = nil
+	}
+	if len(p.Fields) == 0 {
+		return u
+	}
+	projected := entities.User{Addresses: u.Addresses, Version: u.Version}
+	for _, f := range p.Columns(extra...) {
+		switch f {
+		case "id":
+			projected.ID = u.ID
+		case "name":
+			projected.Name = u.Name
+		case "email":
+			projected.Email = u.Email
+		case "phone_number":
+			projected.PhoneNumber = u.PhoneNumber
+		case "created_at":
+			projected.CreatedAt = u.CreatedAt
+		case "updated_at":
+			projected.UpdatedAt = u.UpdatedAt
+		case "deleted_at":
+			projected.DeletedAt = u.DeletedAt
+		}
+	}
+	return projected
+}
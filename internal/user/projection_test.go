@@ -0,0 +1,54 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields("name, email,name")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "email"}, fields)
+
+	_, err = ParseFields("name,addresses")
+	assert.Error(t, err)
+	_, err = ParseFields("")
+	assert.Error(t, err)
+}
+
+func TestParseInclude(t *testing.T) {
+	addresses, err := ParseInclude("addresses")
+	require.NoError(t, err)
+	assert.True(t, addresses)
+
+	_, err = ParseInclude("orders")
+	assert.Error(t, err)
+}
+
+func TestProjection_Columns(t *testing.T) {
+	assert.Equal(t, append(Fields, "version"), Projection{}.Columns(SortByName))
+	assert.Equal(t, []string{"id", "email", "name", "version"}, Projection{Fields: []string{"email"}}.Columns(SortByName))
+	assert.Equal(t, []string{"id", "email", "version"}, Projection{Fields: []string{"email", "id"}}.Columns(SortByID))
+}
+
+func TestProjection_Apply(t *testing.T) {
+	u := entities.User{
+		ID:        "1",
+		Name:      "Test User",
+		Email:     "test@example.com",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Addresses: []entities.Address{{ID: 1}},
+	}
+
+	assert.Equal(t, entities.User{ID: "1", Email: "test@example.com"}, Projection{Fields: []string{"email"}}.Apply(u))
+	assert.Equal(t, entities.User{ID: "1", Name: "Test User", Email: "test@example.com"}, Projection{Fields: []string{"email"}}.Apply(u, SortByName))
+
+	whole := Projection{IncludeAddresses: true}.Apply(u)
+	assert.Equal(t, u, whole)
+	assert.Nil(t, Projection{}.Apply(u).Addresses)
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"sika/pkg/storage/entities"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SimilarityThreshold is the trigram similarity above which a field matches
+// a search, the default of Postgres pg_trgm.
+const SimilarityThreshold = 0.3
+
+// NormalizeSearchText lowercases s and strips accents, so "José" matches
+// "jose".
+func NormalizeSearchText(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	normalized, _, err := transform.String(t, s)
+	if err != nil {
+		normalized = s
+	}
+	return strings.ToLower(normalized)
+}
+
+// Digits returns the digits of s, phone numbers are searched by digits only.
+func Digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// SearchScore scores u against query for backends without trigram indexes,
+// mirroring the Postgres search: a field matches when it contains the query
+// or is similar enough to it, and the score is the best trigram similarity.
+// ok is false when nothing matches.
+func SearchScore(u entities.User, query string) (score float64, ok bool) {
+	q := NormalizeSearchText(query)
+	fields := []string{NormalizeSearchText(u.Name), NormalizeSearchText(u.Email)}
+	for _, f := range fields {
+		s := similarity(f, q)
+		if s > score {
+			score = s
+		}
+		if s >= SimilarityThreshold || (q != "" && strings.Contains(f, q)) {
+			ok = true
+		}
+	}
+
+	if qd := Digits(query); qd != "" {
+		phone := Digits(u.PhoneNumber)
+		s := similarity(phone, qd)
+		if s > score {
+			score = s
+		}
+		if s >= SimilarityThreshold || strings.Contains(phone, qd) {
+			ok = true
+		}
+	}
+	return score, ok
+}
+
+// similarity is the pg_trgm similarity of a and b: the share of trigrams
+// they have in common.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// trigrams splits s into words of letters and digits and returns the
+// trigrams of each word padded like pg_trgm, two spaces before and one after.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// RankUsers scores users against query and returns the page of matches
+// starting at offset, most relevant first and by ID among equal scores.
+func RankUsers(users []entities.User, query string, limit, offset int) []entities.UserMatch {
+	var matches []entities.UserMatch
+	for _, u := range users {
+		if score, ok := SearchScore(u, query); ok {
+			matches = append(matches, entities.UserMatch{User: u, Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if offset >= len(matches) {
+		return []entities.UserMatch{}
+	}
+	matches = matches[offset:]
+	if limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,64 @@
+package user
+
+import (
+	"testing"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchScore(t *testing.T) {
+	u := entities.User{
+		Name:        "José Álvarez",
+		Email:       "jose.alvarez@example.com",
+		PhoneNumber: "+1 (555) 010-9999",
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantOK  bool
+		minimum float64
+	}{
+		{name: "exact name", query: "José Álvarez", wantOK: true, minimum: 1},
+		{name: "without accents", query: "jose alvarez", wantOK: true, minimum: 1},
+		{name: "misspelled", query: "Alvares", wantOK: true, minimum: SimilarityThreshold},
+		{name: "email fragment", query: "alvarez@exa", wantOK: true},
+		{name: "phone digits", query: "555-010", wantOK: true},
+		{name: "unrelated", query: "Smith", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, ok := SearchScore(u, tt.query)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.GreaterOrEqual(t, score, tt.minimum)
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	// pg_trgm: similarity('word', 'two words') = 0.363636
+	assert.InDelta(t, 0.363636, similarity("word", "two words"), 0.0001)
+	assert.Equal(t, 1.0, similarity("abc", "abc"))
+	assert.Equal(t, 0.0, similarity("", "abc"))
+}
+
+func TestRankUsers(t *testing.T) {
+	users := []entities.User{
+		{ID: "3", Name: "Anna Smith"},
+		{ID: "1", Name: "Anna"},
+		{ID: "2", Name: "Anna"},
+		{ID: "4", Name: "Bob"},
+	}
+
+	matches := RankUsers(users, "anna", 10, 0)
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+
+	assert.Len(t, RankUsers(users, "anna", 2, 1), 2)
+	assert.Empty(t, RankUsers(users, "anna", 10, 5))
+}
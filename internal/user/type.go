@@ -5,10 +5,38 @@ import (
 	"sika/pkg/storage/entities"
 )
 
-
-type Repo interface{
-	CreateUser(ctx context.Context, user *entities.User)error
-	CreateBatchUsers(ctx context.Context, users []entities.User)error
-	GetUserByID(ctx context.Context, id string)(*entities.User, error)
-	ClearAllUsersDataFromDB()error
-}
\ No newline at end of file
+// Repo stores users. Its errors are those of errors.go, such as ErrNotFound,
+// wrapping the error of the database. A write that would give two active
+// users the same non-empty email, ignoring case, fails with ErrEmailTaken.
+type Repo interface {
+	CreateUser(ctx context.Context, user *entities.User) error
+	CreateBatchUsers(ctx context.Context, users []entities.User) error
+	GetUserByID(ctx context.Context, id string) (*entities.User, error)
+	GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error)
+	// GetUserProjection returns the user id with only what p selects. It finds
+	// soft-deleted users when includeDeleted is set.
+	GetUserProjection(ctx context.Context, id string, p Projection, includeDeleted bool) (*entities.User, error)
+	// GetUsersByIDs returns the active users among ids with their addresses,
+	// in no particular order. Unknown IDs are left out.
+	GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error)
+	// GetUserByEmail returns an active user with the email, ignoring case.
+	GetUserByEmail(ctx context.Context, email string) (*entities.User, error)
+	GetAllUserIDs(ctx context.Context) ([]string, error)
+	// ListUsers returns up to q.Limit active users following q.After.
+	ListUsers(ctx context.Context, q ListQuery) ([]entities.User, error)
+	// UpdateUser updates the name, email and phone number of the active user
+	// u.ID and sets the timestamps and the new version of u. Addresses are left
+	// unchanged. A u.Version other than 0 must be the stored version, else
+	// ErrVersionMismatch is returned.
+	UpdateUser(ctx context.Context, u *entities.User) error
+	// DeleteUser soft-deletes the active user id. A version other than 0
+	// must be the stored version, else ErrVersionMismatch is returned.
+	DeleteUser(ctx context.Context, id string, version int64) error
+	PurgeUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) error
+	// SearchUsers returns active users matching query, most relevant first.
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error)
+	// ClearAllUsersDataFromDB removes every user, soft-deleted ones too, and
+	// their addresses, recording a user.purged event for each user.
+	ClearAllUsersDataFromDB(ctx context.Context) error
+}
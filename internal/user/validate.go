@@ -0,0 +1,105 @@
+package user
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sika/pkg/storage/entities"
+	"strings"
+)
+
+// FieldError is a problem with one field of a user.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a user. It matches
+// ErrValidation.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return ErrValidation.Error() + ": " + JoinFieldErrors(e.Errors)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// JoinFieldErrors lists errs in one line for an error message.
+func JoinFieldErrors(errs []FieldError) string {
+	msgs := make([]string, len(errs))
+	for i, fe := range errs {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+const (
+	maxIDLength    = 64
+	maxNameLength  = 255
+	maxEmailLength = 254
+)
+
+var (
+	idPattern    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	phonePattern = regexp.MustCompile(`^\+?[0-9 ().-]+$`)
+)
+
+// Normalize trims surrounding spaces from the fields of u that are written
+// through the API.
+func Normalize(u *entities.User) {
+	u.ID = strings.TrimSpace(u.ID)
+	u.Name = strings.TrimSpace(u.Name)
+	u.Email = strings.TrimSpace(u.Email)
+	u.PhoneNumber = strings.TrimSpace(u.PhoneNumber)
+}
+
+// Validate checks a user written through the API. The name and email are
+// required, the phone number is optional. It returns a *ValidationError.
+func Validate(u entities.User) error {
+	verr := &ValidationError{}
+
+	switch {
+	case u.ID == "":
+		verr.add("id", "is required")
+	case len(u.ID) > maxIDLength:
+		verr.add("id", "must be at most %d characters", maxIDLength)
+	case !idPattern.MatchString(u.ID):
+		verr.add("id", "may only contain letters, digits, '-' and '_'")
+	}
+
+	switch {
+	case u.Name == "":
+		verr.add("name", "is required")
+	case len(u.Name) > maxNameLength:
+		verr.add("name", "must be at most %d characters", maxNameLength)
+	}
+
+	switch addr, err := mail.ParseAddress(u.Email); {
+	case u.Email == "":
+		verr.add("email", "is required")
+	case len(u.Email) > maxEmailLength:
+		verr.add("email", "must be at most %d characters", maxEmailLength)
+	case err != nil || addr.Address != u.Email:
+		verr.add("email", "is not a valid email address")
+	}
+
+	if u.PhoneNumber != "" {
+		digits := len(Digits(u.PhoneNumber))
+		if !phonePattern.MatchString(u.PhoneNumber) || digits < 7 || digits > 15 {
+			verr.add("phone_number", "must have 7 to 15 digits, optionally with a leading '+', spaces, '-', '.' and parentheses")
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sika/config"
+	"strings"
+)
+
+// APIKeyHeader carries the key of config.AuthMethodAPIKey.
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyHashPrefix = "sha256:"
+
+// APIKeys authenticates requests by a static key in APIKeyHeader. Keys are
+// known only by their SHA-256, which is enough for random keys of the
+// length GenerateAPIKey returns.
+type APIKeys struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	name string
+	hash []byte
+}
+
+func NewAPIKeys(keys []config.APIKey) (*APIKeys, error) {
+	a := &APIKeys{}
+	names := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.Name == "" {
+			return nil, errors.New("api key without a name")
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("api key %q is configured twice", k.Name)
+		}
+		names[k.Name] = true
+		hexHash, ok := strings.CutPrefix(k.Hash, apiKeyHashPrefix)
+		hash, err := hex.DecodeString(hexHash)
+		if !ok || err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: hash must be %q followed by a hex SHA-256", k.Name, apiKeyHashPrefix)
+		}
+		a.keys = append(a.keys, apiKey{name: k.Name, hash: hash})
+	}
+	return a, nil
+}
+
+func (a *APIKeys) Authenticate(ctx context.Context, header func(name string) string) (Principal, error) {
+	key := header(APIKeyHeader)
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	sum := sha256.Sum256([]byte(key))
+	// every key is compared, so the time taken does not tell which matched
+	var name string
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			name = k.name
+		}
+	}
+	if name == "" {
+		return Principal{}, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	return Principal{Method: config.AuthMethodAPIKey, Subject: name}, nil
+}
+
+func (a *APIKeys) Challenge() string {
+	return `ApiKey header="` + APIKeyHeader + `"`
+}
+
+// HashAPIKey returns the hash of key as configured in config.APIKey.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random key of 256 bits.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"sika/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headers returns the header func of a request carrying h.
+func headers(h map[string]string) func(string) string {
+	return func(name string) string { return h[name] }
+}
+
+func TestAPIKeys_Authenticate(t *testing.T) {
+	key, err := GenerateAPIKey()
+	require.NoError(t, err)
+	keys, err := NewAPIKeys([]config.APIKey{
+		{Name: "ci", Hash: HashAPIKey(key)},
+		{Name: "ops", Hash: HashAPIKey("ops-key")},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name              string
+		key               string
+		expectedPrincipal Principal
+		expectedError     error
+	}{
+		{name: "generated key", key: key, expectedPrincipal: Principal{Method: config.AuthMethodAPIKey, Subject: "ci"}},
+		{name: "second key", key: "ops-key", expectedPrincipal: Principal{Method: config.AuthMethodAPIKey, Subject: "ops"}},
+		{name: "no key", expectedError: ErrNoCredentials},
+		{name: "unknown key", key: "other", expectedError: ErrInvalidCredentials},
+		{name: "hash as key", key: HashAPIKey(key), expectedError: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := keys.Authenticate(context.Background(), headers(map[string]string{APIKeyHeader: tt.key}))
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPrincipal, principal)
+		})
+	}
+}
+
+func TestNewAPIKeys_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		keys          []config.APIKey
+		expectedError string
+	}{
+		{name: "no name", keys: []config.APIKey{{Hash: HashAPIKey("a")}}, expectedError: "without a name"},
+		{name: "duplicate name", keys: []config.APIKey{{Name: "ci", Hash: HashAPIKey("a")}, {Name: "ci", Hash: HashAPIKey("b")}}, expectedError: "configured twice"},
+		{name: "plain key", keys: []config.APIKey{{Name: "ci", Hash: "secret"}}, expectedError: "hash must be"},
+		{name: "short hash", keys: []config.APIKey{{Name: "ci", Hash: "sha256:abcd"}}, expectedError: "hash must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAPIKeys(tt.keys)
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	a, err := GenerateAPIKey()
+	require.NoError(t, err)
+	b, err := GenerateAPIKey()
+	require.NoError(t, err)
+
+	assert.Len(t, a, 43)
+	assert.NotEqual(t, a, b)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, HashAPIKey(a))
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// no credentials of its method, so that another method may be tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrInvalidCredentials is returned by an Authenticator when the request
+// carries credentials of its method that do not verify.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Method is the config.AuthMethod the caller authenticated with.
+	Method string
+	// Subject names the caller, the name of an API key or the sub claim of
+	// a token.
+	Subject string
+}
+
+// Authenticator authenticates requests by one method. Authenticate reads
+// the request headers through header and returns ErrNoCredentials when
+// there are none of its method, or an error wrapping ErrInvalidCredentials
+// when they do not verify.
+type Authenticator interface {
+	Authenticate(ctx context.Context, header func(name string) string) (Principal, error)
+	// Challenge is the WWW-Authenticate challenge of the method.
+	Challenge() string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal set by WithPrincipal. It reports
+// false for unauthenticated requests and for work not done on behalf of a
+// caller, such as imports.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sika/config"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTVerifier authenticates requests by a bearer token signed with HS256 or
+// RS256. Keys are read once, from the config and the files it names.
+type JWTVerifier struct {
+	hmacSecret []byte
+	publicKey  *rsa.PublicKey
+	// jwks holds the keys of the JWKS file by kid, *rsa.PublicKey or []byte
+	jwks   map[string]interface{}
+	parser *jwt.Parser
+}
+
+func NewJWTVerifier(cfg config.JWT) (*JWTVerifier, error) {
+	v := &JWTVerifier{}
+	var methods []string
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.PublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt public_key_file: %w", err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.jwks, err = parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt jwks_file: %w", err)
+		}
+		methods = append(methods, jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("jwt requires hmac_secret, public_key_file or jwks_file")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+func (v *JWTVerifier) Authenticate(ctx context.Context, header func(name string) string) (Principal, error) {
+	scheme, token, ok := strings.Cut(header("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := v.parser.ParseWithClaims(strings.TrimSpace(token), &claims, v.key); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if claims.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no sub claim", ErrInvalidCredentials)
+	}
+	return Principal{Method: config.AuthMethodJWT, Subject: claims.Subject}, nil
+}
+
+func (v *JWTVerifier) Challenge() string {
+	return "Bearer"
+}
+
+// key returns the key verifying token. A token with a kid must name a key
+// of the JWKS file, one without uses the key configured for its algorithm.
+// The parser rejects a key of the wrong type for the algorithm, so an RSA
+// public key can never be taken for an HMAC secret.
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"].(string); ok && v.jwks != nil {
+		key, ok := v.jwks[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	}
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		if v.hmacSecret != nil {
+			return v.hmacSecret, nil
+		}
+	case jwt.SigningMethodRS256.Alg():
+		if v.publicKey != nil {
+			return v.publicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("no key for %s tokens without a kid", token.Method.Alg())
+}
+
+// jwk is the part of a JSON Web Key (RFC 7517) read from a JWKS file.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA public key
+	N string `json:"n"`
+	E string `json:"e"`
+	// symmetric key
+	K string `json:"k"`
+}
+
+// parseJWKS returns the signature keys of a JWKS document by kid. Keys for
+// encryption and of other types are skipped.
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if k.Kid == "" {
+			return nil, errors.New("key without a kid")
+		}
+		if _, ok := keys[k.Kid]; ok {
+			return nil, fmt.Errorf("kid %q is used twice", k.Kid)
+		}
+		switch k.Kty {
+		case "RSA":
+			if k.Alg != "" && k.Alg != jwt.SigningMethodRS256.Alg() {
+				continue
+			}
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("kid %q: n: %w", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("kid %q: e: %w", k.Kid, err)
+			}
+			exponent := new(big.Int).SetBytes(e)
+			if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("kid %q: invalid RSA key", k.Kid)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		case "oct":
+			if k.Alg != "" && k.Alg != jwt.SigningMethodHS256.Alg() {
+				continue
+			}
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("kid %q: invalid symmetric key", k.Kid)
+			}
+			keys[k.Kid] = secret
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RS256 or HS256 signature keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sika/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes data to a file of a temporary directory and returns its
+// path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestJWTVerifier_Authenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(otherKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(otherKey.E)).Bytes())},
+		{"kty": "oct", "kid": "hmac-1", "k": base64.RawURLEncoding.EncodeToString([]byte("jwks-secret"))},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}})
+	require.NoError(t, err)
+
+	v, err := NewJWTVerifier(config.JWT{
+		HMACSecret:    "secret",
+		PublicKeyFile: writeFile(t, "key.pem", publicPEM),
+		JWKSFile:      writeFile(t, "jwks.json", jwks),
+		Issuer:        "https://issuer.example.com",
+		Audience:      "sika",
+	})
+	require.NoError(t, err)
+
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "https://issuer.example.com",
+			Audience:  jwt.ClaimStrings{"sika"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}
+	with := func(change func(c *jwt.RegisteredClaims)) jwt.RegisteredClaims {
+		c := valid()
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		header        string
+		expectedError error
+	}{
+		{name: "HS256", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", valid())},
+		{name: "RS256", header: "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "", valid())},
+		{name: "RS256 from the JWKS", header: "Bearer " + sign(t, jwt.SigningMethodRS256, otherKey, "rsa-1", valid())},
+		{name: "HS256 from the JWKS", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("jwks-secret"), "hmac-1", valid())},
+		{name: "scheme is case-insensitive", header: "bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", valid())},
+		{name: "no header", expectedError: ErrNoCredentials},
+		{name: "other scheme", header: "Basic YWxpY2U6c2VjcmV0", expectedError: ErrNoCredentials},
+		{name: "malformed", header: "Bearer abc", expectedError: ErrInvalidCredentials},
+		{name: "wrong secret", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("wrong"), "", valid()), expectedError: ErrInvalidCredentials},
+		{name: "wrong RSA key", header: "Bearer " + sign(t, jwt.SigningMethodRS256, otherKey, "", valid()), expectedError: ErrInvalidCredentials},
+		{name: "unknown kid", header: "Bearer " + sign(t, jwt.SigningMethodRS256, otherKey, "rsa-2", valid()), expectedError: ErrInvalidCredentials},
+		{name: "encryption key", header: "Bearer " + sign(t, jwt.SigningMethodRS256, otherKey, "enc-1", valid()), expectedError: ErrInvalidCredentials},
+		{name: "public key as HMAC secret", header: "Bearer " + sign(t, jwt.SigningMethodHS256, publicPEM, "", valid()), expectedError: ErrInvalidCredentials},
+		{name: "RSA kid with HS256", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "rsa-1", valid()), expectedError: ErrInvalidCredentials},
+		{name: "alg none", header: "Bearer " + sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", valid()), expectedError: ErrInvalidCredentials},
+		{name: "HS512", header: "Bearer " + sign(t, jwt.SigningMethodHS512, []byte("secret"), "", valid()), expectedError: ErrInvalidCredentials},
+		{name: "expired", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", with(func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		})), expectedError: ErrInvalidCredentials},
+		{name: "no expiry", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", with(func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = nil
+		})), expectedError: ErrInvalidCredentials},
+		{name: "not yet valid", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", with(func(c *jwt.RegisteredClaims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		})), expectedError: ErrInvalidCredentials},
+		{name: "wrong issuer", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", with(func(c *jwt.RegisteredClaims) {
+			c.Issuer = "https://other.example.com"
+		})), expectedError: ErrInvalidCredentials},
+		{name: "wrong audience", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", with(func(c *jwt.RegisteredClaims) {
+			c.Audience = jwt.ClaimStrings{"other"}
+		})), expectedError: ErrInvalidCredentials},
+		{name: "no subject", header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", with(func(c *jwt.RegisteredClaims) {
+			c.Subject = ""
+		})), expectedError: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := v.Authenticate(context.Background(), headers(map[string]string{"Authorization": tt.header}))
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, Principal{Method: config.AuthMethodJWT, Subject: "alice"}, principal)
+		})
+	}
+}
+
+func TestJWTVerifier_Leeway(t *testing.T) {
+	claims := jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}
+	header := headers(map[string]string{"Authorization": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("secret"), "", claims)})
+
+	strict, err := NewJWTVerifier(config.JWT{HMACSecret: "secret"})
+	require.NoError(t, err)
+	_, err = strict.Authenticate(context.Background(), header)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	lenient, err := NewJWTVerifier(config.JWT{HMACSecret: "secret", Leeway: 2 * time.Minute})
+	require.NoError(t, err)
+	_, err = lenient.Authenticate(context.Background(), header)
+	assert.NoError(t, err)
+}
+
+func TestNewJWTVerifier_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           func(t *testing.T) config.JWT
+		expectedError string
+	}{
+		{name: "no key", cfg: func(t *testing.T) config.JWT { return config.JWT{Issuer: "x"} }, expectedError: "requires"},
+		{name: "not a PEM key", cfg: func(t *testing.T) config.JWT {
+			return config.JWT{PublicKeyFile: writeFile(t, "key.pem", []byte("secret"))}
+		}, expectedError: "public_key_file"},
+		{name: "JWKS without signature keys", cfg: func(t *testing.T) config.JWT {
+			return config.JWT{JWKSFile: writeFile(t, "jwks.json", []byte(`{"keys": [{"kty": "EC", "kid": "ec-1"}]}`))}
+		}, expectedError: "no RS256 or HS256 signature keys"},
+		{name: "JWKS key without kid", cfg: func(t *testing.T) config.JWT {
+			return config.JWT{JWKSFile: writeFile(t, "jwks.json", []byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`))}
+		}, expectedError: "without a kid"},
+		{name: "JWKS kid twice", cfg: func(t *testing.T) config.JWT {
+			return config.JWT{JWKSFile: writeFile(t, "jwks.json", []byte(`{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}, {"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`))}
+		}, expectedError: "used twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewJWTVerifier(tt.cfg(t))
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sika/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePublisher_WritesNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	ctx := context.Background()
+
+	// Publish across two opens, the file is appended to
+	for _, seq := range []int64{1, 2} {
+		publisher, err := NewFilePublisher(path)
+		require.NoError(t, err)
+		require.NoError(t, publisher.Publish(ctx, outbox.Event{
+			Sequence: seq,
+			Type:     outbox.EventUserCreated,
+			UserID:   "1",
+			Payload:  json.RawMessage(`{"id":"1"}`),
+		}))
+		require.NoError(t, publisher.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []outbox.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e outbox.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(1), events[0].Sequence)
+	assert.Equal(t, int64(2), events[1].Sequence)
+	assert.JSONEq(t, `{"id":"1"}`, string(events[1].Payload))
+}
+
+func TestInProcessPublisher_FailingHandler(t *testing.T) {
+	publisher := NewInProcessPublisher()
+	var received []int64
+	publisher.Subscribe(func(ctx context.Context, event outbox.Event) error {
+		received = append(received, event.Sequence)
+		return nil
+	})
+	publisher.Subscribe(func(ctx context.Context, event outbox.Event) error {
+		if event.Sequence == 2 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	require.NoError(t, publisher.Publish(context.Background(), outbox.Event{Sequence: 1}))
+	assert.ErrorIs(t, publisher.Publish(context.Background(), outbox.Event{Sequence: 2}), assert.AnError)
+	assert.Equal(t, []int64{1, 2}, received)
+}
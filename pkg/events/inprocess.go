@@ -0,0 +1,42 @@
+// Package events provides outbox.EventPublisher sinks.
+package events
+
+import (
+	"context"
+	"sika/internal/outbox"
+	"sync"
+)
+
+// Handler receives a published event. Returning an error makes the relay
+// deliver the event again later, to every handler.
+type Handler func(ctx context.Context, event outbox.Event) error
+
+// InProcessPublisher hands events to handlers registered in the same
+// process, in the order they were subscribed.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{}
+}
+
+func (p *InProcessPublisher) Subscribe(h Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers = append(p.handlers, h)
+}
+
+func (p *InProcessPublisher) Publish(ctx context.Context, event outbox.Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, h := range p.handlers {
+		if err := h(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
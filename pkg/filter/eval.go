@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"strings"
+	"time"
+)
+
+// Record provides field values to Eval. A field of a group has one value per
+// related record, other fields have exactly one.
+type Record interface {
+	Values(field Field) []any
+}
+
+// Eval reports whether r matches e, with the same semantics as ToSQL.
+func Eval(e Expr, r Record) bool {
+	switch e := e.(type) {
+	case And:
+		return Eval(e.Left, r) && Eval(e.Right, r)
+	case Or:
+		return Eval(e.Left, r) || Eval(e.Right, r)
+	case Not:
+		return !Eval(e.Expr, r)
+	case Comparison:
+		for _, v := range r.Values(e.Field) {
+			if matches(e, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matches(cmp Comparison, v any) bool {
+	switch cmp.Op {
+	case In:
+		for _, want := range cmp.Values {
+			if compare(v, want) == 0 {
+				return true
+			}
+		}
+		return false
+	case Contains, StartsWith, EndsWith:
+		s, want := strings.ToLower(v.(string)), strings.ToLower(cmp.Values[0].(string))
+		switch cmp.Op {
+		case Contains:
+			return strings.Contains(s, want)
+		case StartsWith:
+			return strings.HasPrefix(s, want)
+		default:
+			return strings.HasSuffix(s, want)
+		}
+	}
+
+	c := compare(v, cmp.Values[0])
+	switch cmp.Op {
+	case Eq:
+		return c == 0
+	case Ne:
+		return c != 0
+	case Lt:
+		return c < 0
+	case Le:
+		return c <= 0
+	case Gt:
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
@@ -0,0 +1,136 @@
+// Package filter parses filter expressions such as
+//
+//	country = AR and city startswith 'Glen' and address_count > 2
+//
+// against a fixed set of fields, and compiles them to parameterized SQL or
+// evaluates them in Go.
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxLength and MaxDepth bound the size of an expression.
+const (
+	MaxLength = 2000
+	MaxDepth  = 32
+)
+
+// Type is the type of a field, it decides the operators and values allowed.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Time
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "integer"
+	case Time:
+		return "timestamp"
+	default:
+		return "string"
+	}
+}
+
+// Field is a field an expression can reference. Fields of a Group belong to
+// related records, a comparison on them holds when any related record
+// matches.
+type Field struct {
+	Name  string
+	Type  Type
+	Group string
+}
+
+// Fields is the set of fields of a filterable resource.
+type Fields []Field
+
+func (fs Fields) lookup(name string) (Field, bool) {
+	for _, f := range fs {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+func (fs Fields) names() string {
+	names := make([]string, len(fs))
+	for i, f := range fs {
+		names[i] = f.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Op is a comparison operator.
+type Op string
+
+const (
+	Eq         Op = "="
+	Ne         Op = "!="
+	Lt         Op = "<"
+	Le         Op = "<="
+	Gt         Op = ">"
+	Ge         Op = ">="
+	Contains   Op = "contains"
+	StartsWith Op = "startswith"
+	EndsWith   Op = "endswith"
+	In         Op = "in"
+)
+
+// Expr is a parsed expression: And, Or, Not or Comparison.
+type Expr interface {
+	expr()
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares a field to its values, which are string, int64 or
+// time.Time by the field's type. Only In has more than one value.
+type Comparison struct {
+	Field  Field
+	Op     Op
+	Values []any
+}
+
+func (And) expr()        {}
+func (Or) expr()         {}
+func (Not) expr()        {}
+func (Comparison) expr() {}
+
+// Error is a problem with an expression, at a byte offset of the input.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseTime accepts an RFC 3339 timestamp or a date meaning its start in UTC.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
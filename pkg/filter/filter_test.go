@@ -0,0 +1,163 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFields = Fields{
+	{Name: "name", Type: String},
+	{Name: "created_at", Type: Time},
+	{Name: "count", Type: Int},
+	{Name: "city", Type: String, Group: "address"},
+}
+
+var testSchema = SQLSchema{
+	Columns: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+		"count":      "cnt",
+		"city":       "a.city",
+	},
+	Groups: map[string]string{
+		"address": "EXISTS (SELECT 1 FROM addresses a WHERE %s)",
+	},
+}
+
+func TestToSQL(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			input:    "name = Anna",
+			wantSQL:  "name = ?",
+			wantArgs: []any{"Anna"},
+		},
+		{
+			input:    `city STARTSWITH 'Glen' and count > 2 or not name <> "O'Hara"`,
+			wantSQL:  `((EXISTS (SELECT 1 FROM addresses a WHERE LOWER(a.city) LIKE ? ESCAPE '\') AND cnt > ?) OR NOT (name != ?))`,
+			wantArgs: []any{"glen%", int64(2), "O'Hara"},
+		},
+		{
+			input:    "name = a and (name = b or name = c)",
+			wantSQL:  "(name = ? AND (name = ? OR name = ?))",
+			wantArgs: []any{"a", "b", "c"},
+		},
+		{
+			input:    "name contains '50%_off' and name endswith 'x'",
+			wantSQL:  `(LOWER(name) LIKE ? ESCAPE '\' AND LOWER(name) LIKE ? ESCAPE '\')`,
+			wantArgs: []any{`%50\%\_off%`, "%x"},
+		},
+		{
+			input:    "name in (a, 'b c', 3) and created_at >= '2026-01-02'",
+			wantSQL:  "(name IN (?, ?, ?) AND created_at >= ?)",
+			wantArgs: []any{"a", "b c", "3", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			input:    "name = 'x; DROP TABLE users; --'",
+			wantSQL:  "name = ?",
+			wantArgs: []any{"x; DROP TABLE users; --"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input, testFields)
+			require.NoError(t, err)
+
+			sql, args, err := ToSQL(e, testSchema)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestToSQL_Placeholder(t *testing.T) {
+	e, err := Parse("name = a or count in (1, 2)", testFields)
+	require.NoError(t, err)
+
+	schema := testSchema
+	schema.Placeholder = func(n int) string { return fmt.Sprintf("$%d", n+1) }
+	sql, _, err := ToSQL(e, schema)
+	require.NoError(t, err)
+	assert.Equal(t, "(name = $2 OR cnt IN ($3, $4))", sql)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantPos int
+		wantMsg string
+	}{
+		{input: "phone = 1", wantPos: 0, wantMsg: `unknown field "phone", expected one of name, created_at, count, city`},
+		{input: "count > many", wantPos: 8, wantMsg: "count is an integer"},
+		{input: "count contains 1", wantPos: 6, wantMsg: "contains only applies to string fields, count is of type integer"},
+		{input: "created_at > 2026", wantPos: 13, wantMsg: "created_at is a timestamp, quote it like '2026-01-02'"},
+		{input: "created_at > 'yesterday'", wantPos: 13, wantMsg: `created_at is a timestamp, expected RFC 3339 or YYYY-MM-DD, got "yesterday"`},
+		{input: "name = 'open", wantPos: 7, wantMsg: "unterminated string"},
+		{input: "name like a", wantPos: 5, wantMsg: "expected an operator after name"},
+		{input: "name = a name = b", wantPos: 9, wantMsg: `unexpected "name", expected and, or or the end`},
+		{input: "(name = a", wantPos: 9, wantMsg: "expected ')'"},
+		{input: "name in (a b)", wantPos: 11, wantMsg: "expected ',' or ')'"},
+		{input: "name = a and", wantPos: 12, wantMsg: "expected a field name"},
+		{input: "name ! a", wantPos: 5, wantMsg: "unexpected '!'"},
+		{input: strings.Repeat("(", MaxDepth+2) + "name = a", wantPos: MaxDepth + 1, wantMsg: "nested deeper than 32 levels"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input, testFields)
+			var ferr *Error
+			require.ErrorAs(t, err, &ferr)
+			assert.Equal(t, tt.wantPos, ferr.Pos)
+			assert.Equal(t, tt.wantMsg, ferr.Msg)
+		})
+	}
+
+	_, err := Parse(strings.Repeat(" ", MaxLength+1), testFields)
+	assert.Error(t, err)
+}
+
+type testRecord map[string][]any
+
+func (r testRecord) Values(f Field) []any {
+	return r[f.Name]
+}
+
+func TestEval(t *testing.T) {
+	record := testRecord{
+		"name":       {"Anna"},
+		"created_at": {time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		"count":      {int64(3)},
+		"city":       {"Springfield", "Glendale"},
+	}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "name = Anna", want: true},
+		{input: "name = anna", want: false},
+		{input: "name startswith AN", want: true},
+		{input: "city = Glendale and city = Springfield", want: true},
+		{input: "city startswith 'Glen' and count > 2", want: true},
+		{input: "city = Paris or count <= 2", want: false},
+		{input: "not city = Paris", want: true},
+		{input: "count in (1, 3)", want: true},
+		{input: "created_at < '2026-03-01T00:00:01Z'", want: true},
+		{input: "created_at > '2026-03-01'", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input, testFields)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, Eval(e, record))
+		})
+	}
+}
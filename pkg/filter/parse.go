@@ -0,0 +1,308 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits the input into tokens. Identifiers are words of letters, digits
+// and underscores, strings are single or double quoted with the quote doubled
+// to escape it.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case r == '\'' || r == '"':
+			s, end, ok := lexString(input, i)
+			if !ok {
+				return nil, errorf(i, "unterminated string")
+			}
+			tokens = append(tokens, token{tokenString, s, i})
+			i = end
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(input) && input[i+1] == '=' {
+				op += "="
+			} else if r == '<' && i+1 < len(input) && input[i+1] == '>' {
+				op = "<>"
+			}
+			if op == "!" {
+				return nil, errorf(i, "unexpected '!'")
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		case r == '-' || unicode.IsDigit(r):
+			end := i + 1
+			for end < len(input) && (isDigit(input[end]) || input[end] == '.') {
+				end++
+			}
+			if input[i:end] == "-" {
+				return nil, errorf(i, "unexpected '-'")
+			}
+			tokens = append(tokens, token{tokenNumber, input[i:end], i})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(input) {
+				r, size := utf8.DecodeRuneInString(input[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{tokenIdent, input[i:end], i})
+			i = end
+		default:
+			return nil, errorf(i, "unexpected %q", r)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(input)}), nil
+}
+
+func lexString(input string, start int) (string, int, bool) {
+	quote := input[start]
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		if input[i] != quote {
+			b.WriteByte(input[i])
+			continue
+		}
+		if i+1 < len(input) && input[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Parse parses input against fields. and binds tighter than or, not applies
+// to the comparison or parenthesized expression that follows it. Keywords and
+// operators are case-insensitive, field names are not.
+func Parse(input string, fields Fields) (Expr, error) {
+	if len(input) > MaxLength {
+		return nil, errorf(MaxLength, "longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	e, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(t.pos, "unexpected %q, expected and, or or the end", t.text)
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	fields Fields
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot(depth int) (Expr, error) {
+	if depth > MaxDepth {
+		return nil, errorf(p.peek().pos, "nested deeper than %d levels", MaxDepth)
+	}
+	if p.keyword("not") {
+		e, err := p.parseNot(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: e}, nil
+	}
+	if t := p.peek(); t.kind == tokenLParen {
+		p.advance()
+		e, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t := p.advance(); t.kind != tokenRParen {
+			return nil, errorf(t.pos, "expected ')'")
+		}
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	t := p.advance()
+	if t.kind != tokenIdent {
+		return nil, errorf(t.pos, "expected a field name")
+	}
+	field, ok := p.fields.lookup(t.text)
+	if !ok {
+		return nil, errorf(t.pos, "unknown field %q, expected one of %s", t.text, p.fields.names())
+	}
+
+	opToken := p.advance()
+	var op Op
+	switch {
+	case opToken.kind == tokenOperator:
+		op = Op(opToken.text)
+		if op == "<>" {
+			op = Ne
+		}
+	case opToken.kind == tokenIdent:
+		op = Op(strings.ToLower(opToken.text))
+	}
+	switch op {
+	case Eq, Ne, Lt, Le, Gt, Ge, In:
+	case Contains, StartsWith, EndsWith:
+		if field.Type != String {
+			return nil, errorf(opToken.pos, "%s only applies to string fields, %s is of type %s", op, field.Name, field.Type)
+		}
+	default:
+		return nil, errorf(opToken.pos, "expected an operator after %s", field.Name)
+	}
+
+	if op != In {
+		v, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		return Comparison{Field: field, Op: op, Values: []any{v}}, nil
+	}
+
+	if t := p.advance(); t.kind != tokenLParen {
+		return nil, errorf(t.pos, "expected '(' after in")
+	}
+	var values []any
+	for {
+		v, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if t := p.advance(); t.kind == tokenRParen {
+			break
+		} else if t.kind != tokenComma {
+			return nil, errorf(t.pos, "expected ',' or ')'")
+		}
+	}
+	return Comparison{Field: field, Op: In, Values: values}, nil
+}
+
+// parseValue reads a value of the field's type. Unquoted words and numbers
+// are accepted as strings, so country = AR needs no quotes.
+func (p *parser) parseValue(field Field) (any, error) {
+	t := p.advance()
+	switch field.Type {
+	case Int:
+		if t.kind != tokenNumber {
+			return nil, errorf(t.pos, "%s is an integer", field.Name)
+		}
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, errorf(t.pos, "%s is an integer, got %s", field.Name, t.text)
+		}
+		return n, nil
+	case Time:
+		if t.kind != tokenString {
+			return nil, errorf(t.pos, "%s is a timestamp, quote it like '2026-01-02'", field.Name)
+		}
+		at, err := parseTime(t.text)
+		if err != nil {
+			return nil, errorf(t.pos, "%s is a timestamp, expected RFC 3339 or YYYY-MM-DD, got %q", field.Name, t.text)
+		}
+		return at, nil
+	default:
+		if t.kind != tokenString && t.kind != tokenIdent && t.kind != tokenNumber {
+			return nil, errorf(t.pos, "expected a value for %s", field.Name)
+		}
+		return t.text, nil
+	}
+}
@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLSchema maps fields to SQL for ToSQL.
+type SQLSchema struct {
+	// Columns holds the SQL expression of each field.
+	Columns map[string]string
+	// Groups holds, for each field group, a condition with one %s verb for
+	// the comparison, such as an EXISTS over the related table.
+	Groups map[string]string
+	// Placeholder returns the bind parameter for the nth argument, counted
+	// from 1. Nil means "?".
+	Placeholder func(n int) string
+}
+
+// ToSQL compiles e into a condition and its arguments. Values are always
+// bound as arguments, only the schema's SQL and fixed operators end up in the
+// condition. Pattern operators are case-insensitive and escape % and _ with \.
+func ToSQL(e Expr, schema SQLSchema) (string, []any, error) {
+	c := &compiler{schema: schema}
+	var b strings.Builder
+	if err := c.compile(&b, e); err != nil {
+		return "", nil, err
+	}
+	return b.String(), c.args, nil
+}
+
+type compiler struct {
+	schema SQLSchema
+	args   []any
+}
+
+func (c *compiler) bind(v any) string {
+	c.args = append(c.args, v)
+	if c.schema.Placeholder == nil {
+		return "?"
+	}
+	return c.schema.Placeholder(len(c.args))
+}
+
+func (c *compiler) compile(b *strings.Builder, e Expr) error {
+	switch e := e.(type) {
+	case And:
+		return c.binary(b, "AND", e.Left, e.Right)
+	case Or:
+		return c.binary(b, "OR", e.Left, e.Right)
+	case Not:
+		b.WriteString("NOT (")
+		if err := c.compile(b, e.Expr); err != nil {
+			return err
+		}
+		b.WriteString(")")
+		return nil
+	case Comparison:
+		return c.comparison(b, e)
+	default:
+		return fmt.Errorf("unknown filter expression %T", e)
+	}
+}
+
+func (c *compiler) binary(b *strings.Builder, op string, left, right Expr) error {
+	b.WriteString("(")
+	if err := c.compile(b, left); err != nil {
+		return err
+	}
+	b.WriteString(" " + op + " ")
+	if err := c.compile(b, right); err != nil {
+		return err
+	}
+	b.WriteString(")")
+	return nil
+}
+
+func (c *compiler) comparison(b *strings.Builder, cmp Comparison) error {
+	col, ok := c.schema.Columns[cmp.Field.Name]
+	if !ok {
+		return fmt.Errorf("no column for filter field %s", cmp.Field.Name)
+	}
+
+	var cond string
+	switch cmp.Op {
+	case Eq, Ne, Lt, Le, Gt, Ge:
+		cond = fmt.Sprintf("%s %s %s", col, cmp.Op, c.bind(cmp.Values[0]))
+	case Contains, StartsWith, EndsWith:
+		cond = fmt.Sprintf(`LOWER(%s) LIKE %s ESCAPE '\'`, col, c.bind(likePattern(cmp)))
+	case In:
+		params := make([]string, len(cmp.Values))
+		for i, v := range cmp.Values {
+			params[i] = c.bind(v)
+		}
+		cond = fmt.Sprintf("%s IN (%s)", col, strings.Join(params, ", "))
+	default:
+		return fmt.Errorf("unknown filter operator %s", cmp.Op)
+	}
+
+	if cmp.Field.Group != "" {
+		group, ok := c.schema.Groups[cmp.Field.Group]
+		if !ok {
+			return fmt.Errorf("no condition for filter field group %s", cmp.Field.Group)
+		}
+		cond = fmt.Sprintf(group, cond)
+	}
+	b.WriteString(cond)
+	return nil
+}
+
+func likePattern(cmp Comparison) string {
+	v := strings.ToLower(cmp.Values[0].(string))
+	v = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(v)
+	switch cmp.Op {
+	case Contains:
+		return "%" + v + "%"
+	case StartsWith:
+		return v + "%"
+	default:
+		return "%" + v
+	}
+}
@@ -43,3 +43,46 @@ func LoadData(filePath string) ([]User, error) {
 	}
 	return users, nil
 }
+
+// StreamData decodes the users array in filePath one element at a time and
+// hands each user to fn, so the whole file never has to be held in memory.
+// It stops at the first error returned by fn.
+func StreamData(filePath string, fn func(User) error) error {
+	if filePath == "" {
+		filePath = "data/users_data.json"
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	defer file.Close()
+
+	return Stream(file, fn)
+}
+
+// Stream is StreamData for an arbitrary reader holding a JSON array of users.
+func Stream(r io.Reader, fn func(User) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("error reading JSON: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("error reading JSON: expected array of users")
+	}
+
+	for dec.More() {
+		var u User
+		if err := dec.Decode(&u); err != nil {
+			return fmt.Errorf("Error un-marshalling JSON: %w", err)
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("error reading JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Package mergepatch applies JSON Merge Patch documents, RFC 7396.
+package mergepatch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContentType is the media type of a merge patch.
+const ContentType = "application/merge-patch+json"
+
+// Apply returns doc with patch applied. Members of a patch object replace
+// the members of the same name, recursively for objects, and null removes
+// them. A patch that is not an object replaces the whole document.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	var target interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, fmt.Errorf("invalid document: %w", err)
+		}
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = merge(t[name], value)
+	}
+	return t
+}
@@ -4,44 +4,265 @@ import (
 	"context"
 	"fmt"
 	"sika/internal/address"
+	"sika/internal/outbox"
 	"sika/pkg/storage/entities"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type addressRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	router *Router
 }
 
 func NewAddressRepo(db *gorm.DB) address.Repo {
-	return &addressRepo{
-		db: db,
-	}
+	return NewAddressRepoWithRouter(NewRouter(db))
+}
+
+// NewAddressRepoWithRouter returns a repo that writes to the router's primary and
+// reads from its replicas. Its errors are translated by AddressError.
+func NewAddressRepoWithRouter(router *Router) address.Repo {
+	return WithAddressErrors(&addressRepo{
+		db:     router.Primary(),
+		router: router,
+	})
 }
 
+// CreateAddress upserts a, keeping created_at of an existing row.
 func (r *addressRepo) CreateAddress(ctx context.Context, a *entities.Address) error {
-	if err := r.db.WithContext(ctx).Save(&a).Error; err != nil {
-		return err
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := touchUsers(tx, a.UserID); err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(a).Error; err != nil {
+			return err
+		}
+		return appendEvent(tx, outbox.EventAddressSaved, a.UserID, a)
+	})
+}
+
+func (r *addressRepo) CreateBatchAddresses(ctx context.Context, adds []entities.Address) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		userIDs := make([]string, len(adds))
+		for i := range adds {
+			userIDs[i] = adds[i].UserID
+		}
+		if err := touchUsers(tx, uniqueStrings(userIDs)...); err != nil {
+			return err
+		}
+		if err := tx.CreateInBatches(adds, 10).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, outbox.EventAddressSaved, userIDs, adds)
+	})
+}
+
+func (r *addressRepo) GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error) {
+	var addresses []entities.Address
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Where("user_id=?", userID).Find(&addresses).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	return addresses, nil
+}
 
-	return nil
+func (r *addressRepo) GetAddress(ctx context.Context, userID string, id int) (*entities.Address, error) {
+	var a entities.Address
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Where("id=? AND user_id=?", id, userID).First(&a).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
 }
 
-func (r *addressRepo) CreateBatchAddresses(ctx context.Context, adds []entities.Address) error {
-	if err := r.db.WithContext(ctx).CreateInBatches(adds, 10).Error; err != nil {
-		return err
+// addressFields returns the columns of a written through the API.
+func addressFields(a *entities.Address, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"street":     a.Street,
+		"city":       a.City,
+		"state":      a.State,
+		"zip_code":   a.ZipCode,
+		"country":    a.Country,
+		"updated_at": now,
 	}
-	return nil
 }
-func (r *addressRepo) GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error) {
+
+// UpdateAddress returns gorm.ErrRecordNotFound when the address does not
+// belong to a.UserID.
+func (r *addressRepo) UpdateAddress(ctx context.Context, a *entities.Address) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := touchUsers(tx, a.UserID); err != nil {
+			return err
+		}
+		now := time.Now()
+		result := tx.Model(&entities.Address{}).Where("id=? AND user_id=?", a.ID, a.UserID).Updates(addressFields(a, now))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var stored entities.Address
+		if err := tx.Select("created_at").First(&stored, "id=?", a.ID).Error; err != nil {
+			return err
+		}
+		a.CreatedAt, a.UpdatedAt, a.DeletedAt = stored.CreatedAt, now, gorm.DeletedAt{}
+		return appendEvent(tx, outbox.EventAddressSaved, a.UserID, a)
+	})
+}
+
+// DeleteAddress removes the address row, gorm.ErrRecordNotFound when it does
+// not belong to the user.
+func (r *addressRepo) DeleteAddress(ctx context.Context, userID string, id int) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := touchUsers(tx, userID); err != nil {
+			return err
+		}
+		var a entities.Address
+		if err := tx.Where("id=? AND user_id=?", id, userID).First(&a).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&entities.Address{}, "id=?", id).Error; err != nil {
+			return err
+		}
+		return appendEvent(tx, outbox.EventAddressDeleted, userID, a)
+	})
+}
+
+func (r *addressRepo) ReplaceAddresses(ctx context.Context, userID string, adds []entities.Address) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := touchUsers(tx, userID); err != nil {
+			return err
+		}
+		var existing []entities.Address
+		if err := tx.Where("user_id=?", userID).Order("id").Find(&existing).Error; err != nil {
+			return err
+		}
+		byID := make(map[int]entities.Address, len(existing))
+		for _, a := range existing {
+			byID[a.ID] = a
+		}
+
+		now := time.Now()
+		kept := make(map[int]struct{}, len(adds))
+		var inserts []*entities.Address
+		for i := range adds {
+			a := &adds[i]
+			a.UserID = userID
+			if a.ID == 0 {
+				inserts = append(inserts, a)
+				continue
+			}
+			old, ok := byID[a.ID]
+			if !ok {
+				return gorm.ErrRecordNotFound
+			}
+			kept[a.ID] = struct{}{}
+			if err := tx.Model(&entities.Address{}).Where("id=?", a.ID).Updates(addressFields(a, now)).Error; err != nil {
+				return err
+			}
+			a.CreatedAt, a.UpdatedAt, a.DeletedAt = old.CreatedAt, now, gorm.DeletedAt{}
+		}
+
+		var removed []entities.Address
+		var removedIDs []int
+		for _, a := range existing {
+			if _, ok := kept[a.ID]; !ok {
+				removed = append(removed, a)
+				removedIDs = append(removedIDs, a.ID)
+			}
+		}
+		if len(removedIDs) > 0 {
+			if err := tx.Unscoped().Where("id IN ?", removedIDs).Delete(&entities.Address{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(inserts) > 0 {
+			if err := tx.Create(inserts).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := appendEvents(tx, outbox.EventAddressDeleted, repeatString(userID, len(removed)), removed); err != nil {
+			return err
+		}
+		return appendEvents(tx, outbox.EventAddressSaved, repeatString(userID, len(adds)), adds)
+	})
+}
+
+// repeatString returns a slice of n copies of s.
+func repeatString(s string, n int) []string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = s
+	}
+	return values
+}
+
+const orphanAddressCondition = "NOT EXISTS (SELECT 1 FROM users WHERE users.id = addresses.user_id)"
+
+// GetOrphanAddresses reads from the primary and includes soft-deleted rows
+// like DeleteOrphanAddresses, so a repair deletes exactly what was reported.
+func (r *addressRepo) GetOrphanAddresses(ctx context.Context) ([]entities.Address, error) {
 	var addresses []entities.Address
-	result := r.db.WithContext(ctx).Where("user_id=?", userID).Find(&addresses)
+	result := conn(ctx, r.db).Unscoped().Where(orphanAddressCondition).Order("id").Find(&addresses)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return addresses, nil
 }
 
+func (r *addressRepo) DeleteOrphanAddresses(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var orphans []entities.Address
+		if err := tx.Unscoped().Where(orphanAddressCondition).Order("id").Find(&orphans).Error; err != nil {
+			return err
+		}
+		if len(orphans) == 0 {
+			return nil
+		}
+
+		ids := make([]int, len(orphans))
+		userIDs := make([]string, len(orphans))
+		for i, a := range orphans {
+			ids[i] = a.ID
+			userIDs[i] = a.UserID
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&entities.Address{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return appendEvents(tx, outbox.EventAddressDeleted, userIDs, orphans)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphan addresses: %w", err)
+	}
+	return deleted, nil
+}
+
+// uniqueStrings returns values without duplicates, in first-seen order.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	var unique []string
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		unique = append(unique, v)
+	}
+	return unique
+}
+
 func (r *addressRepo) ClearAllAddressesDataFromDB() error {
 	if err := r.db.Exec("DELETE FROM addresses").Error; err != nil {
 		return fmt.Errorf("failed to clear addresses table: %w", err)
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAddressRepo_OrphanAddresses(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	addressRepo := NewAddressRepo(db)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, addressRepo.CreateAddress(ctx, &entities.Address{UserID: "1", Street: "1 Test St"}))
+
+	// orphans predate the foreign key, so they are inserted with it disabled
+	require.NoError(t, db.Exec("PRAGMA foreign_keys = OFF").Error)
+	require.NoError(t, addressRepo.CreateAddress(ctx, &entities.Address{UserID: "gone", Street: "2 Test St"}))
+	softDeleted := &entities.Address{UserID: "gone", Street: "3 Test St"}
+	require.NoError(t, addressRepo.CreateAddress(ctx, softDeleted))
+	require.NoError(t, db.Delete(softDeleted).Error)
+	require.NoError(t, db.Exec("PRAGMA foreign_keys = ON").Error)
+
+	// soft-deleted orphans are reported, since the repair deletes them too
+	orphans, err := addressRepo.GetOrphanAddresses(ctx)
+	require.NoError(t, err)
+	require.Len(t, orphans, 2)
+	assert.Equal(t, "gone", orphans[0].UserID)
+	assert.True(t, orphans[1].DeletedAt.Valid)
+
+	deleted, err := addressRepo.DeleteOrphanAddresses(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	orphans, err = addressRepo.GetOrphanAddresses(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+
+	// orphans are removed for good, not soft-deleted
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&entities.Address{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	addrs, err := addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, addrs, 1)
+}
+
+func TestUserRepo_PurgeUserCascades(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	addressRepo := NewAddressRepo(db)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, addressRepo.CreateBatchAddresses(ctx, []entities.Address{{UserID: "1"}, {UserID: "1"}}))
+
+	require.NoError(t, userRepo.PurgeUser(ctx, "1"))
+
+	addrs, err := addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, addrs)
+
+	_, err = userRepo.GetUserByIDWithDeleted(ctx, "1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, userRepo.PurgeUser(ctx, "1"), gorm.ErrRecordNotFound)
+}
+
+func TestAddressRepo_AddressesOfUser(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	addressRepo := NewAddressRepo(db)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2"}))
+	first := &entities.Address{UserID: "1", Street: "1 Test St"}
+	second := &entities.Address{UserID: "1", Street: "2 Test St"}
+	other := &entities.Address{UserID: "2", Street: "3 Test St"}
+	for _, a := range []*entities.Address{first, second, other} {
+		require.NoError(t, addressRepo.CreateAddress(ctx, a))
+	}
+
+	// addresses of another user are not reachable
+	_, err := addressRepo.GetAddress(ctx, "1", other.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, addressRepo.UpdateAddress(ctx, &entities.Address{ID: other.ID, UserID: "1", Street: "x"}), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, addressRepo.DeleteAddress(ctx, "1", other.ID), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, addressRepo.ReplaceAddresses(ctx, "1", []entities.Address{{ID: other.ID}}), gorm.ErrRecordNotFound)
+
+	updated := &entities.Address{ID: first.ID, UserID: "1", City: "Glendale"}
+	require.NoError(t, addressRepo.UpdateAddress(ctx, updated))
+	assert.Equal(t, first.CreatedAt.Unix(), updated.CreatedAt.Unix())
+	got, err := addressRepo.GetAddress(ctx, "1", first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Glendale", got.City)
+	assert.Empty(t, got.Street)
+
+	// the set replace keeps first, deletes second and adds a new address
+	set := []entities.Address{{ID: first.ID, Street: "1 New St"}, {Street: "4 Test St"}}
+	require.NoError(t, addressRepo.ReplaceAddresses(ctx, "1", set))
+	assert.NotZero(t, set[1].ID)
+	addrs, err := addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	var streets []string
+	for _, a := range addrs {
+		streets = append(streets, a.Street)
+	}
+	assert.ElementsMatch(t, []string{"1 New St", "4 Test St"}, streets)
+	_, err = addressRepo.GetAddress(ctx, "1", second.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, addressRepo.DeleteAddress(ctx, "1", first.ID))
+	addrs, err = addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, addrs, 1)
+
+	addrs, err = addressRepo.GetAddressByUser(ctx, "2")
+	require.NoError(t, err)
+	assert.Len(t, addrs, 1)
+}
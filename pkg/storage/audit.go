@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"sika/internal/audit"
+	"sika/pkg/storage/entities"
+
+	"gorm.io/gorm"
+)
+
+type auditRepo struct {
+	db     *gorm.DB
+	router *Router
+}
+
+func NewAuditRepo(db *gorm.DB) audit.Repo {
+	return NewAuditRepoWithRouter(NewRouter(db))
+}
+
+// NewAuditRepoWithRouter returns a repo that appends to the router's primary
+// and reads from its replicas.
+func NewAuditRepoWithRouter(router *Router) audit.Repo {
+	return &auditRepo{
+		db:     router.Primary(),
+		router: router,
+	}
+}
+
+func (r *auditRepo) AppendEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	return conn(ctx, r.db).Create(entry).Error
+}
+
+func (r *auditRepo) AppendEntries(ctx context.Context, entries []entities.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Create(&entries).Error
+}
+
+func (r *auditRepo) GetEntriesByUser(ctx context.Context, userID string) ([]entities.AuditEntry, error) {
+	var entries []entities.AuditEntry
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Where("user_id=?", userID).Order("id").Find(&entries).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
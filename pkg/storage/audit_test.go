@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAuditRepo_AppendOnly(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	auditRepo := NewAuditRepo(db)
+	require.NoError(t, auditRepo.AppendEntry(ctx, &entities.AuditEntry{
+		Actor: "alice", Action: "update", UserID: "1", RequestID: "req-1", ChangedFields: []string{"name", "email"},
+	}))
+	require.NoError(t, auditRepo.AppendEntry(ctx, &entities.AuditEntry{Actor: "bob", Action: "view", UserID: "1"}))
+	require.NoError(t, auditRepo.AppendEntry(ctx, &entities.AuditEntry{Actor: "bob", Action: "view", UserID: "2"}))
+
+	entries, err := auditRepo.GetEntriesByUser(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, []string{"name", "email"}, entries[0].ChangedFields)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.False(t, entries[0].CreatedAt.IsZero())
+	assert.Equal(t, "bob", entries[1].Actor)
+
+	// entries can be neither changed nor removed
+	assert.Error(t, db.Exec("UPDATE audit_log SET actor = 'mallory'").Error)
+	assert.Error(t, db.Exec("DELETE FROM audit_log").Error)
+}
+
+func TestAuditRepo_AppendEntries(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	inserts := 0
+	require.NoError(t, db.Callback().Create().After("gorm:create").Register("test:count_inserts", func(*gorm.DB) {
+		inserts++
+	}))
+
+	auditRepo := NewAuditRepo(db)
+	require.NoError(t, auditRepo.AppendEntries(ctx, nil))
+	assert.Zero(t, inserts, "no entries, no statement")
+
+	require.NoError(t, auditRepo.AppendEntries(ctx, []entities.AuditEntry{
+		{Actor: "bob", Action: "view", UserID: "1", RequestID: "req-1"},
+		{Actor: "bob", Action: "view", UserID: "2", RequestID: "req-1"},
+		{Actor: "bob", Action: "view", UserID: "1", RequestID: "req-1"},
+	}))
+	assert.Equal(t, 1, inserts, "all entries are written in one statement")
+
+	entries, err := auditRepo.GetEntriesByUser(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.False(t, entries[0].CreatedAt.IsZero())
+}
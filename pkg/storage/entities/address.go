@@ -1,11 +1,20 @@
 package entities
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Address struct {
-	ID      int    `json:"address_id" gorm:"primaryKey"`
-	UserID  string `json:"user_id"`
-	Street  string `json:"street"`
-	City    string `json:"city"`
-	State   string `json:"state"`
-	ZipCode string `json:"zip_code"`
-	Country string `json:"country"`
+	ID        int            `json:"address_id" gorm:"primaryKey"`
+	UserID    string         `json:"user_id"`
+	Street    string         `json:"street"`
+	City      string         `json:"city"`
+	State     string         `json:"state"`
+	ZipCode   string         `json:"zip_code"`
+	Country   string         `json:"country"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
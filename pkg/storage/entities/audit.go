@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+// AuditEntry records one action on a user's personal data. Entries are only
+// ever appended, and outlive the user they describe.
+type AuditEntry struct {
+	ID            int64     `json:"id" gorm:"primaryKey"`
+	Actor         string    `json:"actor"`
+	Action        string    `json:"action"`
+	UserID        string    `json:"user_id"`
+	RequestID     string    `json:"request_id,omitempty"`
+	ChangedFields []string  `json:"changed_fields,omitempty" gorm:"serializer:json"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
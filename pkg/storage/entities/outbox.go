@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+// OutboxEvent is a user change written in the same transaction as the change
+// itself, waiting to be relayed to downstream services. Sequence orders the
+// events, Payload holds the changed record as JSON.
+type OutboxEvent struct {
+	Sequence  int64     `json:"sequence" gorm:"primaryKey"`
+	EventType string    `json:"type"`
+	UserID    string    `json:"user_id"`
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
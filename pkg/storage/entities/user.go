@@ -1,10 +1,21 @@
 package entities
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
-	ID          string    `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name"`
-	Email       string    `json:"email"`
-	PhoneNumber string    `json:"phone_number"`
-	Addresses   []Address `json:"addresses" gorm:"foreignKey:UserID"`
-}
\ No newline at end of file
+	ID          string         `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name"`
+	Email       string         `json:"email"`
+	PhoneNumber string         `json:"phone_number"`
+	Addresses   []Address      `json:"addresses" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	// Version counts the writes of the user and its addresses. It is sent
+	// as the ETag, not in the body.
+	Version int64 `json:"-" gorm:"not null;default:1"`
+}
@@ -0,0 +1,8 @@
+package entities
+
+// UserMatch is a user found by a search, with the relevance of the match
+// between 0 and 1.
+type UserMatch struct {
+	User
+	Score float64 `json:"score"`
+}
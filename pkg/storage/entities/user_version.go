@@ -0,0 +1,22 @@
+package entities
+
+import "time"
+
+// UserVersion is a user and its address set as held between ValidFrom and
+// ValidTo. The current version has no ValidTo.
+type UserVersion struct {
+	ID          int64      `json:"-" gorm:"primaryKey"`
+	UserID      string     `json:"user_id"`
+	Version     int        `json:"version"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	PhoneNumber string     `json:"phone_number"`
+	Addresses   []Address  `json:"addresses" gorm:"serializer:json"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+	ValidFrom   time.Time  `json:"valid_from"`
+	ValidTo     *time.Time `json:"valid_to"`
+}
+
+func (UserVersion) TableName() string {
+	return "user_history"
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// UserError translates an error of a user repo into the errors of
+// internal/user. The original error stays in the chain, so
+// gorm.ErrRecordNotFound and the like still match. A duplicate key the repo
+// already found to be the email is left as user.ErrEmailTaken.
+func UserError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, user.ErrEmailTaken):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("%w: %w", user.ErrNotFound, err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %w", user.ErrIDTaken, err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return fmt.Errorf("%w: %w", user.ErrConflict, err)
+	case isUnavailable(err):
+		return fmt.Errorf("%w: %w", user.ErrUnavailable, err)
+	}
+	return err
+}
+
+// AddressError translates an error of an address repo into the errors of
+// internal/address. A foreign key violation means the user of the address is
+// gone and becomes user.ErrNotFound.
+func AddressError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("%w: %w", address.ErrNotFound, err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %w", address.ErrConflict, err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return fmt.Errorf("%w: %w", user.ErrNotFound, err)
+	case isUnavailable(err):
+		return fmt.Errorf("%w: %w", address.ErrUnavailable, err)
+	}
+	return err
+}
+
+// isUnavailable reports whether err means the database could not be reached
+// or did not answer in time, rather than that it rejected the statement.
+func isUnavailable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// connection exceptions, insufficient resources such as too many
+	// connections, and operator intervention such as a shutdown or a
+	// statement timeout
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && len(pgErr.Code) == 5 {
+		class := pgErr.Code[:2]
+		return class == "08" || class == "53" || class == "57"
+	}
+	// SQLite tells a busy database only in the message
+	return strings.Contains(err.Error(), "database is locked")
+}
+
+// userErrorRepo translates the errors of a user.Repo with UserError.
+type userErrorRepo struct {
+	repo user.Repo
+}
+
+// WithUserErrors returns repo with its errors translated by UserError.
+func WithUserErrors(repo user.Repo) user.Repo {
+	return &userErrorRepo{repo: repo}
+}
+
+func (r *userErrorRepo) CreateUser(ctx context.Context, u *entities.User) error {
+	return UserError(r.repo.CreateUser(ctx, u))
+}
+
+func (r *userErrorRepo) CreateBatchUsers(ctx context.Context, users []entities.User) error {
+	return UserError(r.repo.CreateBatchUsers(ctx, users))
+}
+
+func (r *userErrorRepo) GetUserByID(ctx context.Context, id string) (*entities.User, error) {
+	u, err := r.repo.GetUserByID(ctx, id)
+	return u, UserError(err)
+}
+
+func (r *userErrorRepo) GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error) {
+	u, err := r.repo.GetUserByIDWithDeleted(ctx, id)
+	return u, UserError(err)
+}
+
+func (r *userErrorRepo) GetUserProjection(ctx context.Context, id string, p user.Projection, includeDeleted bool) (*entities.User, error) {
+	u, err := r.repo.GetUserProjection(ctx, id, p, includeDeleted)
+	return u, UserError(err)
+}
+
+func (r *userErrorRepo) GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error) {
+	users, err := r.repo.GetUsersByIDs(ctx, ids)
+	return users, UserError(err)
+}
+
+func (r *userErrorRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	u, err := r.repo.GetUserByEmail(ctx, email)
+	return u, UserError(err)
+}
+
+func (r *userErrorRepo) GetAllUserIDs(ctx context.Context) ([]string, error) {
+	ids, err := r.repo.GetAllUserIDs(ctx)
+	return ids, UserError(err)
+}
+
+func (r *userErrorRepo) ListUsers(ctx context.Context, q user.ListQuery) ([]entities.User, error) {
+	users, err := r.repo.ListUsers(ctx, q)
+	return users, UserError(err)
+}
+
+func (r *userErrorRepo) UpdateUser(ctx context.Context, u *entities.User) error {
+	return UserError(r.repo.UpdateUser(ctx, u))
+}
+
+func (r *userErrorRepo) DeleteUser(ctx context.Context, id string, version int64) error {
+	return UserError(r.repo.DeleteUser(ctx, id, version))
+}
+
+func (r *userErrorRepo) PurgeUser(ctx context.Context, id string) error {
+	return UserError(r.repo.PurgeUser(ctx, id))
+}
+
+func (r *userErrorRepo) RestoreUser(ctx context.Context, id string) error {
+	return UserError(r.repo.RestoreUser(ctx, id))
+}
+
+func (r *userErrorRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error) {
+	matches, err := r.repo.SearchUsers(ctx, query, limit, offset)
+	return matches, UserError(err)
+}
+
+func (r *userErrorRepo) ClearAllUsersDataFromDB(ctx context.Context) error {
+	return UserError(r.repo.ClearAllUsersDataFromDB(ctx))
+}
+
+// addressErrorRepo translates the errors of an address.Repo with
+// AddressError.
+type addressErrorRepo struct {
+	repo address.Repo
+}
+
+// WithAddressErrors returns repo with its errors translated by AddressError.
+func WithAddressErrors(repo address.Repo) address.Repo {
+	return &addressErrorRepo{repo: repo}
+}
+
+func (r *addressErrorRepo) CreateAddress(ctx context.Context, a *entities.Address) error {
+	return AddressError(r.repo.CreateAddress(ctx, a))
+}
+
+func (r *addressErrorRepo) CreateBatchAddresses(ctx context.Context, adds []entities.Address) error {
+	return AddressError(r.repo.CreateBatchAddresses(ctx, adds))
+}
+
+func (r *addressErrorRepo) GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error) {
+	addrs, err := r.repo.GetAddressByUser(ctx, userID)
+	return addrs, AddressError(err)
+}
+
+func (r *addressErrorRepo) GetAddress(ctx context.Context, userID string, id int) (*entities.Address, error) {
+	a, err := r.repo.GetAddress(ctx, userID, id)
+	return a, AddressError(err)
+}
+
+func (r *addressErrorRepo) UpdateAddress(ctx context.Context, a *entities.Address) error {
+	return AddressError(r.repo.UpdateAddress(ctx, a))
+}
+
+func (r *addressErrorRepo) DeleteAddress(ctx context.Context, userID string, id int) error {
+	return AddressError(r.repo.DeleteAddress(ctx, userID, id))
+}
+
+func (r *addressErrorRepo) ReplaceAddresses(ctx context.Context, userID string, adds []entities.Address) error {
+	return AddressError(r.repo.ReplaceAddresses(ctx, userID, adds))
+}
+
+func (r *addressErrorRepo) GetOrphanAddresses(ctx context.Context) ([]entities.Address, error) {
+	addrs, err := r.repo.GetOrphanAddresses(ctx)
+	return addrs, AddressError(err)
+}
+
+func (r *addressErrorRepo) DeleteOrphanAddresses(ctx context.Context) (int64, error) {
+	deleted, err := r.repo.DeleteOrphanAddresses(ctx)
+	return deleted, AddressError(err)
+}
+
+func (r *addressErrorRepo) ClearAllAddressesDataFromDB() error {
+	return AddressError(r.repo.ClearAllAddressesDataFromDB())
+}
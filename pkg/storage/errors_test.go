@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUserError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "not found", err: gorm.ErrRecordNotFound, expected: user.ErrNotFound},
+		{name: "duplicate key", err: gorm.ErrDuplicatedKey, expected: user.ErrIDTaken},
+		{name: "duplicate email", err: fmt.Errorf("%w: %w", user.ErrEmailTaken, gorm.ErrDuplicatedKey), expected: user.ErrEmailTaken},
+		{name: "foreign key", err: gorm.ErrForeignKeyViolated, expected: user.ErrConflict},
+		{name: "timeout", err: fmt.Errorf("query: %w", context.DeadlineExceeded), expected: user.ErrUnavailable},
+		{name: "too many connections", err: &pgconn.PgError{Code: "53300"}, expected: user.ErrUnavailable},
+		{name: "sqlite busy", err: errors.New("database is locked (5) (SQLITE_BUSY)"), expected: user.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			err := UserError(tt.err)
+
+			// Assert
+			assert.ErrorIs(t, err, tt.expected)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	assert.NotErrorIs(t, UserError(fmt.Errorf("%w: %w", user.ErrEmailTaken, gorm.ErrDuplicatedKey)), user.ErrIDTaken)
+	assert.NoError(t, UserError(nil))
+	syntaxErr := &pgconn.PgError{Code: "42601"}
+	assert.Equal(t, syntaxErr, UserError(syntaxErr))
+}
+
+func TestAddressError(t *testing.T) {
+	assert.ErrorIs(t, AddressError(gorm.ErrRecordNotFound), address.ErrNotFound)
+	assert.ErrorIs(t, AddressError(gorm.ErrDuplicatedKey), address.ErrConflict)
+	assert.ErrorIs(t, AddressError(gorm.ErrForeignKeyViolated), user.ErrNotFound)
+	assert.ErrorIs(t, AddressError(context.DeadlineExceeded), address.ErrUnavailable)
+	assert.NoError(t, AddressError(nil))
+}
+
+func TestRepos_ReturnDomainErrors(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	addressRepo := NewAddressRepo(db)
+
+	_, err := userRepo.GetUserByID(ctx, "1")
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	require.NoError(t, userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "1"}}))
+	assert.ErrorIs(t, userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "1"}}), user.ErrIDTaken)
+
+	_, err = addressRepo.GetAddress(ctx, "1", 1)
+	assert.ErrorIs(t, err, address.ErrNotFound)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"sika/internal/history"
+	"sika/pkg/storage/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type historyRepo struct {
+	db     *gorm.DB
+	router *Router
+}
+
+func NewHistoryRepo(db *gorm.DB) history.Repo {
+	return NewHistoryRepoWithRouter(NewRouter(db))
+}
+
+// NewHistoryRepoWithRouter returns a repo that writes to the router's primary
+// and reads from its replicas.
+func NewHistoryRepoWithRouter(router *Router) history.Repo {
+	return &historyRepo{
+		db:     router.Primary(),
+		router: router,
+	}
+}
+
+// Times are stored in UTC, SQLite compares them as text.
+func (r *historyRepo) AppendVersion(ctx context.Context, v *entities.UserVersion) error {
+	v.ValidFrom = v.ValidFrom.UTC()
+	if v.Addresses == nil {
+		v.Addresses = []entities.Address{}
+	}
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var last int
+		err := tx.Model(&entities.UserVersion{}).Where("user_id=?", v.UserID).
+			Select("COALESCE(MAX(version), 0)").Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&entities.UserVersion{}).Where("user_id=? AND valid_to IS NULL", v.UserID).
+			Update("valid_to", v.ValidFrom).Error
+		if err != nil {
+			return err
+		}
+		v.Version = last + 1
+		return tx.Create(v).Error
+	})
+}
+
+// GetCurrentVersion reads from the primary, it is compared against right
+// before a new version is appended. It locks the user first, see lockUsers,
+// so that concurrent writes of the user compare and append their versions
+// one after the other.
+func (r *historyRepo) GetCurrentVersion(ctx context.Context, userID string) (*entities.UserVersion, error) {
+	db := conn(ctx, r.db)
+	if err := lockUsers(db, userID); err != nil {
+		return nil, err
+	}
+	var v entities.UserVersion
+	result := db.Where("user_id=? AND valid_to IS NULL", userID).First(&v)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &v, nil
+}
+
+func (r *historyRepo) GetVersions(ctx context.Context, userID string) ([]entities.UserVersion, error) {
+	var versions []entities.UserVersion
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Where("user_id=?", userID).Order("version").Find(&versions).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *historyRepo) GetVersionAt(ctx context.Context, userID string, at time.Time) (*entities.UserVersion, error) {
+	var v entities.UserVersion
+	at = at.UTC()
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Where("user_id=? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", userID, at, at).
+			Order("version DESC").First(&v).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *historyRepo) DeleteVersions(ctx context.Context, userID string) error {
+	return conn(ctx, r.db).Where("user_id=?", userID).Delete(&entities.UserVersion{}).Error
+}
+
+func (r *historyRepo) CloseVersions(ctx context.Context, at time.Time) error {
+	return conn(ctx, r.db).Model(&entities.UserVersion{}).Where("valid_to IS NULL").
+		Update("valid_to", at.UTC()).Error
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestHistoryRepo_Versions(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	historyRepo := NewHistoryRepo(db)
+	t1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := historyRepo.GetCurrentVersion(ctx, "1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, historyRepo.AppendVersion(ctx, &entities.UserVersion{
+		UserID: "1", Email: "old@example.com", ValidFrom: t1,
+		Addresses: []entities.Address{{ID: 1, UserID: "1", Street: "1 Old St"}},
+	}))
+	require.NoError(t, historyRepo.AppendVersion(ctx, &entities.UserVersion{
+		UserID: "1", Email: "new@example.com", ValidFrom: t2,
+	}))
+
+	versions, err := historyRepo.GetVersions(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 1, versions[0].Version)
+	require.NotNil(t, versions[0].ValidTo)
+	assert.True(t, versions[0].ValidTo.Equal(t2))
+	assert.Equal(t, "1 Old St", versions[0].Addresses[0].Street)
+	assert.Equal(t, 2, versions[1].Version)
+	assert.Nil(t, versions[1].ValidTo)
+
+	current, err := historyRepo.GetCurrentVersion(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", current.Email)
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantEmail string
+		wantErr   error
+	}{
+		{name: "before the first version", at: t1.Add(-time.Second), wantErr: gorm.ErrRecordNotFound},
+		{name: "start of the first version", at: t1, wantEmail: "old@example.com"},
+		{name: "just before the change", at: t2.Add(-time.Millisecond), wantEmail: "old@example.com"},
+		{name: "at the change", at: t2, wantEmail: "new@example.com"},
+		{name: "in another zone", at: t2.In(time.FixedZone("UTC+5", 5*3600)), wantEmail: "new@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := historyRepo.GetVersionAt(ctx, "1", tt.at)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEmail, v.Email)
+		})
+	}
+
+	require.NoError(t, historyRepo.DeleteVersions(ctx, "1"))
+	versions, err = historyRepo.GetVersions(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func TestMigrate_BackfillsUserHistory(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	// Roll back to before the history table and store a user
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	steps := 0
+	for _, m := range migrations {
+		if m.version > 6 {
+			steps++
+		}
+	}
+	require.NoError(t, MigrateDown(db, steps))
+	require.NoError(t, db.Exec(`INSERT INTO users (id, email, created_at, updated_at) VALUES ('1', 'test@example.com', ?, ?)`, time.Now(), time.Now()).Error)
+	require.NoError(t, db.Exec(`INSERT INTO addresses (user_id, street, created_at, updated_at) VALUES ('1', '1 Test St', ?, ?), ('1', '2 Test St', ?, ?)`, time.Now(), time.Now(), time.Now(), time.Now()).Error)
+	require.NoError(t, Migrate(db))
+
+	versions, err := NewHistoryRepo(db).GetVersions(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, 1, versions[0].Version)
+	assert.Equal(t, "test@example.com", versions[0].Email)
+	require.Len(t, versions[0].Addresses, 2)
+	assert.Equal(t, "1 Test St", versions[0].Addresses[0].Street)
+	assert.Equal(t, "1", versions[0].Addresses[0].UserID)
+
+	v, err := NewHistoryRepo(db).GetVersionAt(ctx, "1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", v.Email)
+}
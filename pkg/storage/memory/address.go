@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"context"
+	"sika/internal/address"
+	"sika/internal/outbox"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+type addressRepo struct {
+	store *Store
+}
+
+// NewAddressRepo returns a repo whose errors are translated by
+// storage.AddressError like those of the database repos.
+func NewAddressRepo(store *Store) address.Repo {
+	return storage.WithAddressErrors(&addressRepo{
+		store: store,
+	})
+}
+
+// CreateAddress upserts a, assigning a new ID when a.ID is zero.
+func (r *addressRepo) CreateAddress(ctx context.Context, a *entities.Address) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[a.UserID]; !ok {
+		return gorm.ErrForeignKeyViolated
+	}
+	*a = r.store.upsertAddress(*a)
+	r.store.touchUsers(a.UserID)
+	r.store.appendEvent(outbox.EventAddressSaved, a.UserID, a)
+	return nil
+}
+
+// CreateBatchAddresses inserts all addresses or none, failing on an existing ID.
+func (r *addressRepo) CreateBatchAddresses(ctx context.Context, adds []entities.Address) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	seen := make(map[int]struct{}, len(adds))
+	for _, a := range adds {
+		if _, ok := r.store.users[a.UserID]; !ok {
+			return gorm.ErrForeignKeyViolated
+		}
+		if a.ID == 0 {
+			continue
+		}
+		if _, ok := r.store.addresses[a.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+		if _, ok := seen[a.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+		seen[a.ID] = struct{}{}
+	}
+
+	touched := make(map[string]struct{}, len(adds))
+	for i := range adds {
+		adds[i] = r.store.upsertAddress(adds[i])
+		r.store.appendEvent(outbox.EventAddressSaved, adds[i].UserID, adds[i])
+		if _, ok := touched[adds[i].UserID]; !ok {
+			touched[adds[i].UserID] = struct{}{}
+			r.store.touchUsers(adds[i].UserID)
+		}
+	}
+	return nil
+}
+
+func (r *addressRepo) GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.addressesOf(userID), nil
+}
+
+// ownAddress returns the address id when it belongs to the user. Callers must
+// hold at least a read lock.
+func (r *addressRepo) ownAddress(userID string, id int) (entities.Address, error) {
+	a, ok := r.store.addresses[id]
+	if !ok || a.UserID != userID {
+		return entities.Address{}, gorm.ErrRecordNotFound
+	}
+	return a, nil
+}
+
+func (r *addressRepo) GetAddress(ctx context.Context, userID string, id int) (*entities.Address, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	a, err := r.ownAddress(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *addressRepo) UpdateAddress(ctx context.Context, a *entities.Address) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, err := r.ownAddress(a.UserID, a.ID); err != nil {
+		return err
+	}
+	*a = r.store.upsertAddress(*a)
+	r.store.touchUsers(a.UserID)
+	r.store.appendEvent(outbox.EventAddressSaved, a.UserID, a)
+	return nil
+}
+
+func (r *addressRepo) DeleteAddress(ctx context.Context, userID string, id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	a, err := r.ownAddress(userID, id)
+	if err != nil {
+		return err
+	}
+	r.store.unlinkAddress(a)
+	delete(r.store.addresses, id)
+	r.store.touchUsers(userID)
+	r.store.appendEvent(outbox.EventAddressDeleted, userID, a)
+	return nil
+}
+
+func (r *addressRepo) ReplaceAddresses(ctx context.Context, userID string, adds []entities.Address) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[userID]; !ok {
+		return gorm.ErrForeignKeyViolated
+	}
+	kept := make(map[int]struct{}, len(adds))
+	for _, a := range adds {
+		if a.ID == 0 {
+			continue
+		}
+		if _, err := r.ownAddress(userID, a.ID); err != nil {
+			return err
+		}
+		kept[a.ID] = struct{}{}
+	}
+	r.store.touchUsers(userID)
+
+	for _, a := range r.store.addressesOf(userID) {
+		if _, ok := kept[a.ID]; !ok {
+			r.store.unlinkAddress(a)
+			delete(r.store.addresses, a.ID)
+			r.store.appendEvent(outbox.EventAddressDeleted, userID, a)
+		}
+	}
+	for i := range adds {
+		adds[i].UserID = userID
+		adds[i] = r.store.upsertAddress(adds[i])
+		r.store.appendEvent(outbox.EventAddressSaved, userID, adds[i])
+	}
+	return nil
+}
+
+// GetOrphanAddresses returns addresses whose user does not exist. The memory
+// store enforces the foreign key and cascades deletes, so this is normally empty.
+func (r *addressRepo) GetOrphanAddresses(ctx context.Context) ([]entities.Address, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var orphans []entities.Address
+	for userID := range r.store.userAddresses {
+		if _, ok := r.store.users[userID]; !ok {
+			orphans = append(orphans, r.store.addressesOf(userID)...)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].ID < orphans[j].ID })
+	return orphans, nil
+}
+
+func (r *addressRepo) DeleteOrphanAddresses(ctx context.Context) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for userID, ids := range r.store.userAddresses {
+		if _, ok := r.store.users[userID]; !ok {
+			deleted += int64(len(ids))
+			r.store.deleteAddressesOf(userID)
+		}
+	}
+	return deleted, nil
+}
+
+func (r *addressRepo) ClearAllAddressesDataFromDB() error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.addresses = make(map[int]entities.Address)
+	r.store.userAddresses = make(map[string][]int)
+	return nil
+}
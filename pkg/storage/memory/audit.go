@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sika/internal/audit"
+	"sika/pkg/storage/entities"
+	"time"
+)
+
+type auditRepo struct {
+	store *Store
+}
+
+func NewAuditRepo(store *Store) audit.Repo {
+	return &auditRepo{
+		store: store,
+	}
+}
+
+func (r *auditRepo) AppendEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	entry.ID = int64(len(r.store.auditLog) + 1)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	stored := *entry
+	stored.ChangedFields = append([]string(nil), entry.ChangedFields...)
+	r.store.auditLog = append(r.store.auditLog, stored)
+	return nil
+}
+
+func (r *auditRepo) AppendEntries(ctx context.Context, entries []entities.AuditEntry) error {
+	for _, e := range entries {
+		if err := r.AppendEntry(ctx, &e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *auditRepo) GetEntriesByUser(ctx context.Context, userID string) ([]entities.AuditEntry, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var entries []entities.AuditEntry
+	for _, e := range r.store.auditLog {
+		if e.UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
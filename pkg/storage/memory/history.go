@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sika/internal/history"
+	"sika/pkg/storage/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type historyRepo struct {
+	store *Store
+}
+
+func NewHistoryRepo(store *Store) history.Repo {
+	return &historyRepo{
+		store: store,
+	}
+}
+
+func (r *historyRepo) AppendVersion(ctx context.Context, v *entities.UserVersion) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	versions := r.store.history[v.UserID]
+	if n := len(versions); n > 0 && versions[n-1].ValidTo == nil {
+		validTo := v.ValidFrom
+		versions[n-1].ValidTo = &validTo
+	}
+	v.Version = len(versions) + 1
+	stored := *v
+	stored.Addresses = append([]entities.Address(nil), v.Addresses...)
+	r.store.history[v.UserID] = append(versions, stored)
+	return nil
+}
+
+func (r *historyRepo) GetCurrentVersion(ctx context.Context, userID string) (*entities.UserVersion, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	versions := r.store.history[userID]
+	if n := len(versions); n > 0 && versions[n-1].ValidTo == nil {
+		v := versions[n-1]
+		return &v, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *historyRepo) GetVersions(ctx context.Context, userID string) ([]entities.UserVersion, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return append([]entities.UserVersion(nil), r.store.history[userID]...), nil
+}
+
+func (r *historyRepo) GetVersionAt(ctx context.Context, userID string, at time.Time) (*entities.UserVersion, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	versions := r.store.history[userID]
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if !v.ValidFrom.After(at) && (v.ValidTo == nil || v.ValidTo.After(at)) {
+			return &v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *historyRepo) DeleteVersions(ctx context.Context, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.history, userID)
+	return nil
+}
+
+func (r *historyRepo) CloseVersions(ctx context.Context, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, versions := range r.store.history {
+		if n := len(versions); n > 0 && versions[n-1].ValidTo == nil {
+			validTo := at
+			versions[n-1].ValidTo = &validTo
+		}
+	}
+	return nil
+}
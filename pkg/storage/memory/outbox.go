@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"sika/internal/outbox"
+	"sika/pkg/storage/entities"
+	"slices"
+)
+
+type outboxRepo struct {
+	store *Store
+}
+
+func NewOutboxRepo(store *Store) outbox.Repo {
+	return &outboxRepo{
+		store: store,
+	}
+}
+
+func (r *outboxRepo) GetPendingEvents(ctx context.Context, limit int, skipUsers []string) ([]entities.OutboxEvent, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var events []entities.OutboxEvent
+	for _, e := range r.store.outbox {
+		if len(events) == limit {
+			break
+		}
+		if !slices.Contains(skipUsers, e.UserID) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (r *outboxRepo) DeleteEvents(ctx context.Context, sequences []int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	remove := make(map[int64]struct{}, len(sequences))
+	for _, seq := range sequences {
+		remove[seq] = struct{}{}
+	}
+	kept := r.store.outbox[:0]
+	for _, e := range r.store.outbox {
+		if _, ok := remove[e.Sequence]; !ok {
+			kept = append(kept, e)
+		}
+	}
+	r.store.outbox = kept
+	return nil
+}
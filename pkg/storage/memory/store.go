@@ -0,0 +1,177 @@
+// Package memory provides thread-safe in-memory implementations of user.Repo,
+// address.Repo, audit.Repo, history.Repo and outbox.Repo for tests, benchmarks and the
+// database-less demo mode.
+package memory
+
+import (
+	"context"
+	"sika/internal/outbox"
+	"sika/pkg/storage/entities"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Store holds the users and addresses shared by the repos built on it, so
+// that reading a user can preload its addresses like the GORM repos do.
+type Store struct {
+	// txMu serializes the transactions of InTx
+	txMu          sync.Mutex
+	mu            sync.RWMutex
+	users         map[string]entities.User
+	addresses     map[int]entities.Address
+	userAddresses map[string][]int
+	nextAddressID int
+	auditLog      []entities.AuditEntry
+	history       map[string][]entities.UserVersion
+	outbox        []entities.OutboxEvent
+	nextSequence  int64
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:         make(map[string]entities.User),
+		addresses:     make(map[int]entities.Address),
+		userAddresses: make(map[string][]int),
+		nextAddressID: 1,
+		history:       make(map[string][]entities.UserVersion),
+		nextSequence:  1,
+	}
+}
+
+type txKey struct{}
+
+// InTx runs fn as a transaction, one at a time, so that the writes of fn
+// are not interleaved with those of another transaction. The store cannot
+// roll back: a failing write changes nothing, but the writes fn made before
+// it are kept. Audit and history appends never fail, so a change is never
+// left without them. Within a transaction fn joins it.
+func (s *Store) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	return fn(context.WithValue(ctx, txKey{}, true))
+}
+
+// appendEvent records an outbox event along with a change. Payloads are
+// plain entities and always encode, the error is ignored. Callers must hold mu.
+func (s *Store) appendEvent(eventType, userID string, payload any) {
+	event, _ := outbox.NewEvent(eventType, userID, payload)
+	event.Sequence = s.nextSequence
+	event.CreatedAt = time.Now()
+	s.nextSequence++
+	s.outbox = append(s.outbox, event)
+}
+
+// upsertUser stores u without its addresses and returns the stored copy with
+// timestamps maintained like the GORM repo. Callers must hold mu.
+func (s *Store) upsertUser(u entities.User) entities.User {
+	now := time.Now()
+	u.Addresses = nil
+	if old, ok := s.users[u.ID]; ok {
+		u.CreatedAt = old.CreatedAt
+		u.Version = old.Version + 1
+	} else {
+		if u.CreatedAt.IsZero() {
+			u.CreatedAt = now
+		}
+		u.Version = 1
+	}
+	u.UpdatedAt = now
+	u.DeletedAt = gorm.DeletedAt{}
+	s.users[u.ID] = u
+	return u
+}
+
+// emailTaken reports whether an active user other than id has the email,
+// ignoring case. Like the unique index of the databases it does not apply
+// to empty emails. Callers must hold mu.
+func (s *Store) emailTaken(email, id string) bool {
+	if email == "" {
+		return false
+	}
+	for _, u := range s.users {
+		if u.ID != id && !u.DeletedAt.Valid && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// touchUsers increments the version of the users for writes of their
+// addresses. Callers must hold mu.
+func (s *Store) touchUsers(userIDs ...string) {
+	for _, id := range userIDs {
+		if u, ok := s.users[id]; ok {
+			u.Version++
+			s.users[id] = u
+		}
+	}
+}
+
+// upsertAddress stores a, assigning an ID when it has none, and returns the
+// stored copy. Callers must hold mu.
+func (s *Store) upsertAddress(a entities.Address) entities.Address {
+	now := time.Now()
+	if a.ID == 0 {
+		a.ID = s.nextAddressID
+	}
+	if a.ID >= s.nextAddressID {
+		s.nextAddressID = a.ID + 1
+	}
+	old, exists := s.addresses[a.ID]
+	if exists && old.UserID != a.UserID {
+		s.unlinkAddress(old)
+	}
+	if !exists || old.UserID != a.UserID {
+		s.userAddresses[a.UserID] = append(s.userAddresses[a.UserID], a.ID)
+	}
+	if exists {
+		a.CreatedAt = old.CreatedAt
+	} else if a.CreatedAt.IsZero() {
+		a.CreatedAt = now
+	}
+	a.UpdatedAt = now
+	s.addresses[a.ID] = a
+	return a
+}
+
+// unlinkAddress removes a from its user's index. Callers must hold mu.
+func (s *Store) unlinkAddress(a entities.Address) {
+	ids := s.userAddresses[a.UserID]
+	for i, id := range ids {
+		if id == a.ID {
+			s.userAddresses[a.UserID] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(s.userAddresses[a.UserID]) == 0 {
+		delete(s.userAddresses, a.UserID)
+	}
+}
+
+// deleteAddressesOf removes all addresses of the user, like the ON DELETE
+// CASCADE foreign key does. Callers must hold mu.
+func (s *Store) deleteAddressesOf(userID string) {
+	for _, id := range s.userAddresses[userID] {
+		delete(s.addresses, id)
+	}
+	delete(s.userAddresses, userID)
+}
+
+// addressesOf returns copies of the user's addresses ordered by ID. Callers
+// must hold at least a read lock.
+func (s *Store) addressesOf(userID string) []entities.Address {
+	ids := s.userAddresses[userID]
+	addresses := make([]entities.Address, 0, len(ids))
+	for _, id := range ids {
+		addresses = append(addresses, s.addresses[id])
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].ID < addresses[j].ID })
+	return addresses
+}
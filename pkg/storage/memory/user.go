@@ -0,0 +1,350 @@
+package memory
+
+import (
+	"context"
+	"sika/internal/outbox"
+	"sika/internal/user"
+	"sika/pkg/filter"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type userRepo struct {
+	store *Store
+}
+
+// NewUserRepo returns a repo whose errors are translated by
+// storage.UserError like those of the database repos.
+func NewUserRepo(store *Store) user.Repo {
+	return storage.WithUserErrors(&userRepo{
+		store: store,
+	})
+}
+
+// CreateUser upserts u and any addresses attached to it. Like the GORM repo it
+// keeps created_at of an existing user and clears a soft delete.
+func (r *userRepo) CreateUser(ctx context.Context, u *entities.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.store.emailTaken(u.Email, u.ID) {
+		return user.ErrEmailTaken
+	}
+	eventType := outbox.EventUserCreated
+	if _, ok := r.store.users[u.ID]; ok {
+		eventType = outbox.EventUserUpdated
+	}
+
+	addresses := u.Addresses
+	*u = r.store.upsertUser(*u)
+	u.Addresses = addresses
+
+	for i := range u.Addresses {
+		u.Addresses[i].UserID = u.ID
+		u.Addresses[i] = r.store.upsertAddress(u.Addresses[i])
+	}
+	r.store.appendEvent(eventType, u.ID, u)
+	return nil
+}
+
+// CreateBatchUsers inserts all users or none, failing on an existing ID or
+// an email taken by an active user or by another user of the batch.
+func (r *userRepo) CreateBatchUsers(ctx context.Context, users []entities.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	seen := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		if _, ok := r.store.users[u.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+		if _, ok := seen[u.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+		seen[u.ID] = struct{}{}
+	}
+	emails := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		if r.store.emailTaken(u.Email, u.ID) {
+			return user.ErrEmailTaken
+		}
+		if u.Email == "" {
+			continue
+		}
+		if _, ok := emails[strings.ToLower(u.Email)]; ok {
+			return user.ErrEmailTaken
+		}
+		emails[strings.ToLower(u.Email)] = struct{}{}
+	}
+
+	for i := range users {
+		addresses := users[i].Addresses
+		users[i] = r.store.upsertUser(users[i])
+		users[i].Addresses = addresses
+		for j := range users[i].Addresses {
+			users[i].Addresses[j].UserID = users[i].ID
+			users[i].Addresses[j] = r.store.upsertAddress(users[i].Addresses[j])
+		}
+		r.store.appendEvent(outbox.EventUserCreated, users[i].ID, users[i])
+	}
+	return nil
+}
+
+func (r *userRepo) GetUserByID(ctx context.Context, id string) (*entities.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	u, ok := r.store.users[id]
+	if !ok || u.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	u.Addresses = r.store.addressesOf(id)
+	return &u, nil
+}
+
+func (r *userRepo) GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	users := []entities.User{}
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		u, ok := r.store.users[id]
+		if _, dup := seen[id]; !ok || dup || u.DeletedAt.Valid {
+			continue
+		}
+		seen[id] = struct{}{}
+		u.Addresses = r.store.addressesOf(id)
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	u, ok := r.store.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	u.Addresses = r.store.addressesOf(id)
+	return &u, nil
+}
+
+// GetUserProjection copies only what p selects out of the store.
+func (r *userRepo) GetUserProjection(ctx context.Context, id string, p user.Projection, includeDeleted bool) (*entities.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	u, ok := r.store.users[id]
+	if !ok || (u.DeletedAt.Valid && !includeDeleted) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if p.IncludeAddresses {
+		u.Addresses = r.store.addressesOf(id)
+	}
+	u = p.Apply(u)
+	return &u, nil
+}
+
+func (r *userRepo) GetAllUserIDs(ctx context.Context) ([]string, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.store.users))
+	for id, u := range r.store.users {
+		if !u.DeletedAt.Valid {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ListUsers sorts the active users in Go on every call, which is fine for the
+// sizes the memory store is used with.
+func (r *userRepo) ListUsers(ctx context.Context, q user.ListQuery) ([]entities.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var after entities.User
+	if q.After != nil {
+		after = q.After.User()
+	}
+	users := []entities.User{}
+	for _, u := range r.store.users {
+		if u.DeletedAt.Valid {
+			continue
+		}
+		if q.Filter != nil {
+			record := user.FilterRecord{User: u, Addresses: r.store.addressesOf(u.ID)}
+			if !filter.Eval(q.Filter, record) {
+				continue
+			}
+		}
+		if q.After != nil {
+			c := user.CompareUsers(u, after, q.SortBy)
+			if (!q.Descending && c <= 0) || (q.Descending && c >= 0) {
+				continue
+			}
+		}
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		c := user.CompareUsers(users[i], users[j], q.SortBy)
+		if q.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+	if len(users) > q.Limit {
+		users = users[:q.Limit]
+	}
+	for i := range users {
+		if q.IncludeAddresses {
+			users[i].Addresses = r.store.addressesOf(users[i].ID)
+		}
+		users[i] = q.Apply(users[i], q.SortBy)
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var found *entities.User
+	for _, u := range r.store.users {
+		if u.DeletedAt.Valid || !strings.EqualFold(u.Email, email) {
+			continue
+		}
+		if found == nil || u.ID < found.ID {
+			u := u
+			found = &u
+		}
+	}
+	if found == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return found, nil
+}
+
+func (r *userRepo) UpdateUser(ctx context.Context, u *entities.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, err := r.activeUser(u.ID, u.Version)
+	if err != nil {
+		return err
+	}
+	if r.store.emailTaken(u.Email, u.ID) {
+		return user.ErrEmailTaken
+	}
+	stored.Name, stored.Email, stored.PhoneNumber = u.Name, u.Email, u.PhoneNumber
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	r.store.users[u.ID] = stored
+
+	u.CreatedAt, u.UpdatedAt, u.DeletedAt = stored.CreatedAt, stored.UpdatedAt, stored.DeletedAt
+	u.Version = stored.Version
+	r.store.appendEvent(outbox.EventUserUpdated, u.ID, u)
+	return nil
+}
+
+func (r *userRepo) DeleteUser(ctx context.Context, id string, version int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	u, err := r.activeUser(id, version)
+	if err != nil {
+		return err
+	}
+	u.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	u.Version++
+	r.store.users[id] = u
+	r.store.appendEvent(outbox.EventUserDeleted, id, outbox.IDPayload{ID: id})
+	return nil
+}
+
+// activeUser returns the active user id for a write at version, which
+// matches any version when it is 0. Callers must hold mu.
+func (r *userRepo) activeUser(id string, version int64) (entities.User, error) {
+	u, ok := r.store.users[id]
+	if !ok || u.DeletedAt.Valid {
+		return entities.User{}, gorm.ErrRecordNotFound
+	}
+	if version != 0 && u.Version != version {
+		return entities.User{}, user.ErrVersionMismatch
+	}
+	return u, nil
+}
+
+func (r *userRepo) PurgeUser(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.store.users, id)
+	r.store.deleteAddressesOf(id)
+	r.store.appendEvent(outbox.EventUserPurged, id, outbox.IDPayload{ID: id})
+	return nil
+}
+
+func (r *userRepo) RestoreUser(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	u, ok := r.store.users[id]
+	if !ok || !u.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	if r.store.emailTaken(u.Email, id) {
+		return user.ErrEmailTaken
+	}
+	u.DeletedAt = gorm.DeletedAt{}
+	u.UpdatedAt = time.Now()
+	u.Version++
+	r.store.users[id] = u
+	r.store.appendEvent(outbox.EventUserRestored, id, outbox.IDPayload{ID: id})
+	return nil
+}
+
+// SearchUsers scores the active users in Go, see user.SearchScore. Addresses
+// are not loaded.
+func (r *userRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	users := make([]entities.User, 0, len(r.store.users))
+	for _, u := range r.store.users {
+		if !u.DeletedAt.Valid {
+			users = append(users, u)
+		}
+	}
+	return user.RankUsers(users, query, limit, offset), nil
+}
+
+func (r *userRepo) ClearAllUsersDataFromDB(ctx context.Context) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	ids := make([]string, 0, len(r.store.users))
+	for id := range r.store.users {
+		r.store.deleteAddressesOf(id)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		r.store.appendEvent(outbox.EventUserPurged, id, outbox.IDPayload{ID: id})
+	}
+	r.store.users = make(map[string]entities.User)
+	return nil
+}
@@ -0,0 +1,447 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUserRepo_GetUserByIDPreloadsAddresses(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Test User"}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2", Name: "Other User"}))
+	addrs := []entities.Address{
+		{UserID: "1", Street: "1 Test St"},
+		{UserID: "1", Street: "2 Test St"},
+		{UserID: "2", Street: "3 Other St"},
+	}
+	require.NoError(t, addressRepo.CreateBatchAddresses(ctx, addrs))
+	assert.Equal(t, 1, addrs[0].ID)
+	assert.Equal(t, 2, addrs[1].ID)
+	assert.Equal(t, 3, addrs[2].ID)
+
+	got, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Test User", got.Name)
+	assert.Equal(t, []entities.Address{addrs[0], addrs[1]}, got.Addresses)
+
+	_, err = userRepo.GetUserByID(ctx, "3")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserRepo_CreateUserUpserts(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Old"}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "New"}))
+
+	got, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", got.Name)
+}
+
+func TestUserRepo_CreateBatchUsersConflict(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+
+	err := userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "2"}, {ID: "1"}})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	// the batch is all or nothing
+	_, err = userRepo.GetUserByID(ctx, "2")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "3"}, {ID: "3"}})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestAddressRepo_CreateBatchAddressesConflict(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, addressRepo.CreateAddress(ctx, &entities.Address{ID: 5, UserID: "1"}))
+
+	err := addressRepo.CreateBatchAddresses(ctx, []entities.Address{{ID: 5, UserID: "1"}})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	// new addresses continue after the highest explicit ID
+	a := &entities.Address{UserID: "1"}
+	require.NoError(t, addressRepo.CreateAddress(ctx, a))
+	assert.Equal(t, 6, a.ID)
+}
+
+func TestAddressRepo_ForeignKey(t *testing.T) {
+	store := NewStore()
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	err := addressRepo.CreateAddress(ctx, &entities.Address{UserID: "missing"})
+	assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+
+	err = addressRepo.CreateBatchAddresses(ctx, []entities.Address{{UserID: "missing"}})
+	assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+}
+
+func TestUserRepo_PurgeUserCascades(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{
+		ID:        "1",
+		Addresses: []entities.Address{{Street: "1 Test St"}, {Street: "2 Test St"}},
+	}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{
+		ID:        "2",
+		Addresses: []entities.Address{{Street: "3 Test St"}},
+	}))
+
+	require.NoError(t, userRepo.PurgeUser(ctx, "1"))
+	assert.ErrorIs(t, userRepo.PurgeUser(ctx, "1"), gorm.ErrRecordNotFound)
+
+	addrs, err := addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, addrs)
+	addrs, err = addressRepo.GetAddressByUser(ctx, "2")
+	require.NoError(t, err)
+	assert.Len(t, addrs, 1)
+
+	orphans, err := addressRepo.GetOrphanAddresses(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+}
+
+func TestUserRepo_SoftDeleteAndRestore(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	u := &entities.User{ID: "1", Addresses: []entities.Address{{Street: "1 Test St"}}}
+	require.NoError(t, userRepo.CreateUser(ctx, u))
+	assert.False(t, u.CreatedAt.IsZero())
+	assert.False(t, u.Addresses[0].CreatedAt.IsZero())
+
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	assert.ErrorIs(t, userRepo.DeleteUser(ctx, "1", 0), gorm.ErrRecordNotFound)
+
+	_, err := userRepo.GetUserByID(ctx, "1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	ids, err := userRepo.GetAllUserIDs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	deleted, err := userRepo.GetUserByIDWithDeleted(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, deleted.DeletedAt.Valid)
+	assert.Len(t, deleted.Addresses, 1)
+
+	require.NoError(t, userRepo.RestoreUser(ctx, "1"))
+	assert.ErrorIs(t, userRepo.RestoreUser(ctx, "1"), gorm.ErrRecordNotFound)
+
+	restored, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+	assert.Equal(t, u.CreatedAt, restored.CreatedAt)
+	assert.Len(t, restored.Addresses, 1)
+}
+
+func TestStore_Clear(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{
+		ID:        "1",
+		Addresses: []entities.Address{{Street: "1 Test St"}},
+	}))
+
+	require.NoError(t, userRepo.ClearAllUsersDataFromDB(ctx))
+	ids, err := userRepo.GetAllUserIDs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// clearing users cascades to their addresses
+	addrs, err := addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, addrs)
+}
+
+func TestStore_ConcurrentAccess(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprint(i)
+			assert.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: id}))
+			assert.NoError(t, addressRepo.CreateBatchAddresses(ctx, []entities.Address{{UserID: id}, {UserID: id}}))
+			_, err := userRepo.GetUserByID(ctx, id)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	ids, err := userRepo.GetAllUserIDs(ctx)
+	require.NoError(t, err)
+	assert.Len(t, ids, 50)
+	for _, id := range ids {
+		u, err := userRepo.GetUserByID(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, u.Addresses, 2)
+	}
+}
+
+func TestUserRepo_ListUsers(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	for _, u := range []entities.User{
+		{ID: "1", Name: "Carol", Addresses: []entities.Address{{Street: "1 Test St"}}},
+		{ID: "2", Name: "Alice"},
+		{ID: "3", Name: "Bob"},
+		{ID: "4", Name: "Alice"},
+	} {
+		u := u
+		require.NoError(t, userRepo.CreateUser(ctx, &u))
+	}
+	require.NoError(t, userRepo.DeleteUser(ctx, "3", 0))
+
+	q := user.ListQuery{SortBy: user.SortByName, Limit: 2, Projection: user.Projection{IncludeAddresses: true}}
+	first, err := userRepo.ListUsers(ctx, q)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, "2", first[0].ID)
+	assert.Equal(t, "4", first[1].ID)
+
+	after := user.CursorAfter(first[1], user.SortByName, false)
+	q.After = &after
+	second, err := userRepo.ListUsers(ctx, q)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Equal(t, "1", second[0].ID)
+	assert.Len(t, second[0].Addresses, 1)
+}
+
+func TestUserRepo_ListUsersFilter(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	for _, u := range []entities.User{
+		{ID: "1", Name: "Ana", Addresses: []entities.Address{{Country: "AR", City: "Glendale"}, {Country: "AR"}, {Country: "UY"}}},
+		{ID: "2", Name: "Bea", Addresses: []entities.Address{{Country: "AR", City: "Glenwood"}}},
+		{ID: "3", Name: "Cid"},
+	} {
+		u := u
+		require.NoError(t, userRepo.CreateUser(ctx, &u))
+	}
+
+	f, err := user.ParseFilter("country = AR and city startswith 'Glen' and address_count > 2 or address_count = 0")
+	require.NoError(t, err)
+	page, err := userRepo.ListUsers(ctx, user.ListQuery{SortBy: user.SortByID, Limit: 10, Filter: f})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "1", page[0].ID)
+	assert.Equal(t, "3", page[1].ID)
+}
+
+func TestUserRepo_UpdateUserAndGetUserByEmail(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Old", Email: "old@example.com"}))
+
+	found, err := userRepo.GetUserByEmail(ctx, "OLD@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "1", found.ID)
+
+	require.NoError(t, userRepo.UpdateUser(ctx, &entities.User{ID: "1", Name: "New", Email: "new@example.com"}))
+	stored, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", stored.Name)
+	_, err = userRepo.GetUserByEmail(ctx, "old@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, &entities.User{ID: "1"}), gorm.ErrRecordNotFound)
+}
+
+func TestUserRepo_EmailUnique(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateBatchUsers(ctx, []entities.User{
+		{ID: "1", Email: "taken@example.com"},
+		{ID: "2", Email: "free@example.com"},
+		{ID: "3"},
+		{ID: "4"},
+	}))
+
+	err := userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "5", Email: "TAKEN@example.com"}})
+	assert.ErrorIs(t, err, user.ErrEmailTaken)
+	err = userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "5", Email: "new@example.com"}, {ID: "6", Email: "New@example.com"}})
+	assert.ErrorIs(t, err, user.ErrEmailTaken)
+	assert.ErrorIs(t, userRepo.CreateUser(ctx, &entities.User{ID: "2", Email: "taken@example.com"}), user.ErrEmailTaken)
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, &entities.User{ID: "2", Email: "Taken@Example.com"}), user.ErrEmailTaken)
+	_, err = userRepo.GetUserByID(ctx, "5")
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	require.NoError(t, userRepo.UpdateUser(ctx, &entities.User{ID: "2", Email: "taken@example.com"}))
+	assert.ErrorIs(t, userRepo.RestoreUser(ctx, "1"), user.ErrEmailTaken)
+}
+
+func TestUserRepo_Versions(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	// every write of the user or of its addresses increments the version
+	u := &entities.User{ID: "1", Name: "Old", Email: "old@example.com"}
+	require.NoError(t, userRepo.CreateUser(ctx, u))
+	stored, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stored.Version)
+
+	updated := &entities.User{ID: "1", Name: "New", Email: "new@example.com", Version: 1}
+	require.NoError(t, userRepo.UpdateUser(ctx, updated))
+	assert.Equal(t, int64(2), updated.Version)
+	stale := &entities.User{ID: "1", Name: "Stale", Version: 1}
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, stale), user.ErrVersionMismatch)
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, &entities.User{ID: "2", Version: 1}), user.ErrNotFound)
+
+	a := &entities.Address{UserID: "1", Street: "1 Test St"}
+	require.NoError(t, addressRepo.CreateAddress(ctx, a))
+	a.Street = "2 Test St"
+	require.NoError(t, addressRepo.UpdateAddress(ctx, a))
+	require.NoError(t, addressRepo.DeleteAddress(ctx, "1", a.ID))
+	stored, err = userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", stored.Name)
+	assert.Equal(t, int64(5), stored.Version)
+
+	assert.ErrorIs(t, userRepo.DeleteUser(ctx, "1", 2), user.ErrVersionMismatch)
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 5))
+	assert.ErrorIs(t, userRepo.DeleteUser(ctx, "1", 6), user.ErrNotFound)
+	require.NoError(t, userRepo.RestoreUser(ctx, "1"))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Imported"}))
+	stored, err = userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), stored.Version)
+}
+
+func TestAddressRepo_AddressesOfUser(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2"}))
+	first := &entities.Address{UserID: "1", Street: "1 Test St"}
+	second := &entities.Address{UserID: "1", Street: "2 Test St"}
+	other := &entities.Address{UserID: "2", Street: "3 Test St"}
+	for _, a := range []*entities.Address{first, second, other} {
+		require.NoError(t, addressRepo.CreateAddress(ctx, a))
+	}
+
+	_, err := addressRepo.GetAddress(ctx, "1", other.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, addressRepo.UpdateAddress(ctx, &entities.Address{ID: other.ID, UserID: "1"}), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, addressRepo.DeleteAddress(ctx, "1", other.ID), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, addressRepo.ReplaceAddresses(ctx, "1", []entities.Address{{ID: other.ID}}), gorm.ErrRecordNotFound)
+
+	set := []entities.Address{{ID: first.ID, Street: "1 New St"}, {Street: "4 Test St"}}
+	require.NoError(t, addressRepo.ReplaceAddresses(ctx, "1", set))
+	assert.Equal(t, first.CreatedAt, set[0].CreatedAt)
+	assert.NotZero(t, set[1].ID)
+
+	addrs, err := addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, addrs, 2)
+	assert.Equal(t, "1 New St", addrs[0].Street)
+	assert.Equal(t, "4 Test St", addrs[1].Street)
+
+	require.NoError(t, addressRepo.DeleteAddress(ctx, "1", first.ID))
+	addrs, err = addressRepo.GetAddressByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, addrs, 1)
+
+	addrs, err = addressRepo.GetAddressByUser(ctx, "2")
+	require.NoError(t, err)
+	assert.Len(t, addrs, 1)
+}
+
+func TestUserRepo_GetUsersByIDs(t *testing.T) {
+	store := NewStore()
+	repo := NewUserRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateBatchUsers(ctx, []entities.User{
+		{ID: "1", Addresses: []entities.Address{{Street: "1 Test St"}}},
+		{ID: "2"},
+	}))
+	require.NoError(t, repo.DeleteUser(ctx, "2", 0))
+
+	users, err := repo.GetUsersByIDs(ctx, []string{"1", "2", "404", "1"})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "1", users[0].ID)
+	assert.Len(t, users[0].Addresses, 1)
+}
+
+func TestUserRepo_GetUserProjection(t *testing.T) {
+	store := NewStore()
+	userRepo := NewUserRepo(store)
+	addressRepo := NewAddressRepo(store)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Test User", Email: "test@example.com"}))
+	require.NoError(t, addressRepo.CreateAddress(ctx, &entities.Address{UserID: "1", Street: "1 Test St"}))
+
+	u, err := userRepo.GetUserProjection(ctx, "1", user.Projection{Fields: []string{"email"}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, entities.User{ID: "1", Email: "test@example.com", Version: 2}, *u)
+
+	u, err = userRepo.GetUserProjection(ctx, "1", user.Projection{IncludeAddresses: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "Test User", u.Name)
+	require.Len(t, u.Addresses, 1)
+
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	_, err = userRepo.GetUserProjection(ctx, "1", user.Projection{}, false)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = userRepo.GetUserProjection(ctx, "1", user.Projection{}, true)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this binary does not know about, i.e. it was migrated by a newer release.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// ErrDuplicateEmails is returned by the migration adding the unique index on
+// emails while active users share an email. The data is left as it is: which
+// of the users keeps the email is for an operator to decide.
+var ErrDuplicateEmails = errors.New("active users share an email")
+
+// migrationChecks are run before the migration of the same name and fail it
+// when the data does not allow it.
+var migrationChecks = map[string]func(tx *gorm.DB) error{
+	"users_email_unique": checkUniqueEmails,
+}
+
+// maxListedDuplicates caps the emails listed by checkUniqueEmails.
+const maxListedDuplicates = 20
+
+// checkUniqueEmails returns ErrDuplicateEmails listing the emails, ignoring
+// case, used by more than one active user and the IDs of those users.
+func checkUniqueEmails(tx *gorm.DB) error {
+	var rows []struct {
+		Email string
+		ID    string
+	}
+	err := tx.Raw(`SELECT lower(email) AS email, id FROM users
+WHERE deleted_at IS NULL AND lower(email) IN (
+    SELECT lower(email) FROM users
+    WHERE deleted_at IS NULL AND email <> ''
+    GROUP BY lower(email) HAVING count(*) > 1
+)
+ORDER BY lower(email), id`).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to look for duplicate emails: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var emails []string
+	ids := map[string][]string{}
+	for _, r := range rows {
+		if _, ok := ids[r.Email]; !ok {
+			emails = append(emails, r.Email)
+		}
+		ids[r.Email] = append(ids[r.Email], r.ID)
+	}
+	listed := make([]string, 0, maxListedDuplicates)
+	for _, email := range emails[:min(len(emails), maxListedDuplicates)] {
+		listed = append(listed, fmt.Sprintf("%s (users %s)", email, strings.Join(ids[email], ", ")))
+	}
+	if more := len(emails) - len(listed); more > 0 {
+		listed = append(listed, fmt.Sprintf("and %d more", more))
+	}
+	return fmt.Errorf("%w, change or delete all but one of each and migrate again: %s", ErrDuplicateEmails, strings.Join(listed, "; "))
+}
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate is run on startup. It refuses to run against a schema newer than
+// this binary and then applies every pending migration in order.
+func Migrate(db *gorm.DB) error {
+	return MigrateUp(db)
+}
+
+// CheckSchema returns ErrSchemaTooNew when the database has a migration
+// applied that is not embedded in this binary.
+func CheckSchema(db *gorm.DB) error {
+	_, _, err := checkedMigrations(db)
+	return err
+}
+
+// CheckPendingMigrations runs the check of the next pending migration, the
+// only one whose schema is in place, without applying it. It finds the data
+// an upgrade would fail on while the running release can still change it.
+func CheckPendingMigrations(db *gorm.DB) error {
+	migrations, applied, err := checkedMigrations(db)
+	if err != nil {
+		return err
+	}
+	done := make(map[int]struct{}, len(applied))
+	for _, a := range applied {
+		done[a.Version] = struct{}{}
+	}
+	for _, m := range migrations {
+		if _, ok := done[m.version]; ok {
+			continue
+		}
+		if check, ok := migrationChecks[m.name]; ok {
+			if err := check(db); err != nil {
+				return fmt.Errorf("migration %04d_%s cannot run: %w", m.version, m.name, err)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// checkedMigrations returns the known and the applied migrations after
+// checking that no unknown migration is applied.
+func checkedMigrations(db *gorm.DB) ([]migration, []schemaMigration, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	known := make(map[int]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = struct{}{}
+	}
+	for _, a := range applied {
+		if _, ok := known[a.Version]; !ok {
+			return nil, nil, fmt.Errorf("%w: unknown migration %04d_%s is applied", ErrSchemaTooNew, a.Version, a.Name)
+		}
+	}
+	return migrations, applied, nil
+}
+
+// MigrateUp applies all pending migrations, each in its own transaction on
+// Postgres. It holds the migration lock from reading schema_migrations to
+// the last migration, so processes starting together apply each once.
+func MigrateUp(db *gorm.DB) error {
+	return withMigrationLock(db, func(conn *gorm.DB, step stepFunc) error {
+		migrations, applied, err := checkedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		done := make(map[int]struct{}, len(applied))
+		for _, a := range applied {
+			done[a.Version] = struct{}{}
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.version]; ok {
+				continue
+			}
+			err := step(func(tx *gorm.DB) error {
+				if check, ok := migrationChecks[m.name]; ok {
+					if err := check(tx); err != nil {
+						return err
+					}
+				}
+				if err := tx.Exec(m.up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the latest steps applied migrations, newest first,
+// under the migration lock.
+func MigrateDown(db *gorm.DB, steps int) error {
+	return withMigrationLock(db, func(conn *gorm.DB, step stepFunc) error {
+		migrations, applied, err := checkedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		byVersion := make(map[int]migration, len(migrations))
+		for _, m := range migrations {
+			byVersion[m.version] = m
+		}
+
+		for i := len(applied) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+			m := byVersion[applied[i].Version]
+			err := step(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, m.version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of migration %04d_%s failed: %w", m.version, m.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatuses lists every known migration and when it was applied.
+// Applied migrations unknown to this binary are included at the end.
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]schemaMigration, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := appliedAt[m.version]; ok {
+			s.AppliedAt = &a.AppliedAt
+			delete(appliedAt, m.version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range applied {
+		if _, unknown := appliedAt[a.Version]; unknown {
+			a := a
+			statuses = append(statuses, MigrationStatus{Version: a.Version, Name: a.Name, AppliedAt: &a.AppliedAt})
+		}
+	}
+	return statuses, nil
+}
+
+func appliedMigrations(db *gorm.DB) ([]schemaMigration, error) {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var applied []schemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations table: %w", err)
+	}
+	return applied, nil
+}
+
+// migrationLockID is the key of the Postgres advisory lock taken while
+// migrating, chosen to not collide with other users of advisory locks.
+const migrationLockID int64 = 0x73696b61_6d696772 // "sikamigr"
+
+// stepFunc runs one migration step.
+type stepFunc func(fn func(tx *gorm.DB) error) error
+
+// withMigrationLock runs fn on a single connection holding the migration
+// lock. On Postgres that is a session advisory lock, and step runs each
+// migration in its own transaction. SQLite has no such lock, so fn runs in
+// one exclusive transaction that step joins, and a failed migration rolls
+// back the ones before it. Foreign key checks are off meanwhile on SQLite,
+// to let migrations rebuild referenced tables. The pragma is a no-op inside
+// a transaction, so it is toggled around it.
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB, step stepFunc) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		if db.Dialector.Name() != "sqlite" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+				return fmt.Errorf("failed to take the migration lock: %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+			return fn(conn, func(step func(tx *gorm.DB) error) error {
+				return conn.Transaction(step)
+			})
+		}
+
+		if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+		// writes must not open transactions of their own inside this one
+		conn = conn.Session(&gorm.Session{SkipDefaultTransaction: true})
+		if err := conn.Exec("BEGIN EXCLUSIVE").Error; err != nil {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		if err := fn(conn, func(step func(tx *gorm.DB) error) error { return step(conn) }); err != nil {
+			conn.Exec("ROLLBACK")
+			return err
+		}
+		return conn.Exec("COMMIT").Error
+	})
+}
+
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, e := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+		v, label, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", e.Name(), err)
+		}
+		body, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: label}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sika/config"
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := NewGormConnection(config.DB{Driver: config.DriverSQLite, Path: ":memory:"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func indexExists(t *testing.T, db *gorm.DB, name string) bool {
+	var count int64
+	err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&count).Error
+	require.NoError(t, err)
+	return count > 0
+}
+
+func TestMigrate_UpDownStatus(t *testing.T) {
+	db := newTestDB(t)
+
+	require.NoError(t, Migrate(db))
+	// running again is a no-op
+	require.NoError(t, Migrate(db))
+
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+
+	statuses, err := MigrationStatuses(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations))
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, "migration %04d_%s", s.Version, s.Name)
+	}
+	assert.True(t, indexExists(t, db, "idx_addresses_user_id"))
+
+	// the foreign key rejects addresses of unknown users
+	err = db.Create(&entities.Address{UserID: "missing"}).Error
+	assert.Error(t, err)
+
+	// deleting a user cascades to its addresses
+	require.NoError(t, db.Create(&entities.User{ID: "1", Addresses: []entities.Address{{Street: "1 Test St"}}}).Error)
+	require.NoError(t, db.Unscoped().Delete(&entities.User{}, "id = ?", "1").Error)
+	var count int64
+	require.NoError(t, db.Model(&entities.Address{}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	// roll back to the initial schema
+	require.NoError(t, MigrateDown(db, len(migrations)-1))
+	statuses, err = MigrationStatuses(db)
+	require.NoError(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	for _, s := range statuses[1:] {
+		assert.Nil(t, s.AppliedAt, "migration %04d_%s", s.Version, s.Name)
+	}
+	assert.False(t, indexExists(t, db, "idx_addresses_user_id"))
+	assert.NoError(t, db.Exec("INSERT INTO addresses (user_id) VALUES ('missing')").Error)
+}
+
+func TestMigrate_AdoptsAutoMigratedSchema(t *testing.T) {
+	db := newTestDB(t)
+
+	// databases created before versioned migrations were set up by AutoMigrate
+	require.NoError(t, db.Exec("CREATE TABLE `users` (`id` text,`name` text,`email` text,`phone_number` text,PRIMARY KEY (`id`))").Error)
+	require.NoError(t, db.Exec("CREATE TABLE `addresses` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` text,`street` text,`city` text,`state` text,`zip_code` text,`country` text,"+
+		"CONSTRAINT `fk_users_addresses` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (id, name) VALUES ('1', 'Test User')").Error)
+	require.NoError(t, db.Exec("INSERT INTO addresses (user_id, street) VALUES ('1', '1 Test St')").Error)
+
+	require.NoError(t, Migrate(db))
+
+	var user entities.User
+	require.NoError(t, db.Preload("Addresses").First(&user, "id = ?", "1").Error)
+	assert.Len(t, user.Addresses, 1)
+	assert.False(t, user.CreatedAt.IsZero())
+}
+
+func TestMigrate_EmailUnique(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	// back to the schema without the unique email index
+	require.NoError(t, MigrateDown(db, 1))
+	require.NoError(t, db.Create([]entities.User{
+		{ID: "1", Email: "dup@example.com"},
+		{ID: "2", Email: "DUP@example.com"},
+		{ID: "3", Email: "other@example.com"},
+		{ID: "4"},
+		{ID: "5"},
+	}).Error)
+
+	// the duplicates are listed, and no user is changed
+	err := CheckPendingMigrations(db)
+	assert.ErrorIs(t, err, ErrDuplicateEmails)
+	err = Migrate(db)
+	assert.ErrorIs(t, err, ErrDuplicateEmails)
+	assert.ErrorContains(t, err, "dup@example.com (users 1, 2)")
+	assert.NotContains(t, err.Error(), "other@example.com")
+	var active int64
+	require.NoError(t, db.Model(&entities.User{}).Count(&active).Error)
+	assert.Equal(t, int64(5), active)
+	assert.False(t, indexExists(t, db, "idx_users_email_unique"))
+
+	// once the duplicate is resolved the index is created
+	require.NoError(t, NewUserRepo(db).DeleteUser(context.Background(), "2", 0))
+	require.NoError(t, CheckPendingMigrations(db))
+	require.NoError(t, Migrate(db))
+	assert.True(t, indexExists(t, db, "idx_users_email_unique"))
+	err = db.Create(&entities.User{ID: "6", Email: "Other@example.com"}).Error
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+
+	err := db.Create(&schemaMigration{Version: 9999, Name: "from_the_future", AppliedAt: time.Now()}).Error
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, Migrate(db), ErrSchemaTooNew)
+	assert.ErrorIs(t, MigrateDown(db, 1), ErrSchemaTooNew)
+
+	statuses, err := MigrationStatuses(db)
+	require.NoError(t, err)
+	assert.Equal(t, 9999, statuses[len(statuses)-1].Version)
+}
+
+func TestMigrate_ConcurrentProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sika.db")
+
+	// each process has a pool of its own on the same database file
+	const processes = 4
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		db, err := NewGormConnection(config.DB{Driver: config.DriverSQLite, Path: path})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		go func() {
+			errs <- Migrate(db)
+		}()
+	}
+	for i := 0; i < processes; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	db, err := NewGormConnection(config.DB{Driver: config.DriverSQLite, Path: path})
+	require.NoError(t, err)
+	defer func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	var applied, versions int64
+	require.NoError(t, db.Raw("SELECT count(*), count(DISTINCT version) FROM schema_migrations").Row().Scan(&applied, &versions))
+	assert.Equal(t, int64(len(migrations)), applied)
+	assert.Equal(t, applied, versions)
+}
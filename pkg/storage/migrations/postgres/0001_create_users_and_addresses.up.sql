@@ -0,0 +1,18 @@
+-- Matches the schema previously created by GORM AutoMigrate, so existing
+-- databases adopt versioned migrations without changes.
+CREATE TABLE IF NOT EXISTS users (
+    id           TEXT PRIMARY KEY,
+    name         TEXT,
+    email        TEXT,
+    phone_number TEXT
+);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id       BIGSERIAL PRIMARY KEY,
+    user_id  TEXT,
+    street   TEXT,
+    city     TEXT,
+    state    TEXT,
+    zip_code TEXT,
+    country  TEXT
+);
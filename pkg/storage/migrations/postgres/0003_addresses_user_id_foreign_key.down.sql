@@ -0,0 +1 @@
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS fk_users_addresses;
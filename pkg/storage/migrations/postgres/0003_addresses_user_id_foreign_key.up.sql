@@ -0,0 +1,6 @@
+-- Databases created by AutoMigrate may already carry this constraint, so it
+-- is recreated under a known name. NOT VALID enforces it for new rows without
+-- failing on orphan addresses left behind by earlier imports.
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS fk_users_addresses;
+ALTER TABLE addresses
+    ADD CONSTRAINT fk_users_addresses FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID;
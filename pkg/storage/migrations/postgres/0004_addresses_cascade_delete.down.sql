@@ -0,0 +1,3 @@
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS fk_users_addresses;
+ALTER TABLE addresses
+    ADD CONSTRAINT fk_users_addresses FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID;
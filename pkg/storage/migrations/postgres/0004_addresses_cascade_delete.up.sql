@@ -0,0 +1,5 @@
+-- Deleting a user removes its addresses. Existing orphans are left for the
+-- check-integrity command to report and repair.
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS fk_users_addresses;
+ALTER TABLE addresses
+    ADD CONSTRAINT fk_users_addresses FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE NOT VALID;
@@ -0,0 +1,36 @@
+-- One row per version of a user and its address set. addresses holds the
+-- address set as a JSON array, the current version has no valid_to.
+CREATE TABLE IF NOT EXISTS user_history (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    version      INTEGER NOT NULL,
+    name         TEXT,
+    email        TEXT,
+    phone_number TEXT,
+    addresses    TEXT NOT NULL DEFAULT '[]',
+    deleted_at   TIMESTAMPTZ,
+    valid_from   TIMESTAMPTZ NOT NULL,
+    valid_to     TIMESTAMPTZ,
+    CONSTRAINT uq_user_history_version UNIQUE (user_id, version)
+);
+CREATE INDEX IF NOT EXISTS idx_user_history_valid_from ON user_history (user_id, valid_from);
+
+-- Existing users start with their current state as version 1.
+INSERT INTO user_history (user_id, version, name, email, phone_number, addresses, deleted_at, valid_from)
+SELECT u.id, 1, u.name, u.email, u.phone_number,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'address_id', a.id,
+            'user_id', a.user_id,
+            'street', a.street,
+            'city', a.city,
+            'state', a.state,
+            'zip_code', a.zip_code,
+            'country', a.country
+        ) ORDER BY a.id)
+        FROM addresses a
+        WHERE a.user_id = u.id AND a.deleted_at IS NULL
+    ), '[]'::json)::text,
+    u.deleted_at, u.updated_at
+FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM user_history h WHERE h.user_id = u.id);
@@ -0,0 +1,9 @@
+-- Events are written in the same transaction as the change they describe
+-- and removed once the relay has published them.
+CREATE TABLE IF NOT EXISTS outbox (
+    sequence   BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    payload    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
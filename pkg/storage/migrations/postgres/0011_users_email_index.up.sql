@@ -0,0 +1,4 @@
+-- Looks up active users by email, ignoring case, to reject a second user
+-- with the same email. Emails are not unique in existing data, so this is
+-- not a unique index.
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email)) WHERE deleted_at IS NULL;
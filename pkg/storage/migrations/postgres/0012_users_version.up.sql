@@ -0,0 +1,3 @@
+-- Incremented by every write of a user or its addresses, it is the ETag of
+-- the user and guards conditional updates.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
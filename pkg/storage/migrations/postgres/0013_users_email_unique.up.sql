@@ -0,0 +1,6 @@
+-- Rejects a second active user with the same email, ignoring case. The
+-- repos report a violation as an email taken. Users imported without an
+-- email are exempt. Before it runs, checkUniqueEmails fails the migration
+-- with a list of the active users sharing an email, which must be changed
+-- or deleted through the API first.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_unique ON users (lower(email)) WHERE deleted_at IS NULL AND email <> '';
@@ -0,0 +1,17 @@
+CREATE TABLE addresses_new (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id  TEXT,
+    street   TEXT,
+    city     TEXT,
+    state    TEXT,
+    zip_code TEXT,
+    country  TEXT,
+    CONSTRAINT fk_users_addresses FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+INSERT INTO addresses_new (id, user_id, street, city, state, zip_code, country)
+SELECT id, user_id, street, city, state, zip_code, country FROM addresses;
+
+DROP TABLE addresses;
+ALTER TABLE addresses_new RENAME TO addresses;
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses (user_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_addresses_deleted_at;
+ALTER TABLE addresses DROP COLUMN deleted_at;
+ALTER TABLE addresses DROP COLUMN updated_at;
+ALTER TABLE addresses DROP COLUMN created_at;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
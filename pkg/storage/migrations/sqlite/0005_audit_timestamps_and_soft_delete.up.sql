@@ -0,0 +1,13 @@
+-- SQLite only allows constant defaults on added columns, so existing rows
+-- are backfilled explicitly. New rows get their timestamps from GORM.
+ALTER TABLE users ADD COLUMN created_at DATETIME;
+ALTER TABLE users ADD COLUMN updated_at DATETIME;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+UPDATE users SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+ALTER TABLE addresses ADD COLUMN created_at DATETIME;
+ALTER TABLE addresses ADD COLUMN updated_at DATETIME;
+ALTER TABLE addresses ADD COLUMN deleted_at DATETIME;
+UPDATE addresses SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON addresses (deleted_at);
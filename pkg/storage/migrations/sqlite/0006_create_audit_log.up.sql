@@ -0,0 +1,22 @@
+-- user_id has no foreign key, entries must survive purging the user.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor          TEXT NOT NULL,
+    action         TEXT NOT NULL,
+    user_id        TEXT NOT NULL,
+    request_id     TEXT NOT NULL DEFAULT '',
+    changed_fields TEXT,
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id, id);
+
+-- The log is append-only, updates and deletes are rejected.
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
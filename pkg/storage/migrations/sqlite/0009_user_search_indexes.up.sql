@@ -0,0 +1,3 @@
+-- SQLite has no trigram or accent folding support, search scores the active
+-- users in Go. The partial index keeps that scan off deleted rows.
+CREATE INDEX IF NOT EXISTS idx_users_active ON users (id) WHERE deleted_at IS NULL;
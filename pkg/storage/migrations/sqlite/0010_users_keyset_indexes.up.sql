@@ -0,0 +1,5 @@
+-- Keyset indexes of the user listing, one per sort field with the ID as tie
+-- breaker. Listing by ID uses the primary key.
+CREATE INDEX IF NOT EXISTS idx_users_name_id ON users ((COALESCE(name, '')), id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_email_id ON users ((COALESCE(email, '')), id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at, id) WHERE deleted_at IS NULL;
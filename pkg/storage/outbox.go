@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"sika/internal/outbox"
+	"sika/pkg/storage/entities"
+
+	"gorm.io/gorm"
+)
+
+type outboxRepo struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepo(db *gorm.DB) outbox.Repo {
+	return &outboxRepo{
+		db: db,
+	}
+}
+
+func (r *outboxRepo) GetPendingEvents(ctx context.Context, limit int, skipUsers []string) ([]entities.OutboxEvent, error) {
+	var events []entities.OutboxEvent
+	query := r.db.WithContext(ctx).Order("sequence").Limit(limit)
+	if len(skipUsers) > 0 {
+		query = query.Where("user_id NOT IN ?", skipUsers)
+	}
+	result := query.Find(&events)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return events, nil
+}
+
+func (r *outboxRepo) DeleteEvents(ctx context.Context, sequences []int64) error {
+	return r.db.WithContext(ctx).Where("sequence IN ?", sequences).Delete(&entities.OutboxEvent{}).Error
+}
+
+// appendEvent inserts an outbox event for a change made in tx.
+func appendEvent(tx *gorm.DB, eventType, userID string, payload any) error {
+	event, err := outbox.NewEvent(eventType, userID, payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&event).Error
+}
+
+// appendEvents inserts one outbox event per payload, keyed by userIDs[i].
+func appendEvents[T any](tx *gorm.DB, eventType string, userIDs []string, payloads []T) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	events := make([]entities.OutboxEvent, len(payloads))
+	for i := range payloads {
+		event, err := outbox.NewEvent(eventType, userIDs[i], payloads[i])
+		if err != nil {
+			return err
+		}
+		events[i] = event
+	}
+	return tx.CreateInBatches(events, 100).Error
+}
+
+// touchUsers locks the users, see lockUsers, and increments their version
+// for writes of their addresses, which are part of a user.
+func touchUsers(tx *gorm.DB, userIDs ...string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := lockUsers(tx, userIDs...); err != nil {
+		return err
+	}
+	return tx.Model(&entities.User{}).Unscoped().Where("id IN ?", userIDs).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sika/config"
+	"sika/internal/outbox"
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRepos_WriteOutboxEvents(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	addressRepo := NewAddressRepo(db)
+	outboxRepo := NewOutboxRepo(db)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Email: "old@example.com"}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Email: "new@example.com"}))
+	require.NoError(t, addressRepo.CreateBatchAddresses(ctx, []entities.Address{
+		{UserID: "1", Street: "1 Test St"},
+		{UserID: "1", Street: "2 Test St"},
+	}))
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	require.NoError(t, userRepo.RestoreUser(ctx, "1"))
+	require.NoError(t, userRepo.PurgeUser(ctx, "1"))
+
+	// failed writes leave no event behind
+	assert.Error(t, userRepo.DeleteUser(ctx, "1", 0))
+	assert.Error(t, addressRepo.CreateAddress(ctx, &entities.Address{UserID: "missing"}))
+
+	events, err := outboxRepo.GetPendingEvents(ctx, 100, nil)
+	require.NoError(t, err)
+	var types []string
+	for i, e := range events {
+		types = append(types, e.EventType)
+		assert.Equal(t, "1", e.UserID)
+		if i > 0 {
+			assert.Greater(t, e.Sequence, events[i-1].Sequence)
+		}
+	}
+	assert.Equal(t, []string{
+		outbox.EventUserCreated,
+		outbox.EventUserUpdated,
+		outbox.EventAddressSaved,
+		outbox.EventAddressSaved,
+		outbox.EventUserDeleted,
+		outbox.EventUserRestored,
+		outbox.EventUserPurged,
+	}, types)
+
+	var payload entities.User
+	require.NoError(t, json.Unmarshal([]byte(events[1].Payload), &payload))
+	assert.Equal(t, "new@example.com", payload.Email)
+
+	require.NoError(t, outboxRepo.DeleteEvents(ctx, []int64{events[0].Sequence, events[1].Sequence}))
+	events, err = outboxRepo.GetPendingEvents(ctx, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, outbox.EventAddressSaved, events[0].EventType)
+
+	// the events of skipped users are left out
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2"}))
+	events, err = outboxRepo.GetPendingEvents(ctx, 2, []string{"1"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "2", events[0].UserID)
+}
+
+func TestUserRepo_ClearAllUsersDataFromDB(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	historyRepo := NewHistoryRepo(db)
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2", Addresses: []entities.Address{{Street: "1 Test St"}}}))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1"}))
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	require.NoError(t, historyRepo.AppendVersion(ctx, &entities.UserVersion{UserID: "2", ValidFrom: time.Now()}))
+	require.NoError(t, db.Exec("DELETE FROM outbox").Error)
+
+	require.NoError(t, userRepo.ClearAllUsersDataFromDB(ctx))
+	require.NoError(t, historyRepo.CloseVersions(ctx, time.Now()))
+
+	var users, addresses int64
+	require.NoError(t, db.Unscoped().Model(&entities.User{}).Count(&users).Error)
+	require.NoError(t, db.Unscoped().Model(&entities.Address{}).Count(&addresses).Error)
+	assert.Zero(t, users)
+	assert.Zero(t, addresses)
+
+	events, err := NewOutboxRepo(db).GetPendingEvents(ctx, 100, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 2, "one event per user, soft-deleted ones too")
+	for i, id := range []string{"1", "2"} {
+		assert.Equal(t, outbox.EventUserPurged, events[i].EventType)
+		assert.Equal(t, id, events[i].UserID)
+	}
+
+	_, err = historyRepo.GetCurrentVersion(ctx, "2")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserRepo_ConcurrentCreateUserEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sika.db")
+	db, err := NewGormConnection(config.DB{Driver: config.DriverSQLite, Path: path})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	require.NoError(t, Migrate(db))
+
+	// each writer has a pool of its own on the same database file
+	const writers = 4
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		writerDB, err := NewGormConnection(config.DB{Driver: config.DriverSQLite, Path: path})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			if sqlDB, err := writerDB.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		go func(i int) {
+			errs <- NewUserRepo(writerDB).CreateUser(context.Background(), &entities.User{ID: "1", Name: fmt.Sprintf("Name %d", i)})
+		}(i)
+	}
+	for i := 0; i < writers; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	var created, updated int64
+	require.NoError(t, db.Model(&entities.OutboxEvent{}).Where("event_type=?", outbox.EventUserCreated).Count(&created).Error)
+	require.NoError(t, db.Model(&entities.OutboxEvent{}).Where("event_type=?", outbox.EventUserUpdated).Count(&updated).Error)
+	assert.Equal(t, int64(1), created)
+	assert.Equal(t, int64(writers-1), updated)
+}
@@ -0,0 +1,312 @@
+package pgxrepo
+
+import (
+	"context"
+	"fmt"
+	"sika/internal/address"
+	"sika/internal/outbox"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gorm.io/gorm"
+)
+
+type addressRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewAddressRepo returns a repo whose errors are translated by
+// storage.AddressError.
+func NewAddressRepo(pool *pgxpool.Pool) address.Repo {
+	return storage.WithAddressErrors(&addressRepo{
+		pool: pool,
+	})
+}
+
+const insertAddressSQL = `
+INSERT INTO addresses (user_id, street, city, state, zip_code, country, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+RETURNING id, created_at, updated_at`
+
+const upsertAddressSQL = `
+INSERT INTO addresses (id, user_id, street, city, state, zip_code, country, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+ON CONFLICT (id) DO UPDATE SET
+    user_id = EXCLUDED.user_id,
+    street = EXCLUDED.street,
+    city = EXCLUDED.city,
+    state = EXCLUDED.state,
+    zip_code = EXCLUDED.zip_code,
+    country = EXCLUDED.country,
+    updated_at = EXCLUDED.updated_at
+RETURNING id, created_at, updated_at`
+
+const selectAddressColumns = "id, user_id, street, city, state, zip_code, country, created_at, updated_at, deleted_at"
+
+const orphanAddressCondition = "NOT EXISTS (SELECT 1 FROM users WHERE users.id = addresses.user_id)"
+
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+const insertEventSQL = `
+INSERT INTO outbox (event_type, user_id, payload, created_at)
+VALUES ($1, $2, $3, $4)`
+
+// appendEvents inserts one outbox event per payload, keyed by userIDs[i].
+func appendEvents[T any](ctx context.Context, q querier, eventType string, userIDs []string, payloads []T) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	now := time.Now()
+	batch := &pgx.Batch{}
+	for i := range payloads {
+		event, err := outbox.NewEvent(eventType, userIDs[i], payloads[i])
+		if err != nil {
+			return err
+		}
+		batch.Queue(insertEventSQL, event.EventType, event.UserID, event.Payload, now)
+	}
+	return translateError(q.SendBatch(ctx, batch).Close())
+}
+
+// touchUsers locks the users, see lockUsers, and increments their version
+// for writes of their addresses.
+func touchUsers(ctx context.Context, tx pgx.Tx, userIDs []string) error {
+	if err := lockUsers(ctx, tx, userIDs); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, "UPDATE users SET version = version + 1 WHERE id = ANY($1)", userIDs)
+	return translateError(err)
+}
+
+// saveAddresses upserts addrs and records an event per address.
+func saveAddresses(ctx context.Context, tx pgx.Tx, addrs []entities.Address) error {
+	userIDs := make([]string, len(addrs))
+	for i := range addrs {
+		userIDs[i] = addrs[i].UserID
+	}
+	if err := touchUsers(ctx, tx, userIDs); err != nil {
+		return err
+	}
+	if err := upsertAddresses(ctx, tx, addrs); err != nil {
+		return err
+	}
+	return appendEvents(ctx, tx, outbox.EventAddressSaved, userIDs, addrs)
+}
+
+// upsertAddresses writes addrs in one batch, assigning IDs to new addresses
+// and keeping created_at of existing ones.
+func upsertAddresses(ctx context.Context, q querier, addrs []entities.Address) error {
+	if len(addrs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	batch := &pgx.Batch{}
+	for i := range addrs {
+		a := &addrs[i]
+		var qq *pgx.QueuedQuery
+		if a.ID == 0 {
+			qq = batch.Queue(insertAddressSQL, a.UserID, a.Street, a.City, a.State, a.ZipCode, a.Country, now)
+		} else {
+			qq = batch.Queue(upsertAddressSQL, a.ID, a.UserID, a.Street, a.City, a.State, a.ZipCode, a.Country, now)
+		}
+		qq.QueryRow(func(row pgx.Row) error {
+			return row.Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+		})
+	}
+	return translateError(q.SendBatch(ctx, batch).Close())
+}
+
+func (r *addressRepo) CreateAddress(ctx context.Context, a *entities.Address) error {
+	addrs := []entities.Address{*a}
+	err := pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		return saveAddresses(ctx, tx, addrs)
+	})
+	if err != nil {
+		return err
+	}
+	*a = addrs[0]
+	return nil
+}
+
+// CreateBatchAddresses inserts all addresses or none.
+func (r *addressRepo) CreateBatchAddresses(ctx context.Context, adds []entities.Address) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		return saveAddresses(ctx, tx, adds)
+	})
+}
+
+func (r *addressRepo) GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error) {
+	return r.query(ctx, "SELECT "+selectAddressColumns+" FROM addresses WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id", userID)
+}
+
+func (r *addressRepo) GetAddress(ctx context.Context, userID string, id int) (*entities.Address, error) {
+	addresses, err := r.query(ctx, "SELECT "+selectAddressColumns+" FROM addresses WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL", id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &addresses[0], nil
+}
+
+const updateAddressSQL = `
+UPDATE addresses SET street = $3, city = $4, state = $5, zip_code = $6, country = $7, updated_at = $8
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+RETURNING created_at, updated_at`
+
+func (r *addressRepo) UpdateAddress(ctx context.Context, a *entities.Address) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		if err := touchUsers(ctx, tx, []string{a.UserID}); err != nil {
+			return err
+		}
+		err := tx.QueryRow(ctx, updateAddressSQL, a.ID, a.UserID, a.Street, a.City, a.State, a.ZipCode, a.Country, time.Now()).
+			Scan(&a.CreatedAt, &a.UpdatedAt)
+		if err != nil {
+			return translateError(err)
+		}
+		a.DeletedAt = gorm.DeletedAt{}
+		return appendEvents(ctx, tx, outbox.EventAddressSaved, []string{a.UserID}, []*entities.Address{a})
+	})
+}
+
+func (r *addressRepo) DeleteAddress(ctx context.Context, userID string, id int) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		if err := touchUsers(ctx, tx, []string{userID}); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, "DELETE FROM addresses WHERE id = $1 AND user_id = $2 RETURNING "+selectAddressColumns, id, userID)
+		if err != nil {
+			return translateError(err)
+		}
+		deleted, err := pgx.CollectRows(rows, scanAddress)
+		if err != nil {
+			return translateError(err)
+		}
+		if len(deleted) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return appendEvents(ctx, tx, outbox.EventAddressDeleted, []string{userID}, deleted)
+	})
+}
+
+func (r *addressRepo) ReplaceAddresses(ctx context.Context, userID string, adds []entities.Address) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		if err := touchUsers(ctx, tx, []string{userID}); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, "SELECT id FROM addresses WHERE user_id = $1", userID)
+		if err != nil {
+			return translateError(err)
+		}
+		existing, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return translateError(err)
+		}
+		owned := make(map[int]struct{}, len(existing))
+		for _, id := range existing {
+			owned[id] = struct{}{}
+		}
+
+		kept := []int{}
+		userIDs := make([]string, len(adds))
+		for i := range adds {
+			adds[i].UserID = userID
+			userIDs[i] = userID
+			if adds[i].ID == 0 {
+				continue
+			}
+			if _, ok := owned[adds[i].ID]; !ok {
+				return gorm.ErrRecordNotFound
+			}
+			kept = append(kept, adds[i].ID)
+		}
+
+		rows, err = tx.Query(ctx, "DELETE FROM addresses WHERE user_id = $1 AND NOT (id = ANY($2)) RETURNING "+selectAddressColumns, userID, kept)
+		if err != nil {
+			return translateError(err)
+		}
+		removed, err := pgx.CollectRows(rows, scanAddress)
+		if err != nil {
+			return translateError(err)
+		}
+		removedUserIDs := make([]string, len(removed))
+		for i := range removed {
+			removedUserIDs[i] = userID
+		}
+		if err := appendEvents(ctx, tx, outbox.EventAddressDeleted, removedUserIDs, removed); err != nil {
+			return err
+		}
+
+		if err := upsertAddresses(ctx, tx, adds); err != nil {
+			return err
+		}
+		return appendEvents(ctx, tx, outbox.EventAddressSaved, userIDs, adds)
+	})
+}
+
+// GetOrphanAddresses includes soft-deleted rows like DeleteOrphanAddresses.
+func (r *addressRepo) GetOrphanAddresses(ctx context.Context) ([]entities.Address, error) {
+	return r.query(ctx, "SELECT "+selectAddressColumns+" FROM addresses WHERE "+orphanAddressCondition+" ORDER BY id")
+}
+
+func (r *addressRepo) DeleteOrphanAddresses(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "DELETE FROM addresses WHERE "+orphanAddressCondition+" RETURNING "+selectAddressColumns)
+		if err != nil {
+			return translateError(err)
+		}
+		orphans, err := pgx.CollectRows(rows, scanAddress)
+		if err != nil {
+			return translateError(err)
+		}
+		deleted = int64(len(orphans))
+
+		userIDs := make([]string, len(orphans))
+		for i, a := range orphans {
+			userIDs[i] = a.UserID
+		}
+		return appendEvents(ctx, tx, outbox.EventAddressDeleted, userIDs, orphans)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphan addresses: %w", err)
+	}
+	return deleted, nil
+}
+
+func (r *addressRepo) query(ctx context.Context, sql string, args ...any) ([]entities.Address, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	addresses, err := pgx.CollectRows(rows, scanAddress)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return addresses, nil
+}
+
+func scanAddress(row pgx.CollectableRow) (entities.Address, error) {
+	var a entities.Address
+	err := row.Scan(&a.ID, &a.UserID, &a.Street, &a.City, &a.State, &a.ZipCode, &a.Country, &a.CreatedAt, &a.UpdatedAt, &a.DeletedAt)
+	return a, err
+}
+
+func (r *addressRepo) ClearAllAddressesDataFromDB() error {
+	if _, err := r.pool.Exec(context.Background(), "DELETE FROM addresses"); err != nil {
+		return fmt.Errorf("failed to clear addresses table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package pgxrepo
+
+import (
+	"context"
+	"encoding/json"
+	"sika/internal/audit"
+	"sika/pkg/storage/entities"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type auditRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewAuditRepo returns a repo that appends within the transaction of
+// Transactor, so that an entry commits with the change it records.
+func NewAuditRepo(pool *pgxpool.Pool) audit.Repo {
+	return &auditRepo{
+		pool: pool,
+	}
+}
+
+func (r *auditRepo) AppendEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	changedFields, err := changedFieldsJSON(entry.ChangedFields)
+	if err != nil {
+		return err
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	err = conn(ctx, r.pool).QueryRow(ctx, `
+INSERT INTO audit_log (actor, action, user_id, request_id, changed_fields, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id`, entry.Actor, entry.Action, entry.UserID, entry.RequestID, changedFields, entry.CreatedAt).
+		Scan(&entry.ID)
+	return translateError(err)
+}
+
+// AppendEntries inserts the entries as one array per column, unnested into
+// rows by a single statement.
+func (r *auditRepo) AppendEntries(ctx context.Context, entries []entities.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	n := len(entries)
+	actors, actions, userIDs, requestIDs := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	changedFields, createdAt := make([]*string, n), make([]time.Time, n)
+	now := time.Now()
+	for i, e := range entries {
+		fields, err := changedFieldsJSON(e.ChangedFields)
+		if err != nil {
+			return err
+		}
+		actors[i], actions[i], userIDs[i], requestIDs[i], changedFields[i] = e.Actor, e.Action, e.UserID, e.RequestID, fields
+		createdAt[i] = e.CreatedAt
+		if createdAt[i].IsZero() {
+			createdAt[i] = now
+		}
+	}
+	_, err := conn(ctx, r.pool).Exec(ctx, `
+INSERT INTO audit_log (actor, action, user_id, request_id, changed_fields, created_at)
+SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::timestamptz[])`,
+		actors, actions, userIDs, requestIDs, changedFields, createdAt)
+	return translateError(err)
+}
+
+// changedFieldsJSON returns changed_fields as a JSON array like the GORM
+// serializer writes it, nil for NULL when there are none.
+func changedFieldsJSON(fields []string) (*string, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+	return &s, nil
+}
+
+func (r *auditRepo) GetEntriesByUser(ctx context.Context, userID string) ([]entities.AuditEntry, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, `
+SELECT id, actor, action, user_id, request_id, changed_fields, created_at
+FROM audit_log
+WHERE user_id = $1
+ORDER BY id`, userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.AuditEntry, error) {
+		var e entities.AuditEntry
+		var changedFields *string
+		if err := row.Scan(&e.ID, &e.Actor, &e.Action, &e.UserID, &e.RequestID, &changedFields, &e.CreatedAt); err != nil {
+			return e, err
+		}
+		if changedFields != nil && *changedFields != "" {
+			if err := json.Unmarshal([]byte(*changedFields), &e.ChangedFields); err != nil {
+				return e, err
+			}
+		}
+		return e, nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return entries, nil
+}
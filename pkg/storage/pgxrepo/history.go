@@ -0,0 +1,133 @@
+package pgxrepo
+
+import (
+	"context"
+	"encoding/json"
+	"sika/internal/history"
+	"sika/pkg/storage/entities"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type historyRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewHistoryRepo returns a repo that appends within the transaction of
+// Transactor, so that a version commits with the change it records.
+func NewHistoryRepo(pool *pgxpool.Pool) history.Repo {
+	return &historyRepo{
+		pool: pool,
+	}
+}
+
+// versionColumnsSQL are the columns scanned by scanVersion.
+const versionColumnsSQL = `id, user_id, version, name, email, phone_number, addresses, deleted_at, valid_from, valid_to`
+
+// AppendVersion stores the addresses as a JSON array like the GORM
+// serializer writes them.
+func (r *historyRepo) AppendVersion(ctx context.Context, v *entities.UserVersion) error {
+	v.ValidFrom = v.ValidFrom.UTC()
+	if v.Addresses == nil {
+		v.Addresses = []entities.Address{}
+	}
+	addresses, err := json.Marshal(v.Addresses)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		var last int
+		err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM user_history WHERE user_id = $1", v.UserID).Scan(&last)
+		if err != nil {
+			return translateError(err)
+		}
+		_, err = tx.Exec(ctx, "UPDATE user_history SET valid_to = $2 WHERE user_id = $1 AND valid_to IS NULL", v.UserID, v.ValidFrom)
+		if err != nil {
+			return translateError(err)
+		}
+		v.Version = last + 1
+		err = tx.QueryRow(ctx, `
+INSERT INTO user_history (user_id, version, name, email, phone_number, addresses, deleted_at, valid_from)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id`, v.UserID, v.Version, v.Name, v.Email, v.PhoneNumber, string(addresses), v.DeletedAt, v.ValidFrom).
+			Scan(&v.ID)
+		return translateError(err)
+	})
+}
+
+// GetCurrentVersion locks the user first, see lockUsers, so that concurrent
+// writes of the user compare and append their versions one after the other.
+func (r *historyRepo) GetCurrentVersion(ctx context.Context, userID string) (*entities.UserVersion, error) {
+	if err := lockUsers(ctx, conn(ctx, r.pool), []string{userID}); err != nil {
+		return nil, err
+	}
+	return r.queryOne(ctx, "SELECT "+versionColumnsSQL+" FROM user_history WHERE user_id = $1 AND valid_to IS NULL", userID)
+}
+
+func (r *historyRepo) GetVersions(ctx context.Context, userID string) ([]entities.UserVersion, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, "SELECT "+versionColumnsSQL+" FROM user_history WHERE user_id = $1 ORDER BY version", userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	versions, err := pgx.CollectRows(rows, scanVersion)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return versions, nil
+}
+
+func (r *historyRepo) GetVersionAt(ctx context.Context, userID string, at time.Time) (*entities.UserVersion, error) {
+	return r.queryOne(ctx, "SELECT "+versionColumnsSQL+`
+FROM user_history
+WHERE user_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+ORDER BY version DESC
+LIMIT 1`, userID, at.UTC())
+}
+
+func (r *historyRepo) DeleteVersions(ctx context.Context, userID string) error {
+	_, err := conn(ctx, r.pool).Exec(ctx, "DELETE FROM user_history WHERE user_id = $1", userID)
+	return translateError(err)
+}
+
+func (r *historyRepo) CloseVersions(ctx context.Context, at time.Time) error {
+	_, err := conn(ctx, r.pool).Exec(ctx, "UPDATE user_history SET valid_to = $1 WHERE valid_to IS NULL", at.UTC())
+	return translateError(err)
+}
+
+// queryOne returns the version read by sql, gorm.ErrRecordNotFound when
+// there is none.
+func (r *historyRepo) queryOne(ctx context.Context, sql string, args ...any) (*entities.UserVersion, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	v, err := pgx.CollectExactlyOneRow(rows, scanVersion)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &v, nil
+}
+
+func scanVersion(row pgx.CollectableRow) (entities.UserVersion, error) {
+	var v entities.UserVersion
+	var name, email, phoneNumber *string
+	var addresses string
+	err := row.Scan(&v.ID, &v.UserID, &v.Version, &name, &email, &phoneNumber, &addresses, &v.DeletedAt, &v.ValidFrom, &v.ValidTo)
+	if err != nil {
+		return v, err
+	}
+	for _, f := range []struct {
+		dst *string
+		src *string
+	}{{&v.Name, name}, {&v.Email, email}, {&v.PhoneNumber, phoneNumber}} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	if err := json.Unmarshal([]byte(addresses), &v.Addresses); err != nil {
+		return v, err
+	}
+	return v, nil
+}
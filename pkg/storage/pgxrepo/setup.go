@@ -0,0 +1,83 @@
+// Package pgxrepo implements user.Repo, address.Repo, audit.Repo and
+// history.Repo directly on pgx, without GORM. Statements are prepared and
+// cached per connection by pgx's default statement cache, and a user is read
+// together with its addresses in a single query.
+package pgxrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sika/config"
+	"sika/internal/user"
+	"sika/pkg/storage"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gorm.io/gorm"
+)
+
+// NewPool opens a pgx pool using the same DSN and pool settings as the GORM
+// connection. max_idle_conns is rejected: a pgx pool keeps every connection
+// until conn_max_idle_time and has no such limit.
+func NewPool(ctx context.Context, dbConfig config.DB) (*pgxpool.Pool, error) {
+	if dbConfig.MaxIdleConns > 0 {
+		return nil, errors.New("db.max_idle_conns is not supported by the pgx repository, use conn_max_idle_time")
+	}
+	poolConfig, err := pgxpool.ParseConfig(storage.PostgresDSN(dbConfig))
+	if err != nil {
+		return nil, err
+	}
+	if dbConfig.MaxOpenConns > 0 {
+		poolConfig.MaxConns = int32(dbConfig.MaxOpenConns)
+	}
+	if dbConfig.ConnMaxLifetime > 0 {
+		poolConfig.MaxConnLifetime = dbConfig.ConnMaxLifetime
+	}
+	if dbConfig.ConnMaxIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = dbConfig.ConnMaxIdleTime
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// emailIndex is the unique index of the emails of active users.
+const emailIndex = "idx_users_email_unique"
+
+// translateError maps pgx errors to the GORM errors the rest of the code
+// already checks for, so callers behave the same with either repo. A
+// violation of emailIndex is user.ErrEmailTaken, as the GORM repo reports it.
+// Postgres errors stay wrapped, with their constraint and detail, which their
+// message leaves out, so that both are logged.
+func translateError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gorm.ErrRecordNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			if pgErr.ConstraintName == emailIndex {
+				return fmt.Errorf("%w: %w", user.ErrEmailTaken, withDetail(gorm.ErrDuplicatedKey, err, pgErr))
+			}
+			return withDetail(gorm.ErrDuplicatedKey, err, pgErr)
+		case "23503":
+			return withDetail(gorm.ErrForeignKeyViolated, err, pgErr)
+		}
+	}
+	return err
+}
+
+// withDetail wraps err, the error of pgErr, under sentinel.
+func withDetail(sentinel, err error, pgErr *pgconn.PgError) error {
+	return fmt.Errorf("%w: %w (constraint %s: %s)", sentinel, err, pgErr.ConstraintName, pgErr.Detail)
+}
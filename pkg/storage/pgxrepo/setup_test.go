@@ -0,0 +1,75 @@
+package pgxrepo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sika/config"
+	"sika/internal/user"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    []error
+		wantMsg string
+	}{
+		{
+			name: "no rows",
+			err:  pgx.ErrNoRows,
+			want: []error{gorm.ErrRecordNotFound},
+		},
+		{
+			name:    "duplicate key",
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: "users_pkey", Detail: "Key (id)=(1) already exists."},
+			want:    []error{gorm.ErrDuplicatedKey},
+			wantMsg: "Key (id)=(1) already exists.",
+		},
+		{
+			name:    "duplicate email",
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: emailIndex},
+			want:    []error{user.ErrEmailTaken, gorm.ErrDuplicatedKey},
+			wantMsg: emailIndex,
+		},
+		{
+			name:    "foreign key",
+			err:     &pgconn.PgError{Code: "23503", ConstraintName: "fk_users_addresses"},
+			want:    []error{gorm.ErrForeignKeyViolated},
+			wantMsg: "fk_users_addresses",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			err := translateError(tt.err)
+
+			// Assert
+			for _, want := range tt.want {
+				assert.ErrorIs(t, err, want)
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(tt.err, &pgErr) {
+				assert.ErrorAs(t, err, &pgErr, "the Postgres error stays in the chain")
+				assert.Contains(t, err.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestNewPool_RejectsMaxIdleConns(t *testing.T) {
+	// Execute
+	_, err := NewPool(context.Background(), config.DB{MaxIdleConns: 5})
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max_idle_conns")
+}
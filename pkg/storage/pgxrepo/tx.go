@@ -0,0 +1,54 @@
+package pgxrepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type txKey struct{}
+
+// Transactor runs functions in one transaction of the pool, which the repos
+// take from the context like those of storage.Transactor.
+type Transactor struct {
+	pool *pgxpool.Pool
+}
+
+func NewTransactor(pool *pgxpool.Pool) *Transactor {
+	return &Transactor{
+		pool: pool,
+	}
+}
+
+// InTx runs fn in a transaction that is committed when fn returns nil and
+// rolled back otherwise. Within a transaction fn joins it.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	return pgx.BeginFunc(ctx, t.pool, func(tx pgx.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction ctx was given by InTx, or pool outside of
+// one. A repo's own transaction on it becomes a savepoint.
+func conn(ctx context.Context, pool *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return pool
+}
+
+// lockUsers locks the rows of the users until the end of the transaction of
+// q, in ID order so that transactions locking several users do not deadlock.
+// Writes of a user and of its history and outbox events then follow the
+// order in which the transactions commit.
+func lockUsers(ctx context.Context, q querier, userIDs []string) error {
+	ids := append([]string(nil), userIDs...)
+	sort.Strings(ids)
+	_, err := q.Exec(ctx, "SELECT 1 FROM users WHERE id = ANY($1) ORDER BY id FOR UPDATE", ids)
+	return translateError(err)
+}
@@ -0,0 +1,448 @@
+package pgxrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sika/internal/outbox"
+	"sika/internal/user"
+	"sika/pkg/filter"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gorm.io/gorm"
+)
+
+type userRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewUserRepo returns a repo whose errors are translated by
+// storage.UserError.
+func NewUserRepo(pool *pgxpool.Pool) user.Repo {
+	return storage.WithUserErrors(&userRepo{
+		pool: pool,
+	})
+}
+
+const upsertUserSQL = `
+INSERT INTO users (id, name, email, phone_number, created_at, updated_at, deleted_at)
+VALUES ($1, $2, $3, $4, $5, $5, NULL)
+ON CONFLICT (id) DO UPDATE SET
+    name = EXCLUDED.name,
+    email = EXCLUDED.email,
+    phone_number = EXCLUDED.phone_number,
+    updated_at = EXCLUDED.updated_at,
+    deleted_at = NULL,
+    version = users.version + 1
+RETURNING created_at, updated_at, version, xmax = 0`
+
+const insertUserSQL = `
+INSERT INTO users (id, name, email, phone_number, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $5)`
+
+// userColumnsSQL are the columns scanned by scanUser.
+const userColumnsSQL = `u.id, u.name, u.email, u.phone_number, u.created_at, u.updated_at, u.deleted_at, u.version`
+
+// addressesColumnSQL aggregates the addresses of the user into a JSON array
+// that decodes into []entities.Address.
+const addressesColumnSQL = `
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'address_id', a.id,
+            'user_id', a.user_id,
+            'street', a.street,
+            'city', a.city,
+            'state', a.state,
+            'zip_code', a.zip_code,
+            'country', a.country,
+            'created_at', a.created_at,
+            'updated_at', a.updated_at,
+            'deleted_at', a.deleted_at
+        ) ORDER BY a.id)
+        FROM addresses a
+        WHERE a.user_id = u.id AND a.deleted_at IS NULL
+    ), '[]'::json)`
+
+// selectColumnsSQL returns the select list of the columns of a projection,
+// each of user.Fields being a column of the users table.
+func selectColumnsSQL(cols []string, addresses bool) string {
+	sql := "u." + strings.Join(cols, ", u.")
+	if addresses {
+		sql += "," + addressesColumnSQL
+	}
+	return sql
+}
+
+// scanDest returns where the columns of selectColumnsSQL are scanned into u.
+func scanDest(u *entities.User, cols []string, addresses bool) []any {
+	dest := make([]any, 0, len(cols)+1)
+	for _, col := range cols {
+		switch col {
+		case "id":
+			dest = append(dest, &u.ID)
+		case "name":
+			dest = append(dest, &u.Name)
+		case "email":
+			dest = append(dest, &u.Email)
+		case "phone_number":
+			dest = append(dest, &u.PhoneNumber)
+		case "created_at":
+			dest = append(dest, &u.CreatedAt)
+		case "updated_at":
+			dest = append(dest, &u.UpdatedAt)
+		case "deleted_at":
+			dest = append(dest, &u.DeletedAt)
+		case "version":
+			dest = append(dest, &u.Version)
+		}
+	}
+	if addresses {
+		dest = append(dest, &u.Addresses)
+	}
+	return dest
+}
+
+// CreateUser upserts u and any addresses attached to it in one transaction,
+// keeping created_at of an existing row and clearing a soft delete.
+func (r *userRepo) CreateUser(ctx context.Context, u *entities.User) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		// xmax is 0 for a freshly inserted row
+		var inserted bool
+		err := tx.QueryRow(ctx, upsertUserSQL, u.ID, u.Name, u.Email, u.PhoneNumber, time.Now()).
+			Scan(&u.CreatedAt, &u.UpdatedAt, &u.Version, &inserted)
+		if err != nil {
+			return translateError(err)
+		}
+		u.DeletedAt = gorm.DeletedAt{}
+
+		for i := range u.Addresses {
+			u.Addresses[i].UserID = u.ID
+		}
+		if err := upsertAddresses(ctx, tx, u.Addresses); err != nil {
+			return err
+		}
+
+		eventType := outbox.EventUserCreated
+		if !inserted {
+			eventType = outbox.EventUserUpdated
+		}
+		return appendEvents(ctx, tx, eventType, []string{u.ID}, []*entities.User{u})
+	})
+}
+
+// CreateBatchUsers inserts all users and their addresses or none, failing on
+// an existing ID.
+func (r *userRepo) CreateBatchUsers(ctx context.Context, users []entities.User) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		now := time.Now()
+		batch := &pgx.Batch{}
+		for _, u := range users {
+			batch.Queue(insertUserSQL, u.ID, u.Name, u.Email, u.PhoneNumber, now)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return translateError(err)
+		}
+
+		var addresses []entities.Address
+		for i := range users {
+			users[i].CreatedAt, users[i].UpdatedAt, users[i].Version = now, now, 1
+			for j := range users[i].Addresses {
+				users[i].Addresses[j].UserID = users[i].ID
+			}
+			addresses = append(addresses, users[i].Addresses...)
+		}
+		if err := upsertAddresses(ctx, tx, addresses); err != nil {
+			return err
+		}
+
+		// copy the assigned address IDs and timestamps back
+		k := 0
+		ids := make([]string, len(users))
+		for i := range users {
+			ids[i] = users[i].ID
+			for j := range users[i].Addresses {
+				users[i].Addresses[j] = addresses[k]
+				k++
+			}
+		}
+		return appendEvents(ctx, tx, outbox.EventUserCreated, ids, users)
+	})
+}
+
+func (r *userRepo) GetUserByID(ctx context.Context, id string) (*entities.User, error) {
+	return r.GetUserProjection(ctx, id, user.Projection{IncludeAddresses: true}, false)
+}
+
+func (r *userRepo) GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error) {
+	return r.GetUserProjection(ctx, id, user.Projection{IncludeAddresses: true}, true)
+}
+
+// GetUserProjection reads the user and, when p includes them, its addresses
+// in one round trip, selecting only the columns of p.
+func (r *userRepo) GetUserProjection(ctx context.Context, id string, p user.Projection, includeDeleted bool) (*entities.User, error) {
+	cols := p.Columns()
+	sql := "SELECT " + selectColumnsSQL(cols, p.IncludeAddresses) + "\nFROM users u\nWHERE u.id = $1"
+	if !includeDeleted {
+		sql += " AND u.deleted_at IS NULL"
+	}
+	var u entities.User
+	if err := conn(ctx, r.pool).QueryRow(ctx, sql, id).Scan(scanDest(&u, cols, p.IncludeAddresses)...); err != nil {
+		return nil, translateError(err)
+	}
+	return &u, nil
+}
+
+// GetUsersByIDs reads the users and their addresses in one query.
+func (r *userRepo) GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, "SELECT "+userColumnsSQL+","+addressesColumnSQL+`
+FROM users u
+WHERE u.id = ANY($1) AND u.deleted_at IS NULL`, ids)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.User, error) {
+		var u entities.User
+		err := row.Scan(&u.ID, &u.Name, &u.Email, &u.PhoneNumber, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.Version, &u.Addresses)
+		return u, err
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return users, nil
+}
+
+// listColumns are the sort expressions of ListUsers, the same as in the GORM
+// repo so the keyset indexes of migration 0010 apply.
+var listColumns = map[string]string{
+	user.SortByName:      "COALESCE(u.name, '')",
+	user.SortByEmail:     "COALESCE(u.email, '')",
+	user.SortByCreatedAt: "u.created_at",
+}
+
+// userFilterSchema is the filter schema of the GORM repo for the u alias.
+var userFilterSchema = filter.SQLSchema{
+	Columns: map[string]string{
+		"id":            "u.id",
+		"name":          "COALESCE(u.name, '')",
+		"email":         "COALESCE(u.email, '')",
+		"phone_number":  "COALESCE(u.phone_number, '')",
+		"created_at":    "u.created_at",
+		"updated_at":    "u.updated_at",
+		"street":        "COALESCE(a.street, '')",
+		"city":          "COALESCE(a.city, '')",
+		"state":         "COALESCE(a.state, '')",
+		"zip_code":      "COALESCE(a.zip_code, '')",
+		"country":       "COALESCE(a.country, '')",
+		"address_count": "(SELECT COUNT(*) FROM addresses a WHERE a.user_id = u.id AND a.deleted_at IS NULL)",
+	},
+	Groups: map[string]string{
+		user.GroupAddress: "EXISTS (SELECT 1 FROM addresses a WHERE a.user_id = u.id AND a.deleted_at IS NULL AND %s)",
+	},
+}
+
+// ListUsers seeks past the cursor with a row comparison on the sort column
+// and ID. The SQL is assembled from fixed fragments only, values are bound.
+func (r *userRepo) ListUsers(ctx context.Context, q user.ListQuery) ([]entities.User, error) {
+	dir, cmp := "ASC", ">"
+	if q.Descending {
+		dir, cmp = "DESC", "<"
+	}
+
+	cols := q.Columns(q.SortBy)
+	sql := "SELECT " + selectColumnsSQL(cols, q.IncludeAddresses)
+	sql += "\nFROM users u\nWHERE u.deleted_at IS NULL"
+
+	args := []any{q.Limit}
+	col, ok := listColumns[q.SortBy]
+	if q.After != nil {
+		switch q.SortBy {
+		case user.SortByID:
+			sql += " AND u.id " + cmp + " $2"
+			args = append(args, q.After.ID)
+		case user.SortByCreatedAt:
+			sql += " AND (u.created_at, u.id) " + cmp + " ($2, $3)"
+			args = append(args, *q.After.CreatedAt, q.After.ID)
+		default:
+			sql += " AND (" + col + ", u.id) " + cmp + " ($2, $3)"
+			args = append(args, q.After.Value, q.After.ID)
+		}
+	}
+	if q.Filter != nil {
+		schema := userFilterSchema
+		bound := len(args)
+		schema.Placeholder = func(n int) string { return fmt.Sprintf("$%d", bound+n) }
+		cond, filterArgs, err := filter.ToSQL(q.Filter, schema)
+		if err != nil {
+			return nil, err
+		}
+		sql += " AND " + cond
+		args = append(args, filterArgs...)
+	}
+	if ok {
+		sql += "\nORDER BY " + col + " " + dir + ", u.id " + dir
+	} else {
+		sql += "\nORDER BY u.id " + dir
+	}
+	sql += "\nLIMIT $1"
+
+	rows, err := conn(ctx, r.pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.User, error) {
+		var u entities.User
+		err := row.Scan(scanDest(&u, cols, q.IncludeAddresses)...)
+		return u, err
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetAllUserIDs(ctx context.Context) ([]string, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, "SELECT id FROM users WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, translateError(err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return ids, nil
+}
+
+func (r *userRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	var u entities.User
+	err := conn(ctx, r.pool).QueryRow(ctx, "SELECT "+userColumnsSQL+`
+FROM users u
+WHERE lower(u.email) = lower($1) AND u.deleted_at IS NULL
+ORDER BY u.id
+LIMIT 1`, email).Scan(&u.ID, &u.Name, &u.Email, &u.PhoneNumber, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.Version)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &u, nil
+}
+
+// UpdateUser increments the version of the user. When u.Version is set it
+// must be the stored version, else user.ErrVersionMismatch is returned.
+func (r *userRepo) UpdateUser(ctx context.Context, u *entities.User) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+UPDATE users SET name = $2, email = $3, phone_number = $4, updated_at = $5, version = version + 1
+WHERE id = $1 AND deleted_at IS NULL AND ($6::bigint = 0 OR version = $6)
+RETURNING created_at, updated_at, version`, u.ID, u.Name, u.Email, u.PhoneNumber, time.Now(), u.Version).
+			Scan(&u.CreatedAt, &u.UpdatedAt, &u.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return versionError(ctx, tx, u.ID)
+		}
+		if err != nil {
+			return translateError(err)
+		}
+		u.DeletedAt = gorm.DeletedAt{}
+		return appendEvents(ctx, tx, outbox.EventUserUpdated, []string{u.ID}, []*entities.User{u})
+	})
+}
+
+// DeleteUser soft-deletes the user and increments its version. A version
+// other than 0 must be the stored one.
+func (r *userRepo) DeleteUser(ctx context.Context, id string, version int64) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+UPDATE users SET deleted_at = $2, version = version + 1
+WHERE id = $1 AND deleted_at IS NULL AND ($3::bigint = 0 OR version = $3)`, id, time.Now(), version)
+		if err != nil {
+			return translateError(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return versionError(ctx, tx, id)
+		}
+		return appendEvents(ctx, tx, outbox.EventUserDeleted, []string{id}, []outbox.IDPayload{{ID: id}})
+	})
+}
+
+// versionError tells why a write of the active user id matched no row.
+func versionError(ctx context.Context, tx pgx.Tx, id string) error {
+	var active bool
+	err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&active)
+	if err != nil {
+		return translateError(err)
+	}
+	if !active {
+		return gorm.ErrRecordNotFound
+	}
+	return user.ErrVersionMismatch
+}
+
+// PurgeUser relies on the ON DELETE CASCADE foreign key to remove the user's
+// addresses.
+func (r *userRepo) PurgeUser(ctx context.Context, id string) error {
+	return r.execOne(ctx, outbox.EventUserPurged, id, "DELETE FROM users WHERE id = $1", id)
+}
+
+func (r *userRepo) RestoreUser(ctx context.Context, id string) error {
+	return r.execOne(ctx, outbox.EventUserRestored, id,
+		"UPDATE users SET deleted_at = NULL, updated_at = $2, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL", id, time.Now())
+}
+
+// SearchUsers ranks active users by trigram similarity and full-text rank,
+// with the query of the GORM repo. Addresses are not loaded.
+func (r *userRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, storage.SearchUsersSQL, pgx.NamedArgs(storage.SearchUsersArgs(query, limit, offset)))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	matches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entities.UserMatch, error) {
+		var m entities.UserMatch
+		err := row.Scan(&m.ID, &m.Name, &m.Email, &m.PhoneNumber, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt, &m.Score)
+		return m, err
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return matches, nil
+}
+
+// execOne runs a statement that must affect a row and records eventType for
+// the user in the same transaction. Like the GORM repo it reports
+// gorm.ErrRecordNotFound when no row matched.
+func (r *userRepo) execOne(ctx context.Context, eventType, id, sql string, args ...any) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return translateError(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return appendEvents(ctx, tx, eventType, []string{id}, []outbox.IDPayload{{ID: id}})
+	})
+}
+
+// ClearAllUsersDataFromDB relies on the ON DELETE CASCADE foreign key to
+// remove the addresses, like PurgeUser.
+func (r *userRepo) ClearAllUsersDataFromDB(ctx context.Context) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.pool), func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "DELETE FROM users RETURNING id")
+		if err != nil {
+			return fmt.Errorf("failed to clear users table: %w", translateError(err))
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("failed to clear users table: %w", translateError(err))
+		}
+		slices.Sort(ids)
+		payloads := make([]outbox.IDPayload, len(ids))
+		for i, id := range ids {
+			payloads[i] = outbox.IDPayload{ID: id}
+		}
+		return appendEvents(ctx, tx, outbox.EventUserPurged, ids, payloads)
+	})
+}
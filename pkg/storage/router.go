@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sika/config"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so that reads made with it go to the primary, for
+// callers that must see their own writes despite replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether ctx was marked by WithPrimary.
+func UsesPrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+type replica struct {
+	db      *gorm.DB
+	name    string
+	healthy atomic.Bool
+}
+
+// Router sends writes to the primary and spreads reads over the healthy read
+// replicas, falling back to the primary when none is healthy or a replica
+// fails a query.
+type Router struct {
+	primary  *gorm.DB
+	replicas []*replica
+	next     atomic.Uint32
+}
+
+// NewRouter returns a Router on primary. Without replicas every read goes to
+// the primary. Replicas start out healthy.
+func NewRouter(primary *gorm.DB, replicas ...*gorm.DB) *Router {
+	r := &Router{primary: primary}
+	for i, db := range replicas {
+		rep := &replica{db: db, name: fmt.Sprintf("replica %d", i)}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+// NewReplicaConnections opens dbConfig.Replicas with the pool settings of
+// the primary. Each entry is a Postgres DSN, or a file path for SQLite.
+func NewReplicaConnections(dbConfig config.DB) ([]*gorm.DB, error) {
+	var dbs []*gorm.DB
+	for _, target := range dbConfig.Replicas {
+		replicaConfig := dbConfig
+		replicaConfig.Replicas = nil
+		if dbConfig.Driver == config.DriverSQLite {
+			replicaConfig.Path = target
+		} else {
+			replicaConfig.DSN = target
+		}
+
+		db, err := NewGormConnection(replicaConfig)
+		if err != nil {
+			closeAll(dbs)
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
+
+// Primary returns the connection for writes.
+func (r *Router) Primary() *gorm.DB {
+	return r.primary
+}
+
+// Read runs fn on a healthy replica, or on the primary when ctx is marked by
+// WithPrimary or no replica is healthy. If the query fails on the replica for
+// any reason other than a missing record, the replica is marked unhealthy
+// and fn is retried on the primary. Within a transaction of Transactor fn
+// runs in it, to see its writes.
+func (r *Router) Read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(tx.WithContext(ctx))
+	}
+	rep := r.pick(ctx)
+	if rep == nil {
+		return fn(r.primary.WithContext(ctx))
+	}
+
+	err := fn(rep.db.WithContext(ctx))
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+		return err
+	}
+	if rep.healthy.Swap(false) {
+		log.Printf("%s marked unhealthy, reading from primary: %v", rep.name, err)
+	}
+	return fn(r.primary.WithContext(ctx))
+}
+
+// pick returns the next healthy replica in round-robin order, or nil when
+// the read must go to the primary.
+func (r *Router) pick(ctx context.Context) *replica {
+	if len(r.replicas) == 0 || UsesPrimary(ctx) {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(int(start)+i)%len(r.replicas)]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// CheckHealth pings every replica and updates its health.
+func (r *Router) CheckHealth(ctx context.Context) {
+	for _, rep := range r.replicas {
+		err := ping(ctx, rep.db)
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("%s is healthy again", rep.name)
+			} else {
+				log.Printf("%s marked unhealthy: %v", rep.name, err)
+			}
+		}
+	}
+}
+
+// StartHealthChecks runs CheckHealth every interval until the returned stop
+// function is called.
+func (r *Router) StartHealthChecks(interval time.Duration) (stop func()) {
+	if len(r.replicas) == 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkCtx, cancelCheck := context.WithTimeout(ctx, interval)
+				r.CheckHealth(checkCtx)
+				cancelCheck()
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Close closes the replica connections, the primary is left to its owner.
+func (r *Router) Close() error {
+	var dbs []*gorm.DB
+	for _, rep := range r.replicas {
+		dbs = append(dbs, rep.db)
+	}
+	return closeAll(dbs)
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func closeAll(dbs []*gorm.DB) error {
+	var errs []error
+	for _, db := range dbs {
+		sqlDB, err := db.DB()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, sqlDB.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRouter_ReadsFromReplica(t *testing.T) {
+	// Primary and replica are separate databases, so the data shows where a
+	// read went.
+	primary := newTestDB(t)
+	replica := newTestDB(t)
+	require.NoError(t, Migrate(primary))
+	require.NoError(t, Migrate(replica))
+	ctx := context.Background()
+
+	router := NewRouter(primary, replica)
+	userRepo := NewUserRepoWithRouter(router)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Primary"}))
+	require.NoError(t, NewUserRepo(replica).CreateUser(ctx, &entities.User{ID: "1", Name: "Replica"}))
+
+	got, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Replica", got.Name)
+
+	got, err = userRepo.GetUserByID(WithPrimary(ctx), "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Primary", got.Name)
+
+	// a record missing on a lagging replica is not a replica failure
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "2", Name: "Primary"}))
+	_, err = userRepo.GetUserByID(ctx, "2")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestRouter_FallsBackToPrimary(t *testing.T) {
+	primary := newTestDB(t)
+	replica := newTestDB(t)
+	require.NoError(t, Migrate(primary))
+	ctx := context.Background()
+
+	router := NewRouter(primary, replica)
+	userRepo := NewUserRepoWithRouter(router)
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Primary"}))
+
+	// the replica has no schema, so the query fails and is retried on the primary
+	got, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Primary", got.Name)
+	assert.False(t, router.replicas[0].healthy.Load())
+
+	// a replica that fails its health check stays out of rotation
+	sqlDB, err := replica.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	router.CheckHealth(ctx)
+	assert.False(t, router.replicas[0].healthy.Load())
+	assert.Nil(t, router.pick(ctx))
+}
+
+func TestRouter_CheckHealthRestoresReplica(t *testing.T) {
+	primary := newTestDB(t)
+	replica := newTestDB(t)
+	ctx := context.Background()
+
+	router := NewRouter(primary, replica)
+	router.replicas[0].healthy.Store(false)
+
+	router.CheckHealth(ctx)
+	assert.True(t, router.replicas[0].healthy.Load())
+	assert.NotNil(t, router.pick(ctx))
+}
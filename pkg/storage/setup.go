@@ -3,24 +3,133 @@ package storage
 import (
 	"fmt"
 	"sika/config"
-	"sika/pkg/storage/entities"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// NewGormConnection opens the database selected by dbConfig.Driver.
+func NewGormConnection(dbConfig config.DB) (*gorm.DB, error) {
+	switch dbConfig.Driver {
+	case "", config.DriverPostgres:
+		return NewPostgresGormConnection(dbConfig)
+	case config.DriverSQLite:
+		return NewSQLiteGormConnection(dbConfig)
+	default:
+		return nil, fmt.Errorf("unknown db driver %q, expected %s or %s", dbConfig.Driver, config.DriverPostgres, config.DriverSQLite)
+	}
+}
+
+// gormConfig translates constraint violations into gorm.ErrDuplicatedKey and
+// gorm.ErrForeignKeyViolated, like the memory and pgx repos report them.
+func gormConfig() *gorm.Config {
+	return &gorm.Config{TranslateError: true}
+}
+
 func NewPostgresGormConnection(dbConfig config.DB) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		dbConfig.Host, dbConfig.User, dbConfig.Pass, dbConfig.DBName, dbConfig.Port)
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(PostgresDSN(dbConfig)), gormConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if dbConfig.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	}
+	if dbConfig.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	}
+	if dbConfig.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	}
+	if dbConfig.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime)
+	}
+	return db, nil
 }
 
-func Migrate(db *gorm.DB) error {
-	migrator := db.Migrator()
+// PostgresDSN returns dbConfig.DSN when set, otherwise it builds a key=value
+// connection string from the individual fields. sslmode defaults to disable.
+func PostgresDSN(dbConfig config.DB) string {
+	if dbConfig.DSN != "" {
+		return dbConfig.DSN
+	}
+
+	sslMode := dbConfig.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	params := []struct{ key, value string }{
+		{"host", dbConfig.Host},
+		{"user", dbConfig.User},
+		{"password", dbConfig.Pass},
+		{"dbname", dbConfig.DBName},
+		{"sslmode", sslMode},
+		{"sslrootcert", dbConfig.SSLRootCert},
+		{"application_name", dbConfig.ApplicationName},
+	}
+	if dbConfig.Port > 0 {
+		params = append(params, struct{ key, value string }{"port", strconv.Itoa(dbConfig.Port)})
+	}
+	if dbConfig.ConnectTimeout > 0 {
+		// libpq only understands whole seconds, round up so 500ms is not "no timeout"
+		seconds := int((dbConfig.ConnectTimeout + time.Second - 1) / time.Second)
+		params = append(params, struct{ key, value string }{"connect_timeout", strconv.Itoa(seconds)})
+	}
+	if dbConfig.StatementTimeout > 0 {
+		// sent as a run-time parameter, in milliseconds
+		params = append(params, struct{ key, value string }{"statement_timeout", strconv.FormatInt(dbConfig.StatementTimeout.Milliseconds(), 10)})
+	}
+
+	var parts []string
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes values containing spaces, quotes or backslashes as
+// required by the key=value connection string format.
+func quoteDSNValue(v string) string {
+	if !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+func NewSQLiteGormConnection(dbConfig config.DB) (*gorm.DB, error) {
+	path := dbConfig.Path
+	if path == "" {
+		return nil, fmt.Errorf("db path is required for the %s driver", config.DriverSQLite)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := gorm.Open(sqlite.Open(path+sep+"_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"), gormConfig())
+	if err != nil {
+		return nil, err
+	}
 
-	err := migrator.AutoMigrate(&entities.User{}, &entities.Address{})
+	// SQLite allows a single writer, and every connection to ":memory:" gets
+	// its own empty database, so all callers share one connection.
+	sqlDB, err := db.DB()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	sqlDB.SetMaxOpenConns(1)
+	return db, nil
 }
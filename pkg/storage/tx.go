@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"sika/pkg/storage/entities"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type txKey struct{}
+
+// Transactor runs functions in one transaction of the primary. The repos
+// built on the same database take the transaction from the context, so that
+// a change of a user, its version and its audit entry commit together.
+type Transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{
+		db: db,
+	}
+}
+
+// InTx runs fn in a transaction that is committed when fn returns nil and
+// rolled back otherwise. Statements of the repos made with the context
+// passed to fn, reads included, run in the transaction. Within a transaction
+// fn joins it.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction ctx was given by InTx, or db outside of one.
+// A repo's own Transaction on it becomes a savepoint.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// lockUsers locks the rows of the users until the end of the transaction of
+// tx, in ID order so that transactions locking several users do not
+// deadlock. Writes of a user and of its history and outbox events then
+// follow the order in which the transactions commit. SQLite has a single
+// writer and needs no lock.
+func lockUsers(tx *gorm.DB, userIDs ...string) error {
+	if tx.Dialector.Name() != "postgres" || len(userIDs) == 0 {
+		return nil
+	}
+	ids := append([]string(nil), userIDs...)
+	sort.Strings(ids)
+	var locked []string
+	return tx.Model(&entities.User{}).Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).Order("id").Pluck("id", &locked).Error
+}
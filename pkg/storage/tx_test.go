@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"sika/pkg/storage/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactor_InTx(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	auditRepo := NewAuditRepo(db)
+	transactor := NewTransactor(db)
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Old"}))
+
+	// a failure after the writes rolls back all of them
+	err := transactor.InTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, userRepo.UpdateUser(ctx, &entities.User{ID: "1", Name: "New"}))
+		// reads in the transaction see its writes
+		u, err := userRepo.GetUserByID(WithPrimary(ctx), "1")
+		require.NoError(t, err)
+		assert.Equal(t, "New", u.Name)
+		require.NoError(t, auditRepo.AppendEntry(ctx, &entities.AuditEntry{Actor: "alice", Action: "update", UserID: "1"}))
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	u, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Old", u.Name)
+	entries, err := auditRepo.GetEntriesByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	var events int64
+	require.NoError(t, db.Model(&entities.OutboxEvent{}).Count(&events).Error)
+	assert.Equal(t, int64(1), events, "only the event of the create is kept")
+
+	// a transaction that succeeds commits all writes
+	err = transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := userRepo.UpdateUser(ctx, &entities.User{ID: "1", Name: "New"}); err != nil {
+			return err
+		}
+		// a nested transaction joins the outer one
+		return transactor.InTx(ctx, func(ctx context.Context) error {
+			return auditRepo.AppendEntry(ctx, &entities.AuditEntry{Actor: "alice", Action: "update", UserID: "1"})
+		})
+	})
+	require.NoError(t, err)
+
+	u, err = userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", u.Name)
+	entries, err = auditRepo.GetEntriesByUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
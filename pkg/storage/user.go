@@ -2,49 +2,476 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sika/internal/outbox"
 	"sika/internal/user"
+	"sika/pkg/filter"
 	"sika/pkg/storage/entities"
+	"strings"
+	"time"
+	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	router *Router
 }
 
 func NewUserRepo(db *gorm.DB) user.Repo {
-	return &userRepo{
-		db: db,
-	}
+	return NewUserRepoWithRouter(NewRouter(db))
+}
+
+// NewUserRepoWithRouter returns a repo that writes to the router's primary and
+// reads from its replicas. Its errors are translated by UserError.
+func NewUserRepoWithRouter(router *Router) user.Repo {
+	return WithUserErrors(&userRepo{
+		db:     router.Primary(),
+		router: router,
+	})
+}
+
+// upsertUser overwrites an existing user on insert, except for created_at,
+// and increments its version.
+var upsertUser = clause.OnConflict{
+	Columns: []clause.Column{{Name: "id"}},
+	DoUpdates: append(
+		clause.AssignmentColumns([]string{"name", "email", "phone_number", "updated_at", "deleted_at"}),
+		clause.Assignment{Column: clause.Column{Name: "version"}, Value: gorm.Expr("users.version + 1")},
+	),
 }
 
+// CreateUser upserts u. Unlike Save, the upsert keeps created_at of an
+// existing row and clears deleted_at, so re-importing a user restores it.
+//
+// The event type is decided by the insert itself: it does nothing when the
+// ID exists, and only then the user is overwritten. A concurrent insert of
+// the same ID waits for the first to commit and then finds the row, so a
+// user is created exactly once.
 func (r *userRepo) CreateUser(ctx context.Context, u *entities.User) error {
-	if err := r.db.WithContext(ctx).Save(&u).Error; err != nil {
-		return err
-	}
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoNothing: true}).Create(u)
+		if result.Error != nil {
+			return emailError(result.Error)
+		}
 
-	return nil
+		eventType := outbox.EventUserCreated
+		if result.RowsAffected == 0 {
+			if err := tx.Clauses(upsertUser).Create(u).Error; err != nil {
+				return emailError(err)
+			}
+			eventType = outbox.EventUserUpdated
+		}
+		return appendEvent(tx, eventType, u.ID, u)
+	})
 }
 
 func (r *userRepo) CreateBatchUsers(ctx context.Context, users []entities.User) error {
-	if err := r.db.WithContext(ctx).CreateInBatches(users, 10).Error; err != nil {
+	ids := make([]string, len(users))
+	for i := range users {
+		ids[i] = users[i].ID
+	}
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(users, 10).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, outbox.EventUserCreated, ids, users)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return r.duplicateError(ctx, err, ids)
+	}
+	return err
+}
+
+// duplicateError tells which unique index a failed insert of the users ids
+// violated. It is the primary key when one of the IDs is stored, even
+// soft-deleted, or given twice, and the email index otherwise. The insert
+// has been rolled back, within a transaction to its savepoint, so the IDs
+// can be looked up.
+func (r *userRepo) duplicateError(ctx context.Context, err error, ids []string) error {
+	var stored int64
+	if cerr := conn(ctx, r.db).Unscoped().Model(&entities.User{}).Where("id IN ?", ids).Count(&stored).Error; cerr != nil {
 		return err
 	}
-	return nil
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			return err
+		}
+		seen[id] = struct{}{}
+	}
+	if stored > 0 {
+		return err
+	}
+	return emailError(err)
+}
+
+// emailError reports a unique violation as user.ErrEmailTaken. It is meant
+// for writes that cannot clash on the ID, which leaves the email index as
+// the only unique index of users.
+func emailError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %w", user.ErrEmailTaken, err)
+	}
+	return err
 }
+
 func (r *userRepo) GetUserByID(ctx context.Context, id string) (*entities.User, error) {
+	return r.GetUserProjection(ctx, id, user.Projection{IncludeAddresses: true}, false)
+}
+
+func (r *userRepo) GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error) {
+	return r.GetUserProjection(ctx, id, user.Projection{IncludeAddresses: true}, true)
+}
+
+// GetUserProjection selects only the columns of p, and preloads the
+// addresses only when p includes them.
+func (r *userRepo) GetUserProjection(ctx context.Context, id string, p user.Projection, includeDeleted bool) (*entities.User, error) {
+	var u entities.User
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		tx := project(db, p)
+		if includeDeleted {
+			tx = tx.Unscoped()
+		}
+		return tx.First(&u, "id=?", id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// project selects the columns of p and the extra ones and preloads the
+// addresses when p includes them.
+func project(db *gorm.DB, p user.Projection, extra ...string) *gorm.DB {
+	if len(p.Fields) > 0 {
+		db = db.Select(p.Columns(extra...))
+	}
+	if p.IncludeAddresses {
+		db = db.Preload("Addresses")
+	}
+	return db
+}
+
+// GetUsersByIDs reads the users with one IN query and their addresses with
+// another.
+func (r *userRepo) GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error) {
+	users := []entities.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Preload("Addresses").Where("id IN ?", ids).Find(&users).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetAllUserIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Model(&entities.User{}).Pluck("id", &ids).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// listColumns are the sort expressions of ListUsers, matching the keyset
+// indexes of migration 0010. NULL names and emails sort as empty strings.
+var listColumns = map[string]string{
+	user.SortByName:      "COALESCE(name, '')",
+	user.SortByEmail:     "COALESCE(email, '')",
+	user.SortByCreatedAt: "created_at",
+}
+
+// userFilterSchema maps the user filter fields to SQL. NULL strings compare
+// as empty strings, like they are read.
+var userFilterSchema = filter.SQLSchema{
+	Columns: map[string]string{
+		"id":            "users.id",
+		"name":          "COALESCE(users.name, '')",
+		"email":         "COALESCE(users.email, '')",
+		"phone_number":  "COALESCE(users.phone_number, '')",
+		"created_at":    "users.created_at",
+		"updated_at":    "users.updated_at",
+		"street":        "COALESCE(a.street, '')",
+		"city":          "COALESCE(a.city, '')",
+		"state":         "COALESCE(a.state, '')",
+		"zip_code":      "COALESCE(a.zip_code, '')",
+		"country":       "COALESCE(a.country, '')",
+		"address_count": "(SELECT COUNT(*) FROM addresses a WHERE a.user_id = users.id AND a.deleted_at IS NULL)",
+	},
+	Groups: map[string]string{
+		user.GroupAddress: "EXISTS (SELECT 1 FROM addresses a WHERE a.user_id = users.id AND a.deleted_at IS NULL AND %s)",
+	},
+}
+
+// ListUsers seeks past the cursor with a row comparison on the sort column
+// and ID, so every page is an index range scan however deep it is.
+func (r *userRepo) ListUsers(ctx context.Context, q user.ListQuery) ([]entities.User, error) {
+	dir, cmp := "ASC", ">"
+	if q.Descending {
+		dir, cmp = "DESC", "<"
+	}
+
+	users := []entities.User{}
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		tx := db.Limit(q.Limit)
+		col, ok := listColumns[q.SortBy]
+		if ok {
+			tx = tx.Order(col + " " + dir + ", id " + dir)
+		} else {
+			tx = tx.Order("id " + dir)
+		}
+
+		if q.After != nil {
+			switch q.SortBy {
+			case user.SortByID:
+				tx = tx.Where("id "+cmp+" ?", q.After.ID)
+			case user.SortByCreatedAt:
+				// the cursor keeps the zone offset read from the row, SQLite
+				// compares times as text
+				tx = tx.Where("(created_at, id) "+cmp+" (?, ?)", *q.After.CreatedAt, q.After.ID)
+			default:
+				tx = tx.Where("("+col+", id) "+cmp+" (?, ?)", q.After.Value, q.After.ID)
+			}
+		}
+		if q.Filter != nil {
+			cond, args, err := filter.ToSQL(q.Filter, userFilterSchema)
+			if err != nil {
+				return err
+			}
+			tx = tx.Where(cond, args...)
+		}
+		return project(tx, q.Projection, q.SortBy).Find(&users).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
 	var user entities.User
-	result := r.db.WithContext(ctx).Preload("Addresses").First(&user, "id=?", id)
-	if result.Error != nil {
-		return nil, result.Error
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Where("lower(email) = lower(?)", email).Order("id").First(&user).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *userRepo) ClearAllUsersDataFromDB() error {
-	if err := r.db.Exec("DELETE FROM users").Error; err != nil {
-		return fmt.Errorf("failed to clear users table: %w", err)
+// UpdateUser increments the version of the user. When u.Version is set it
+// must be the stored version, else user.ErrVersionMismatch is returned. On
+// success u.Version is the new version.
+func (r *userRepo) UpdateUser(ctx context.Context, u *entities.User) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := whereVersion(tx.Model(&entities.User{}).Where("id=?", u.ID), u.Version).Updates(map[string]interface{}{
+			"name":         u.Name,
+			"email":        u.Email,
+			"phone_number": u.PhoneNumber,
+			"updated_at":   now,
+			"version":      gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return emailError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return versionError(tx, u.ID)
+		}
+
+		var stored entities.User
+		if err := tx.Select("created_at", "version").First(&stored, "id=?", u.ID).Error; err != nil {
+			return err
+		}
+		u.CreatedAt, u.UpdatedAt, u.DeletedAt, u.Version = stored.CreatedAt, now, gorm.DeletedAt{}, stored.Version
+		return appendEvent(tx, outbox.EventUserUpdated, u.ID, u)
+	})
+}
+
+// DeleteUser soft-deletes the user by setting deleted_at and increments its
+// version. A version other than 0 must be the stored one.
+func (r *userRepo) DeleteUser(ctx context.Context, id string, version int64) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := whereVersion(tx.Model(&entities.User{}).Where("id=?", id), version).UpdateColumns(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return versionError(tx, id)
+		}
+		return appendEvent(tx, outbox.EventUserDeleted, id, outbox.IDPayload{ID: id})
+	})
+}
+
+// whereVersion restricts a write to the version, unless it is 0.
+func whereVersion(tx *gorm.DB, version int64) *gorm.DB {
+	if version == 0 {
+		return tx
+	}
+	return tx.Where("version=?", version)
+}
+
+// versionError tells why a write of the active user id matched no row.
+func versionError(tx *gorm.DB, id string) error {
+	var active int64
+	if err := tx.Model(&entities.User{}).Where("id=?", id).Count(&active).Error; err != nil {
+		return err
+	}
+	if active == 0 {
+		return gorm.ErrRecordNotFound
 	}
-	return nil
+	return user.ErrVersionMismatch
+}
+
+// PurgeUser removes the user row and relies on the ON DELETE CASCADE foreign
+// key to remove the user's addresses.
+func (r *userRepo) PurgeUser(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Delete(&entities.User{}, "id=?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return appendEvent(tx, outbox.EventUserPurged, id, outbox.IDPayload{ID: id})
+	})
+}
+
+func (r *userRepo) RestoreUser(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&entities.User{}).
+			Where("id=? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return emailError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return appendEvent(tx, outbox.EventUserRestored, id, outbox.IDPayload{ID: id})
+	})
+}
+
+// SearchUsersSQL ranks active users by the best trigram similarity of the
+// query to their name, email or phone digits, or by its full-text rank. Text
+// is compared lowercased and without accents. It is shared with the pgx
+// repo: GORM and pgx both bind the named parameters @query, @limit and
+// @offset, see SearchUsersArgs.
+const SearchUsersSQL = `
+WITH q AS (
+    SELECT immutable_unaccent(lower(@query)) AS text
+), terms AS (
+    SELECT text,
+        replace(replace(replace(text, '\', '\\'), '%', '\%'), '_', '\_') AS pattern,
+        regexp_replace(text, '\D', '', 'g') AS digits,
+        plainto_tsquery('simple', text) AS tsquery
+    FROM q
+)
+SELECT u.id, u.name, u.email, u.phone_number, u.created_at, u.updated_at, u.deleted_at,
+    GREATEST(
+        similarity(immutable_unaccent(lower(u.name)), t.text),
+        similarity(immutable_unaccent(lower(u.email)), t.text),
+        CASE WHEN t.digits <> '' THEN similarity(regexp_replace(u.phone_number, '\D', '', 'g'), t.digits) ELSE 0 END,
+        ts_rank(u.search_vector, t.tsquery)
+    ) AS score
+FROM users u, terms t
+WHERE u.deleted_at IS NULL AND (
+    immutable_unaccent(lower(u.name)) % t.text
+    OR immutable_unaccent(lower(u.email)) % t.text
+    OR immutable_unaccent(lower(u.name)) LIKE '%' || t.pattern || '%'
+    OR immutable_unaccent(lower(u.email)) LIKE '%' || t.pattern || '%'
+    OR (t.digits <> '' AND regexp_replace(u.phone_number, '\D', '', 'g') LIKE '%' || t.digits || '%')
+    OR u.search_vector @@ t.tsquery
+)
+ORDER BY score DESC, u.id
+LIMIT @limit OFFSET @offset`
+
+// searchCandidates returns the LIKE conditions of SearchUsers on SQLite. The
+// digits of the query may be separated by punctuation in a phone number.
+func searchCandidates(db *gorm.DB, query string) *gorm.DB {
+	cond := db.Session(&gorm.Session{NewDB: true}).Where("1 = 0")
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		pattern := "%" + likeEscaper.Replace(w) + "%"
+		cond = cond.Or(`name LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+	if digits := user.Digits(query); digits != "" {
+		cond = cond.Or("phone_number LIKE ?", "%"+strings.Join(strings.Split(digits, ""), "%")+"%")
+	}
+	return cond
+}
+
+// likeEscaper escapes the wildcards of LIKE with a backslash.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchUsersArgs returns the named arguments of SearchUsersSQL.
+func SearchUsersArgs(query string, limit, offset int) map[string]any {
+	return map[string]any{"query": query, "limit": limit, "offset": offset}
+}
+
+// searchScanLimit is the most users a search reads on SQLite, which lacks
+// the trigram and full-text indexes.
+const searchScanLimit = 10000
+
+// SearchUsers uses the trigram and full-text indexes on Postgres. On SQLite
+// the candidates are the active users whose name or email contains a word
+// of the query, with LIKE, case-insensitive for ASCII only, or whose phone
+// number contains its digits. At most searchScanLimit of them, by ID, are
+// read and scored in Go like the memory repo, so matches that are only
+// similar, or past the limit, are not found there. Addresses are not loaded.
+func (r *userRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error) {
+	if r.db.Dialector.Name() != "postgres" {
+		var users []entities.User
+		err := r.router.Read(ctx, func(db *gorm.DB) error {
+			return db.Where(searchCandidates(db, query)).Order("id").Limit(searchScanLimit).Find(&users).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		return user.RankUsers(users, query, limit, offset), nil
+	}
+
+	matches := []entities.UserMatch{}
+	err := r.router.Read(ctx, func(db *gorm.DB) error {
+		return db.Raw(SearchUsersSQL, SearchUsersArgs(query, limit, offset)).Scan(&matches).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// ClearAllUsersDataFromDB relies on the ON DELETE CASCADE foreign key to
+// remove the addresses, like PurgeUser.
+func (r *userRepo) ClearAllUsersDataFromDB(ctx context.Context) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Model(&entities.User{}).Unscoped().Order("id").Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to list users to clear: %w", err)
+		}
+		if err := tx.Exec("DELETE FROM users").Error; err != nil {
+			return fmt.Errorf("failed to clear users table: %w", err)
+		}
+		payloads := make([]outbox.IDPayload, len(ids))
+		for i, id := range ids {
+			payloads[i] = outbox.IDPayload{ID: id}
+		}
+		return appendEvents(tx, outbox.EventUserPurged, ids, payloads)
+	})
 }
@@ -0,0 +1,480 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestUserRepo_CreateUserKeepsCreatedAt(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Old"}))
+	first, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, first.CreatedAt.IsZero())
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "New"}))
+	second, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", second.Name)
+	assert.True(t, first.CreatedAt.Equal(second.CreatedAt))
+	assert.True(t, second.UpdatedAt.After(first.UpdatedAt))
+}
+
+func TestUserRepo_SoftDeleteAndRestore(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{
+		ID:        "1",
+		Addresses: []entities.Address{{Street: "1 Test St"}},
+	}))
+
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	assert.ErrorIs(t, userRepo.DeleteUser(ctx, "1", 0), gorm.ErrRecordNotFound)
+
+	_, err := userRepo.GetUserByID(ctx, "1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	ids, err := userRepo.GetAllUserIDs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	deleted, err := userRepo.GetUserByIDWithDeleted(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, deleted.DeletedAt.Valid)
+	assert.Len(t, deleted.Addresses, 1)
+
+	require.NoError(t, userRepo.RestoreUser(ctx, "1"))
+	assert.ErrorIs(t, userRepo.RestoreUser(ctx, "1"), gorm.ErrRecordNotFound)
+
+	restored, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+	assert.Len(t, restored.Addresses, 1)
+}
+
+func TestUserRepo_SearchUsers(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	for _, u := range []entities.User{
+		{ID: "1", Name: "José Álvarez", Email: "jose@example.com", PhoneNumber: "555-0101"},
+		{ID: "2", Name: "Josephine Alvares", Email: "jo@example.com", PhoneNumber: "555-0202"},
+		{ID: "3", Name: "Mary Smith", Email: "mary@example.com", PhoneNumber: "555-0303"},
+	} {
+		u := u
+		require.NoError(t, userRepo.CreateUser(ctx, &u))
+	}
+	require.NoError(t, userRepo.DeleteUser(ctx, "2", 0))
+
+	matches, err := userRepo.SearchUsers(ctx, "jose alvarez", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "1", matches[0].ID)
+	assert.Equal(t, 1.0, matches[0].Score)
+
+	matches, err = userRepo.SearchUsers(ctx, "0303", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "3", matches[0].ID)
+
+	matches, err = userRepo.SearchUsers(ctx, "example.com", 1, 1)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "3", matches[0].ID)
+
+	// phone digits match across punctuation, wildcards are taken literally
+	matches, err = userRepo.SearchUsers(ctx, "(555) 0303", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "3", matches[0].ID)
+	matches, err = userRepo.SearchUsers(ctx, "%_", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+// TestSearchUsersSQL checks that GORM and pgx, which share the query, both
+// bind its named parameters. The query itself needs Postgres and runs in the
+// integration tests.
+func TestSearchUsersSQL(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	stmt := db.Raw(SearchUsersSQL, SearchUsersArgs("jose", 10, 20)).Statement
+	assert.Contains(t, stmt.SQL.String(), "lower($1)")
+	assert.Contains(t, stmt.SQL.String(), "LIMIT $2 OFFSET $3")
+	assert.Contains(t, stmt.SQL.String(), "u.search_vector @@ t.tsquery")
+	assert.Equal(t, []interface{}{"jose", 10, 20}, stmt.Vars)
+
+	sql, args, err := pgx.NamedArgs(SearchUsersArgs("jose", 10, 20)).RewriteQuery(context.Background(), nil, SearchUsersSQL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, stmt.SQL.String(), sql)
+	assert.Equal(t, []any{"jose", 10, 20}, args)
+}
+
+func TestUserRepo_ListUsers(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	for _, u := range []entities.User{
+		{ID: "1", Name: "Carol", Email: "c@example.com", Addresses: []entities.Address{{Street: "1 Test St"}}},
+		{ID: "2", Name: "Alice", Email: "b@example.com"},
+		{ID: "3", Name: "Bob", Email: "a@example.com"},
+		{ID: "4", Name: "Alice", Email: "d@example.com"},
+		{ID: "5", Name: "Deleted", Email: "e@example.com"},
+	} {
+		u := u
+		require.NoError(t, userRepo.CreateUser(ctx, &u))
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, userRepo.DeleteUser(ctx, "5", 0))
+
+	tests := []struct {
+		sortBy     string
+		descending bool
+		wantIDs    []string
+	}{
+		{sortBy: user.SortByID, wantIDs: []string{"1", "2", "3", "4"}},
+		{sortBy: user.SortByName, wantIDs: []string{"2", "4", "3", "1"}},
+		{sortBy: user.SortByName, descending: true, wantIDs: []string{"1", "3", "4", "2"}},
+		{sortBy: user.SortByEmail, wantIDs: []string{"3", "2", "1", "4"}},
+		{sortBy: user.SortByCreatedAt, descending: true, wantIDs: []string{"4", "3", "2", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s desc=%t", tt.sortBy, tt.descending), func(t *testing.T) {
+			// walk all pages of two
+			var ids []string
+			q := user.ListQuery{SortBy: tt.sortBy, Descending: tt.descending, Limit: 2}
+			for {
+				page, err := userRepo.ListUsers(ctx, q)
+				require.NoError(t, err)
+				for _, u := range page {
+					ids = append(ids, u.ID)
+					assert.Nil(t, u.Addresses)
+				}
+				if len(page) < q.Limit {
+					break
+				}
+				after := user.CursorAfter(page[len(page)-1], tt.sortBy, tt.descending)
+				q.After = &after
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+
+	page, err := userRepo.ListUsers(ctx, user.ListQuery{SortBy: user.SortByID, Limit: 1, Projection: user.Projection{IncludeAddresses: true}})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Len(t, page[0].Addresses, 1)
+}
+
+func TestUserRepo_ListUsersFilter(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	for _, u := range []entities.User{
+		{ID: "1", Name: "Ana", Addresses: []entities.Address{
+			{Country: "AR", City: "Glendale"}, {Country: "AR", City: "Rosario"}, {Country: "UY", City: "Salto"},
+		}},
+		{ID: "2", Name: "Bea", Addresses: []entities.Address{{Country: "AR", City: "Glenwood"}}},
+		{ID: "3", Name: "Cid", Addresses: []entities.Address{
+			{Country: "US", City: "Glendale"}, {Country: "AR", City: "Mendoza"}, {Country: "AR", City: "Salta"},
+		}},
+		{ID: "4", Name: "Dee"},
+	} {
+		u := u
+		require.NoError(t, userRepo.CreateUser(ctx, &u))
+	}
+
+	tests := []struct {
+		filter  string
+		wantIDs []string
+	}{
+		{filter: "country = AR and city startswith 'Glen' and address_count > 2", wantIDs: []string{"1", "3"}},
+		{filter: "city startswith 'glen' and address_count <= 1", wantIDs: []string{"2"}},
+		{filter: "address_count = 0 or name in (Bea, 'Zed')", wantIDs: []string{"2", "4"}},
+		{filter: "not country = AR", wantIDs: []string{"4"}},
+		{filter: "name contains '%'", wantIDs: nil},
+		{filter: "created_at > '2000-01-01' and updated_at < '2999-01-01'", wantIDs: []string{"1", "2", "3", "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := user.ParseFilter(tt.filter)
+			require.NoError(t, err)
+
+			page, err := userRepo.ListUsers(ctx, user.ListQuery{SortBy: user.SortByID, Limit: 10, Filter: f})
+			require.NoError(t, err)
+			var ids []string
+			for _, u := range page {
+				ids = append(ids, u.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestUserRepo_UpdateUserAndGetUserByEmail(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{
+		ID:        "1",
+		Name:      "Old",
+		Email:     "old@example.com",
+		Addresses: []entities.Address{{Street: "1 Test St"}},
+	}))
+	err := userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "1"}})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	found, err := userRepo.GetUserByEmail(ctx, "OLD@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "1", found.ID)
+
+	updated := &entities.User{ID: "1", Name: "New", Email: "new@example.com", PhoneNumber: "5550100"}
+	require.NoError(t, userRepo.UpdateUser(ctx, updated))
+	assert.False(t, updated.CreatedAt.IsZero())
+
+	stored, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", stored.Name)
+	assert.Equal(t, "new@example.com", stored.Email)
+	assert.Equal(t, "5550100", stored.PhoneNumber)
+	assert.Len(t, stored.Addresses, 1)
+	assert.True(t, stored.CreatedAt.Equal(updated.CreatedAt))
+
+	_, err = userRepo.GetUserByEmail(ctx, "old@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, updated), gorm.ErrRecordNotFound)
+	_, err = userRepo.GetUserByEmail(ctx, "new@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserRepo_EmailUnique(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	require.NoError(t, userRepo.CreateBatchUsers(ctx, []entities.User{
+		{ID: "1", Email: "taken@example.com"},
+		{ID: "2", Email: "free@example.com"},
+		// users imported without an email are exempt
+		{ID: "3"},
+		{ID: "4"},
+	}))
+
+	tests := []struct {
+		name     string
+		write    func(ctx context.Context) error
+		expected error
+	}{
+		{
+			name: "create",
+			write: func(ctx context.Context) error {
+				return userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "5", Email: "TAKEN@example.com"}})
+			},
+			expected: user.ErrEmailTaken,
+		},
+		{
+			name: "create in a batch",
+			write: func(ctx context.Context) error {
+				return userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "5", Email: "new@example.com"}, {ID: "6", Email: "New@example.com"}})
+			},
+			expected: user.ErrEmailTaken,
+		},
+		{
+			name: "create with a taken ID",
+			write: func(ctx context.Context) error {
+				return userRepo.CreateBatchUsers(ctx, []entities.User{{ID: "2", Email: "taken@example.com"}})
+			},
+			expected: user.ErrIDTaken,
+		},
+		{
+			name: "upsert",
+			write: func(ctx context.Context) error {
+				return userRepo.CreateUser(ctx, &entities.User{ID: "2", Email: "taken@example.com"})
+			},
+			expected: user.ErrEmailTaken,
+		},
+		{
+			name: "update",
+			write: func(ctx context.Context) error {
+				return userRepo.UpdateUser(ctx, &entities.User{ID: "2", Email: "Taken@Example.com"})
+			},
+			expected: user.ErrEmailTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			err := tt.write(ctx)
+			// within a transaction the IDs are looked up after the savepoint
+			txErr := NewTransactor(db).InTx(ctx, tt.write)
+
+			// Assert
+			for _, err := range []error{err, txErr} {
+				assert.ErrorIs(t, err, tt.expected)
+				if tt.expected == user.ErrEmailTaken {
+					assert.NotErrorIs(t, err, user.ErrIDTaken)
+				}
+			}
+		})
+	}
+
+	// a soft-deleted user frees its email, and cannot be restored while
+	// another user has it
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 0))
+	require.NoError(t, userRepo.UpdateUser(ctx, &entities.User{ID: "2", Email: "taken@example.com"}))
+	assert.ErrorIs(t, userRepo.RestoreUser(ctx, "1"), user.ErrEmailTaken)
+}
+
+func TestUserRepo_Versions(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+
+	userRepo := NewUserRepo(db)
+	addressRepo := NewAddressRepo(db)
+
+	// every write of the user or of its addresses increments the version
+	u := &entities.User{ID: "1", Name: "Old", Email: "old@example.com"}
+	require.NoError(t, userRepo.CreateUser(ctx, u))
+	stored, err := userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stored.Version)
+
+	updated := &entities.User{ID: "1", Name: "New", Email: "new@example.com", Version: 1}
+	require.NoError(t, userRepo.UpdateUser(ctx, updated))
+	assert.Equal(t, int64(2), updated.Version)
+	stale := &entities.User{ID: "1", Name: "Stale", Version: 1}
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, stale), user.ErrVersionMismatch)
+	assert.ErrorIs(t, userRepo.UpdateUser(ctx, &entities.User{ID: "2", Version: 1}), user.ErrNotFound)
+
+	a := &entities.Address{UserID: "1", Street: "1 Test St"}
+	require.NoError(t, addressRepo.CreateAddress(ctx, a))
+	a.Street = "2 Test St"
+	require.NoError(t, addressRepo.UpdateAddress(ctx, a))
+	require.NoError(t, addressRepo.DeleteAddress(ctx, "1", a.ID))
+	stored, err = userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "New", stored.Name)
+	assert.Equal(t, int64(5), stored.Version)
+
+	assert.ErrorIs(t, userRepo.DeleteUser(ctx, "1", 2), user.ErrVersionMismatch)
+	require.NoError(t, userRepo.DeleteUser(ctx, "1", 5))
+	assert.ErrorIs(t, userRepo.DeleteUser(ctx, "1", 6), user.ErrNotFound)
+	require.NoError(t, userRepo.RestoreUser(ctx, "1"))
+	require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: "1", Name: "Imported"}))
+	stored, err = userRepo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), stored.Version)
+}
+
+func TestUserRepo_GetUsersByIDs(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+	repo := NewUserRepo(db)
+
+	require.NoError(t, repo.CreateBatchUsers(ctx, []entities.User{
+		{ID: "1", Addresses: []entities.Address{{Street: "1 Test St"}, {Street: "2 Test St"}}},
+		{ID: "2"},
+		{ID: "3"},
+	}))
+	require.NoError(t, repo.DeleteUser(ctx, "3", 0))
+
+	// one query for the users and one for their addresses
+	var queries int
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:count", func(*gorm.DB) { queries++ }))
+	users, err := repo.GetUsersByIDs(ctx, []string{"1", "2", "3", "404"})
+	require.NoError(t, db.Callback().Query().Remove("test:count"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, queries)
+
+	require.Len(t, users, 2)
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	assert.Len(t, users[0].Addresses, 2)
+	assert.Empty(t, users[1].Addresses)
+
+	users, err = repo.GetUsersByIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestUserRepo_GetUserProjection(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Migrate(db))
+	ctx := context.Background()
+	repo := NewUserRepo(db)
+
+	require.NoError(t, repo.CreateBatchUsers(ctx, []entities.User{{
+		ID:          "1",
+		Name:        "Test User",
+		Email:       "test@example.com",
+		PhoneNumber: "5550100",
+		Addresses:   []entities.Address{{Street: "1 Test St"}},
+	}}))
+
+	// only the selected columns are read and the addresses are not queried
+	var statements []string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:record", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	}))
+	u, err := repo.GetUserProjection(ctx, "1", user.Projection{Fields: []string{"name"}}, false)
+	require.NoError(t, db.Callback().Query().Remove("test:record"))
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Contains(t, statements[0], "SELECT `id`,`name`,`version` FROM `users`")
+	assert.Equal(t, entities.User{ID: "1", Name: "Test User", Version: 1}, *u)
+
+	u, err = repo.GetUserProjection(ctx, "1", user.Projection{Fields: []string{"email"}, IncludeAddresses: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", u.Email)
+	assert.Empty(t, u.Name)
+	require.Len(t, u.Addresses, 1)
+
+	require.NoError(t, repo.DeleteUser(ctx, "1", 0))
+	_, err = repo.GetUserProjection(ctx, "1", user.Projection{}, false)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	u, err = repo.GetUserProjection(ctx, "1", user.Projection{}, true)
+	require.NoError(t, err)
+	assert.True(t, u.DeletedAt.Valid)
+	assert.Nil(t, u.Addresses)
+
+	// a listing reads the sort field along with the fields asked for
+	require.NoError(t, repo.RestoreUser(ctx, "1"))
+	page, err := repo.ListUsers(ctx, user.ListQuery{SortBy: user.SortByEmail, Limit: 1, Projection: user.Projection{Fields: []string{"name"}}})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, entities.User{ID: "1", Name: "Test User", Email: "test@example.com", Version: 3}, page[0])
+}
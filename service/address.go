@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/user"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+)
+
+// GetUserAddresses returns the addresses of the active user ordered by ID.
+// It is recorded as a view of the user.
+func (s *UserService) GetUserAddresses(ctx context.Context, userID string) ([]entities.Address, error) {
+	u, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Addresses == nil {
+		return []entities.Address{}, nil
+	}
+	return u.Addresses, nil
+}
+
+// GetUserAddress returns the address id of the active user. An address of
+// another user is reported as address.ErrNotFound like a missing one.
+func (s *UserService) GetUserAddress(ctx context.Context, userID string, id int) (*entities.Address, error) {
+	if _, err := s.userOps.GetUserByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get user by ID %s: %w", userID, err)
+	}
+	a, err := s.addressOps.GetAddress(ctx, userID, id)
+	if err != nil {
+		return nil, addressError("get", userID, id, err)
+	}
+	if err := s.audit(ctx, audit.ActionView, userID); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// CreateAddress validates a and adds it to the active user a.UserID. A
+// version other than 0 must be the current version of the user. On success a
+// holds the stored address with its new ID.
+func (s *UserService) CreateAddress(ctx context.Context, a *entities.Address, version int64) error {
+	address.Normalize(a)
+	if errs := address.Validate(*a, ""); len(errs) > 0 {
+		return &address.ValidationError{Errors: errs}
+	}
+	current, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), a.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create address of user %s: %w", a.UserID, err)
+	}
+	if err := checkVersion(current, version); err != nil {
+		return err
+	}
+	a.ID = 0
+	return s.changeAddresses(ctx, current, func(ctx context.Context) error {
+		if err := s.addressOps.CreateAddress(ctx, a); err != nil {
+			return fmt.Errorf("failed to create address of user %s: %w", a.UserID, err)
+		}
+		return nil
+	})
+}
+
+// ReplaceAddress validates a and overwrites the address a.ID of the active
+// user a.UserID. A version other than 0 must be the current version of the
+// user.
+func (s *UserService) ReplaceAddress(ctx context.Context, a *entities.Address, version int64) error {
+	address.Normalize(a)
+	if errs := address.Validate(*a, ""); len(errs) > 0 {
+		return &address.ValidationError{Errors: errs}
+	}
+	current, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), a.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to replace address of user %s: %w", a.UserID, err)
+	}
+	if err := checkVersion(current, version); err != nil {
+		return err
+	}
+	return s.changeAddresses(ctx, current, func(ctx context.Context) error {
+		if err := s.addressOps.UpdateAddress(ctx, a); err != nil {
+			return addressError("replace", a.UserID, a.ID, err)
+		}
+		return nil
+	})
+}
+
+// patchableAddress is the document a merge patch of an address applies to.
+type patchableAddress struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	ZipCode string `json:"zip_code"`
+	Country string `json:"country"`
+}
+
+// PatchAddress applies a JSON Merge Patch to the address id of the active
+// user and returns the stored address. null clears a field. A version other
+// than 0 must be the current version of the user.
+func (s *UserService) PatchAddress(ctx context.Context, userID string, id int, version int64, patch []byte) (*entities.Address, error) {
+	if errs := checkPatch(patch, "street", "city", "state", "zip_code", "country"); len(errs) > 0 {
+		return nil, &address.ValidationError{Errors: errs}
+	}
+	u, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch address of user %s: %w", userID, err)
+	}
+	if err := checkVersion(u, version); err != nil {
+		return nil, err
+	}
+	current, err := s.addressOps.GetAddress(storage.WithPrimary(ctx), userID, id)
+	if err != nil {
+		return nil, addressError("patch", userID, id, err)
+	}
+	var patched patchableAddress
+	errs, err := applyPatch(patchableAddress{
+		Street:  current.Street,
+		City:    current.City,
+		State:   current.State,
+		ZipCode: current.ZipCode,
+		Country: current.Country,
+	}, patch, &patched)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &address.ValidationError{Errors: errs}
+	}
+
+	a := &entities.Address{
+		ID:      id,
+		UserID:  userID,
+		Street:  patched.Street,
+		City:    patched.City,
+		State:   patched.State,
+		ZipCode: patched.ZipCode,
+		Country: patched.Country,
+	}
+	address.Normalize(a)
+	if errs := address.Validate(*a, ""); len(errs) > 0 {
+		return nil, &address.ValidationError{Errors: errs}
+	}
+	err = s.changeAddresses(ctx, u, func(ctx context.Context) error {
+		if err := s.addressOps.UpdateAddress(ctx, a); err != nil {
+			return addressError("patch", userID, id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAddress removes the address id of the active user. A version other
+// than 0 must be the current version of the user.
+func (s *UserService) DeleteAddress(ctx context.Context, userID string, id int, version int64) error {
+	current, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete address of user %s: %w", userID, err)
+	}
+	if err := checkVersion(current, version); err != nil {
+		return err
+	}
+	return s.changeAddresses(ctx, current, func(ctx context.Context) error {
+		if err := s.addressOps.DeleteAddress(ctx, userID, id); err != nil {
+			return addressError("delete", userID, id, err)
+		}
+		return nil
+	})
+}
+
+// ReplaceAddresses makes addrs the whole address set of the active user in
+// one transaction. Addresses with an ID must already belong to the user and
+// are updated, the others are created and the user's addresses left out are
+// deleted. A version other than 0 must be the current version of the user.
+// On success addrs hold the stored addresses.
+func (s *UserService) ReplaceAddresses(ctx context.Context, userID string, version int64, addrs []entities.Address) error {
+	verr := &address.ValidationError{}
+	seen := make(map[int]struct{}, len(addrs))
+	for i := range addrs {
+		address.Normalize(&addrs[i])
+		prefix := fmt.Sprintf("addresses[%d].", i)
+		verr.Errors = append(verr.Errors, address.Validate(addrs[i], prefix)...)
+		if id := addrs[i].ID; id != 0 {
+			if _, ok := seen[id]; ok {
+				verr.Errors = append(verr.Errors, user.FieldError{Field: prefix + "address_id", Message: "appears more than once"})
+			}
+			seen[id] = struct{}{}
+		}
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+
+	current, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), userID)
+	if err != nil {
+		return fmt.Errorf("failed to replace addresses of user %s: %w", userID, err)
+	}
+	if err := checkVersion(current, version); err != nil {
+		return err
+	}
+	owned := make(map[int]struct{}, len(current.Addresses))
+	for _, a := range current.Addresses {
+		owned[a.ID] = struct{}{}
+	}
+	for i, a := range addrs {
+		if _, ok := owned[a.ID]; a.ID != 0 && !ok {
+			verr.Errors = append(verr.Errors, user.FieldError{
+				Field:   fmt.Sprintf("addresses[%d].address_id", i),
+				Message: "is not an address of this user",
+			})
+		}
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+
+	return s.changeAddresses(ctx, current, func(ctx context.Context) error {
+		if err := s.addressOps.ReplaceAddresses(ctx, userID, addrs); err != nil {
+			return fmt.Errorf("failed to replace addresses of user %s: %w", userID, err)
+		}
+		return nil
+	})
+}
+
+// changeAddresses runs write and records a version of the user and an
+// update of its addresses in the audit log, all in one transaction. The
+// write is made at the version of current: the repo locks the user and
+// increments its version, so any other version afterwards means the user
+// changed since it was read and the transaction fails with
+// user.ErrVersionMismatch.
+func (s *UserService) changeAddresses(ctx context.Context, current *entities.User, write func(ctx context.Context) error) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		stored, err := s.userOps.GetUserByIDWithDeleted(storage.WithPrimary(ctx), current.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read version of user %s: %w", current.ID, err)
+		}
+		if stored.Version != current.Version+1 {
+			return fmt.Errorf("failed to write addresses of user %s at version %d: %w", current.ID, current.Version, user.ErrVersionMismatch)
+		}
+		if err := s.recordVersion(ctx, current.ID); err != nil {
+			return err
+		}
+		return s.audit(ctx, audit.ActionUpdate, current.ID, "addresses")
+	})
+}
+
+// addressError wraps the error of an action on the address id of the user.
+func addressError(action, userID string, id int, err error) error {
+	return fmt.Errorf("failed to %s address %d of user %s: %w", action, id, userID, err)
+}
+
+// withAddressErrors adds the problems of addrs, as part of a user, to the
+// result of user.Validate.
+func withAddressErrors(err error, addrs []entities.Address) error {
+	var errs []user.FieldError
+	for i := range addrs {
+		address.Normalize(&addrs[i])
+		errs = append(errs, address.Validate(addrs[i], fmt.Sprintf("addresses[%d].", i))...)
+	}
+	if len(errs) == 0 {
+		return err
+	}
+	verr, ok := err.(*user.ValidationError)
+	if !ok {
+		verr = &user.ValidationError{}
+	}
+	verr.Errors = append(verr.Errors, errs...)
+	return verr
+}
@@ -1,19 +1,36 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sika/config"
 	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/history"
+	"sika/internal/outbox"
 	"sika/internal/user"
+	"sika/pkg/events"
 	"sika/pkg/storage"
+	"sika/pkg/storage/memory"
+	"sika/pkg/storage/pgxrepo"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"gorm.io/gorm"
 )
 
 type AppContainer struct {
-	cfg    config.Config
-	dbConn *gorm.DB
-	userService    *UserService
+	cfg              config.Config
+	dbConn           *gorm.DB
+	memStore         *memory.Store
+	pgxPool          *pgxpool.Pool
+	router           *storage.Router
+	stopHealthChecks func()
+	eventBus         *events.InProcessPublisher
+	stopRelay        func()
+	userService      *UserService
 }
 
 func NewAppContainer(cfg config.Config) (*AppContainer, error) {
@@ -27,12 +44,24 @@ func NewAppContainer(cfg config.Config) (*AppContainer, error) {
 	return app, nil
 }
 
+// NewInMemoryAppContainer builds an AppContainer whose repos keep all data in
+// memory, for demos and benchmarks that should not need a database.
+func NewInMemoryAppContainer(cfg config.Config) (*AppContainer, error) {
+	app := &AppContainer{
+		cfg:      cfg,
+		memStore: memory.NewStore(),
+	}
+
+	app.SetUserService()
+	return app, nil
+}
+
 func (a *AppContainer) mustInitDB() {
 	if a.dbConn != nil {
 		return
 	}
 
-	db, err := storage.NewPostgresGormConnection(a.cfg.DB)
+	db, err := storage.NewGormConnection(a.cfg.DB)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -43,15 +72,156 @@ func (a *AppContainer) mustInitDB() {
 	if err != nil {
 		log.Fatal("Migration failed: ", err)
 	}
+
+	// replicas are migrated by replication, never by the application
+	replicas, err := storage.NewReplicaConnections(a.cfg.DB)
+	if err != nil {
+		log.Fatal("Connecting to replicas failed: ", err)
+	}
+	a.router = storage.NewRouter(a.dbConn, replicas...)
+	interval := a.cfg.DB.ReplicaHealthCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	a.stopHealthChecks = a.router.StartHealthChecks(interval)
+
+	switch a.cfg.DB.Repository {
+	case "", config.RepositoryGorm:
+	case config.RepositoryPgx:
+		// migrations still run through GORM, the repos use their own pool
+		if a.cfg.DB.Driver == config.DriverSQLite {
+			log.Fatalf("the %s repository requires the %s driver", config.RepositoryPgx, config.DriverPostgres)
+		}
+		if len(a.cfg.DB.Replicas) > 0 {
+			log.Fatalf("read replicas are not supported by the %s repository", config.RepositoryPgx)
+		}
+		pool, err := pgxrepo.NewPool(context.Background(), a.cfg.DB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		a.pgxPool = pool
+	default:
+		log.Fatalf("unknown repository %q, expected %s or %s", a.cfg.DB.Repository, config.RepositoryGorm, config.RepositoryPgx)
+	}
 }
 
-func(a *AppContainer)SetUserService(){
-	if a.userService != nil{
+func (a *AppContainer) SetUserService() {
+	if a.userService != nil {
+		return
+	}
+	if a.memStore != nil {
+		a.userService = NewUserService(user.NewOps(memory.NewUserRepo(a.memStore)), address.NewOps(memory.NewAddressRepo(a.memStore)))
+		a.userService.SetAuditOps(audit.NewOps(memory.NewAuditRepo(a.memStore)))
+		a.userService.SetHistoryOps(history.NewOps(memory.NewHistoryRepo(a.memStore)))
+		a.userService.SetTransactor(a.memStore)
 		return
 	}
-	a.userService = NewUserService(user.NewOps(storage.NewUserRepo(a.dbConn)), address.NewOps(storage.NewAddressRepo(a.dbConn)))
+	// the audit and history repos must share the transaction of the user
+	// and address repos
+	if a.pgxPool != nil {
+		a.userService = NewUserService(user.NewOps(pgxrepo.NewUserRepo(a.pgxPool)), address.NewOps(pgxrepo.NewAddressRepo(a.pgxPool)))
+		a.userService.SetAuditOps(audit.NewOps(pgxrepo.NewAuditRepo(a.pgxPool)))
+		a.userService.SetHistoryOps(history.NewOps(pgxrepo.NewHistoryRepo(a.pgxPool)))
+		a.userService.SetTransactor(pgxrepo.NewTransactor(a.pgxPool))
+		return
+	}
+	a.userService = NewUserService(user.NewOps(storage.NewUserRepoWithRouter(a.router)), address.NewOps(storage.NewAddressRepoWithRouter(a.router)))
+	a.userService.SetAuditOps(audit.NewOps(storage.NewAuditRepoWithRouter(a.router)))
+	a.userService.SetHistoryOps(history.NewOps(storage.NewHistoryRepoWithRouter(a.router)))
+	a.userService.SetTransactor(storage.NewTransactor(a.router.Primary()))
 }
 
-func(a *AppContainer)UserService()*UserService{
+func (a *AppContainer) UserService() *UserService {
 	return a.userService
 }
+
+// StartEventRelay starts delivering outbox events to the sink selected in
+// the config. Only one process per database may run the relay.
+func (a *AppContainer) StartEventRelay() error {
+	if a.stopRelay != nil {
+		return nil
+	}
+
+	var publisher outbox.EventPublisher
+	closePublisher := func() error { return nil }
+	switch a.cfg.Events.Sink {
+	case "":
+		return nil
+	case config.EventSinkInProcess:
+		a.eventBus = events.NewInProcessPublisher()
+		publisher = a.eventBus
+	case config.EventSinkFile:
+		if a.cfg.Events.FilePath == "" {
+			return fmt.Errorf("events file_path is required for the %s sink", config.EventSinkFile)
+		}
+		filePublisher, err := events.NewFilePublisher(a.cfg.Events.FilePath)
+		if err != nil {
+			return err
+		}
+		publisher = filePublisher
+		closePublisher = filePublisher.Close
+	default:
+		return fmt.Errorf("unknown events sink %q, expected %s or %s", a.cfg.Events.Sink, config.EventSinkInProcess, config.EventSinkFile)
+	}
+
+	var repo outbox.Repo
+	if a.memStore != nil {
+		repo = memory.NewOutboxRepo(a.memStore)
+	} else {
+		repo = storage.NewOutboxRepo(a.dbConn)
+	}
+	interval := a.cfg.Events.RelayInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		outbox.NewRelay(repo, publisher, a.cfg.Events.BatchSize).Run(ctx, interval)
+	}()
+	a.stopRelay = func() {
+		cancel()
+		<-done
+		if err := closePublisher(); err != nil {
+			log.Printf("closing events sink failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close stops the event relay and the replica health checks, then closes
+// the database connections. The app must not be used afterwards.
+func (a *AppContainer) Close() error {
+	if a.stopRelay != nil {
+		a.stopRelay()
+		a.stopRelay = nil
+	}
+	if a.stopHealthChecks != nil {
+		a.stopHealthChecks()
+		a.stopHealthChecks = nil
+	}
+	var errs []error
+	if a.router != nil {
+		// the router closes the replicas only, the primary is ours
+		errs = append(errs, a.router.Close())
+	}
+	if a.pgxPool != nil {
+		a.pgxPool.Close()
+	}
+	if a.dbConn != nil {
+		sqlDB, err := a.dbConn.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// EventBus returns the publisher of the in-process events sink to subscribe
+// to, or nil when another sink is configured.
+func (a *AppContainer) EventBus() *events.InProcessPublisher {
+	return a.eventBus
+}
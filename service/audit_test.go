@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/user"
+	"sika/pkg/load"
+	"sika/pkg/storage/entities"
+	"sika/test/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_Audit(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+	mockAuditRepo := mocks.NewMockAuditRepo(ctrl)
+
+	service := NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+	service.SetAuditOps(audit.NewOps(mockAuditRepo))
+
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), "alice"), "req-1")
+
+	tests := []struct {
+		name       string
+		execute    func() error
+		setupMocks func()
+		wantEntry  *entities.AuditEntry
+		wantErr    bool
+	}{
+		{
+			name: "view is recorded",
+			execute: func() error {
+				_, err := service.GetUserByID(ctx, "123")
+				return err
+			},
+			setupMocks: func() {
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(&entities.User{ID: "123"}, nil)
+			},
+			wantEntry: &entities.AuditEntry{Actor: "alice", Action: audit.ActionView, UserID: "123", RequestID: "req-1"},
+		},
+		{
+			name: "delete is recorded",
+			execute: func() error {
+				return service.DeleteUser(ctx, "123", 0)
+			},
+			setupMocks: func() {
+				mockUserRepo.EXPECT().DeleteUser(gomock.Any(), "123", int64(0)).Return(nil)
+			},
+			wantEntry: &entities.AuditEntry{Actor: "alice", Action: audit.ActionDelete, UserID: "123", RequestID: "req-1"},
+		},
+		{
+			name: "failed delete is not recorded",
+			execute: func() error {
+				return service.DeleteUser(ctx, "123", 0)
+			},
+			setupMocks: func() {
+				mockUserRepo.EXPECT().DeleteUser(gomock.Any(), "123", int64(0)).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "failing audit log fails the view",
+			execute: func() error {
+				_, err := service.GetUserByID(ctx, "123")
+				return err
+			},
+			setupMocks: func() {
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "123").Return(&entities.User{ID: "123"}, nil)
+				mockAuditRepo.EXPECT().AppendEntry(gomock.Any(), gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mocks
+			tt.setupMocks()
+			if tt.wantEntry != nil {
+				mockAuditRepo.EXPECT().AppendEntry(gomock.Any(), tt.wantEntry).Return(nil)
+			}
+
+			// Execute
+			err := tt.execute()
+
+			// Assert
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_BatchReadRecordsAuditOnce(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockAuditRepo := mocks.NewMockAuditRepo(ctrl)
+
+	service := NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+	service.SetAuditOps(audit.NewOps(mockAuditRepo))
+
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), "alice"), "req-1")
+
+	tests := []struct {
+		name     string
+		auditErr error
+		wantErr  bool
+	}{
+		{name: "views are recorded in one write"},
+		{name: "failing audit log fails the read", auditErr: assert.AnError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mocks
+			mockUserRepo.EXPECT().GetUsersByIDs(gomock.Any(), []string{"1", "2", "3"}).
+				Return([]entities.User{{ID: "1"}, {ID: "3"}}, nil)
+			mockAuditRepo.EXPECT().AppendEntries(gomock.Any(), []entities.AuditEntry{
+				{Actor: "alice", Action: audit.ActionView, UserID: "1", RequestID: "req-1"},
+				{Actor: "alice", Action: audit.ActionView, UserID: "3", RequestID: "req-1"},
+			}).Return(tt.auditErr)
+
+			// Execute
+			users, missing, err := service.GetUsersByIDs(ctx, []string{"1", "2", "3"})
+
+			// Assert
+			if tt.wantErr {
+				assert.ErrorIs(t, err, assert.AnError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, users, 2)
+			assert.Equal(t, []string{"2"}, missing)
+		})
+	}
+}
+
+func TestUserService_ImportUsersRecordsAudit(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+	mockAuditRepo := mocks.NewMockAuditRepo(ctrl)
+
+	service := NewUserService(user.NewOps(mockUserRepo), address.NewOps(mockAddressRepo))
+	service.SetAuditOps(audit.NewOps(mockAuditRepo))
+
+	mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
+	mockAuditRepo.EXPECT().AppendEntry(gomock.Any(), &entities.AuditEntry{
+		Actor:         audit.SystemActor,
+		Action:        audit.ActionCreate,
+		UserID:        "1",
+		ChangedFields: []string{"name", "email"},
+	}).Return(nil)
+
+	// Execute
+	err := service.ImportUsers(context.Background(), []load.User{
+		{ID: "1", Name: "Test User", Email: "test@example.com"},
+	})
+
+	// Assert
+	require.NoError(t, err)
+}
+
+// recordingTx marks the context of its transactions and records what they
+// returned.
+type recordingTx struct {
+	errs []error
+}
+
+type recordingTxKey struct{}
+
+func (r *recordingTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := fn(context.WithValue(ctx, recordingTxKey{}, true))
+	r.errs = append(r.errs, err)
+	return err
+}
+
+func TestUserService_AuditInTransaction(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockAuditRepo := mocks.NewMockAuditRepo(ctrl)
+
+	tx := &recordingTx{}
+	service := NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+	service.SetAuditOps(audit.NewOps(mockAuditRepo))
+	service.SetTransactor(tx)
+
+	inTx := func(ctx context.Context) bool { return ctx.Value(recordingTxKey{}) != nil }
+	mockUserRepo.EXPECT().DeleteUser(gomock.Any(), "123", int64(0)).DoAndReturn(
+		func(ctx context.Context, id string, version int64) error {
+			assert.True(t, inTx(ctx), "the delete runs in the transaction")
+			return nil
+		})
+	mockAuditRepo.EXPECT().AppendEntry(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, entry *entities.AuditEntry) error {
+			assert.True(t, inTx(ctx), "the audit entry is written in the transaction")
+			return assert.AnError
+		})
+
+	// Execute
+	err := service.DeleteUser(context.Background(), "123", 0)
+
+	// Assert
+	assert.ErrorIs(t, err, assert.AnError)
+	require.Len(t, tx.errs, 1)
+	assert.ErrorIs(t, tx.errs[0], assert.AnError, "the failing audit entry rolls back the delete")
+}
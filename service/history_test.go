@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sika/internal/address"
+	"sika/internal/history"
+	"sika/internal/outbox"
+	"sika/internal/user"
+	"sika/pkg/load"
+	"sika/pkg/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_History(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	service := NewUserService(user.NewOps(memory.NewUserRepo(store)), address.NewOps(memory.NewAddressRepo(store)))
+	service.SetHistoryOps(history.NewOps(memory.NewHistoryRepo(store)))
+	ctx := context.Background()
+
+	testUser := load.User{
+		ID:        "1",
+		Name:      "Test User",
+		Email:     "old@example.com",
+		Addresses: []load.Address{{Street: "1 Old St"}},
+	}
+
+	// Execute: import, re-import unchanged, import a changed email, delete
+	require.NoError(t, service.ImportUsers(ctx, []load.User{testUser}))
+	beforeChange := time.Now()
+	time.Sleep(time.Millisecond)
+	testUser.Addresses = nil
+	require.NoError(t, service.ImportUsers(ctx, []load.User{testUser}))
+	testUser.Email = "new@example.com"
+	require.NoError(t, service.ImportUsers(ctx, []load.User{testUser}))
+	require.NoError(t, service.DeleteUser(ctx, "1", 0))
+
+	// Assert
+	versions, err := service.GetUserHistory(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "old@example.com", versions[0].Email)
+	assert.Len(t, versions[0].Addresses, 1)
+	assert.Equal(t, "new@example.com", versions[1].Email)
+	assert.Nil(t, versions[1].DeletedAt)
+	assert.NotNil(t, versions[2].DeletedAt)
+	assert.Nil(t, versions[2].ValidTo)
+
+	old, err := service.GetUserAsOf(ctx, "1", beforeChange, false)
+	require.NoError(t, err)
+	assert.Equal(t, "old@example.com", old.Email)
+	require.Len(t, old.Addresses, 1)
+	assert.Equal(t, "1 Old St", old.Addresses[0].Street)
+
+	_, err = service.GetUserAsOf(ctx, "1", time.Now(), false)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	deleted, err := service.GetUserAsOf(ctx, "1", time.Now(), true)
+	require.NoError(t, err)
+	assert.True(t, deleted.DeletedAt.Valid)
+
+	_, err = service.GetUserAsOf(ctx, "1", beforeChange.Add(-time.Hour), false)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	// Purging erases the history
+	require.NoError(t, service.PurgeUser(ctx, "1"))
+	_, err = service.GetUserHistory(ctx, "1")
+	assert.ErrorIs(t, err, user.ErrNotFound)
+}
+
+func TestUserService_ClearUserAndAddressDataFromDB(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	service := NewUserService(user.NewOps(memory.NewUserRepo(store)), address.NewOps(memory.NewAddressRepo(store)))
+	service.SetHistoryOps(history.NewOps(memory.NewHistoryRepo(store)))
+	ctx := context.Background()
+	require.NoError(t, service.ImportUsers(ctx, []load.User{
+		{ID: "1", Name: "First", Email: "first@example.com", Addresses: []load.Address{{Street: "1 Test St"}}},
+		{ID: "2", Name: "Second", Email: "second@example.com"},
+	}))
+
+	// Execute: clear, then import the first user again
+	require.NoError(t, service.ClearUserAndAddressDataFromDB(ctx))
+	require.NoError(t, service.ImportUsers(ctx, []load.User{{ID: "1", Name: "First", Email: "first@example.com"}}))
+
+	// Assert
+	events, err := memory.NewOutboxRepo(store).GetPendingEvents(ctx, 100, nil)
+	require.NoError(t, err)
+	var purged []string
+	for _, e := range events {
+		if e.EventType == outbox.EventUserPurged {
+			purged = append(purged, e.UserID)
+		}
+	}
+	assert.Equal(t, []string{"1", "2"}, purged)
+
+	versions, err := service.GetUserHistory(ctx, "2")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.NotNil(t, versions[0].ValidTo, "the version of a cleared user is closed")
+
+	versions, err = service.GetUserHistory(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, versions, 2, "the re-imported user starts a new version")
+	assert.NotNil(t, versions[0].ValidTo)
+	assert.Nil(t, versions[1].ValidTo)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sika/internal/user"
+	"sika/pkg/load"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+	"sort"
+	"strings"
+)
+
+// FieldMismatch is a single user field whose stored value differs from the
+// value in the source file.
+type FieldMismatch struct {
+	UserID   string `json:"user_id"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// AddressCountDiff records a user whose number of stored addresses differs
+// from the number in the source file.
+type AddressCountDiff struct {
+	UserID   string `json:"user_id"`
+	Expected int    `json:"expected"`
+	Actual   int    `json:"actual"`
+}
+
+type ReconcileReport struct {
+	Checked           int                `json:"checked"`
+	Missing           []string           `json:"missing"`
+	Extra             []string           `json:"extra"`
+	Mismatches        []FieldMismatch    `json:"mismatches"`
+	AddressCountDiffs []AddressCountDiff `json:"address_count_diffs"`
+}
+
+// Drifted returns the number of distinct users that are missing, extra or
+// differ in any field or address.
+func (r *ReconcileReport) Drifted() int {
+	drifted := make(map[string]struct{})
+	for _, id := range r.Missing {
+		drifted[id] = struct{}{}
+	}
+	for _, id := range r.Extra {
+		drifted[id] = struct{}{}
+	}
+	for _, m := range r.Mismatches {
+		drifted[m.UserID] = struct{}{}
+	}
+	for _, d := range r.AddressCountDiffs {
+		drifted[d.UserID] = struct{}{}
+	}
+	return len(drifted)
+}
+
+// DriftRatio is Drifted relative to the number of users seen on either side.
+func (r *ReconcileReport) DriftRatio() float64 {
+	total := r.Checked + len(r.Extra)
+	if total == 0 {
+		return 0
+	}
+	return float64(r.Drifted()) / float64(total)
+}
+
+// Reconcile streams the users in filePath and compares each one, including
+// its addresses, with what is stored in the database. It reads from the
+// primary, replication lag would otherwise show up as drift.
+func (s *UserService) Reconcile(ctx context.Context, filePath string) (*ReconcileReport, error) {
+	ctx = storage.WithPrimary(ctx)
+	report := &ReconcileReport{}
+	seen := make(map[string]struct{})
+
+	err := load.StreamData(filePath, func(u load.User) error {
+		report.Checked++
+		seen[u.ID] = struct{}{}
+
+		stored, err := s.userOps.GetUserByID(ctx, u.ID)
+		if errors.Is(err, user.ErrNotFound) {
+			report.Missing = append(report.Missing, u.ID)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get user by ID %s: %w", u.ID, err)
+		}
+
+		report.Mismatches = append(report.Mismatches, compareUser(u, stored)...)
+
+		if len(u.Addresses) != len(stored.Addresses) {
+			report.AddressCountDiffs = append(report.AddressCountDiffs, AddressCountDiff{
+				UserID:   u.ID,
+				Expected: len(u.Addresses),
+				Actual:   len(stored.Addresses),
+			})
+		} else if !sameAddresses(u.Addresses, stored.Addresses) {
+			report.Mismatches = append(report.Mismatches, FieldMismatch{
+				UserID:   u.ID,
+				Field:    "addresses",
+				Expected: formatAddresses(u.Addresses),
+				Actual:   formatAddresses(loadAddresses(stored.Addresses)),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.userOps.GetAllUserIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user IDs: %w", err)
+	}
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			report.Extra = append(report.Extra, id)
+		}
+	}
+	sort.Strings(report.Extra)
+
+	return report, nil
+}
+
+func compareUser(u load.User, stored *entities.User) []FieldMismatch {
+	var mismatches []FieldMismatch
+	check := func(field, expected, actual string) {
+		if expected != actual {
+			mismatches = append(mismatches, FieldMismatch{
+				UserID:   u.ID,
+				Field:    field,
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+	check("name", u.Name, stored.Name)
+	check("email", u.Email, stored.Email)
+	check("phone_number", u.PhoneNumber, stored.PhoneNumber)
+	return mismatches
+}
+
+// sameAddresses compares two address sets of equal length ignoring order,
+// since the database does not preserve the order of the source file.
+func sameAddresses(expected []load.Address, actual []entities.Address) bool {
+	counts := make(map[load.Address]int, len(expected))
+	for _, a := range expected {
+		counts[a]++
+	}
+	for _, key := range loadAddresses(actual) {
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+// loadAddresses returns the stored addresses in the form of the source file.
+func loadAddresses(addrs []entities.Address) []load.Address {
+	out := make([]load.Address, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, load.Address{
+			Street:  a.Street,
+			City:    a.City,
+			State:   a.State,
+			ZipCode: a.ZipCode,
+			Country: a.Country,
+		})
+	}
+	return out
+}
+
+// formatAddresses renders an address set for a mismatch, one address per
+// entry separated by "; ", sorted so that both sides line up regardless of
+// the order they were read in.
+func formatAddresses(addrs []load.Address) string {
+	lines := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		lines = append(lines, fmt.Sprintf("%s, %s, %s %s, %s", a.Street, a.City, a.State, a.ZipCode, a.Country))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "; ")
+}
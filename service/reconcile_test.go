@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/test/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_Reconcile(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+
+	userOps := user.NewOps(mockUserRepo)
+	addressOps := address.NewOps(mockAddressRepo)
+
+	service := NewUserService(userOps, addressOps)
+
+	// Source file with three users
+	filePath := filepath.Join(t.TempDir(), "users.json")
+	err := os.WriteFile(filePath, []byte(`[
+		{"id": "1", "name": "Same User", "email": "same@example.com", "phone_number": "1",
+		 "addresses": [{"street": "1 St", "city": "A", "state": "S", "zip_code": "1", "country": "C"}]},
+		{"id": "2", "name": "Changed User", "email": "new@example.com", "phone_number": "2",
+		 "addresses": [{"street": "2 St", "city": "B", "state": "S", "zip_code": "2", "country": "C"}]},
+		{"id": "3", "name": "Missing User", "email": "missing@example.com", "phone_number": "3"},
+		{"id": "5", "name": "Moved User", "email": "moved@example.com", "phone_number": "5",
+		 "addresses": [{"street": "5 St", "city": "E", "state": "S", "zip_code": "5", "country": "C"},
+		               {"street": "6 St", "city": "F", "state": "S", "zip_code": "6", "country": "C"}]}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	// Setup mocks
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "1").Return(&entities.User{
+		ID:          "1",
+		Name:        "Same User",
+		Email:       "same@example.com",
+		PhoneNumber: "1",
+		Addresses: []entities.Address{
+			{ID: 1, UserID: "1", Street: "1 St", City: "A", State: "S", ZipCode: "1", Country: "C"},
+		},
+	}, nil)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "2").Return(&entities.User{
+		ID:          "2",
+		Name:        "Changed User",
+		Email:       "old@example.com",
+		PhoneNumber: "2",
+	}, nil)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "3").Return(nil, user.ErrNotFound)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "5").Return(&entities.User{
+		ID:          "5",
+		Name:        "Moved User",
+		Email:       "moved@example.com",
+		PhoneNumber: "5",
+		Addresses: []entities.Address{
+			{ID: 3, UserID: "5", Street: "6 St", City: "F", State: "S", ZipCode: "6", Country: "C"},
+			{ID: 2, UserID: "5", Street: "7 St", City: "G", State: "S", ZipCode: "7", Country: "C"},
+		},
+	}, nil)
+	mockUserRepo.EXPECT().GetAllUserIDs(gomock.Any()).Return([]string{"1", "2", "4", "5"}, nil)
+
+	// Execute
+	report, err := service.Reconcile(context.Background(), filePath)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, []string{"3"}, report.Missing)
+	assert.Equal(t, []string{"4"}, report.Extra)
+	assert.Equal(t, []FieldMismatch{
+		{UserID: "2", Field: "email", Expected: "new@example.com", Actual: "old@example.com"},
+		{UserID: "5", Field: "addresses", Expected: "5 St, E, S 5, C; 6 St, F, S 6, C", Actual: "6 St, F, S 6, C; 7 St, G, S 7, C"},
+	}, report.Mismatches)
+	assert.Equal(t, []AddressCountDiff{
+		{UserID: "2", Expected: 1, Actual: 0},
+	}, report.AddressCountDiffs)
+	assert.Equal(t, 4, report.Drifted())
+	assert.InDelta(t, 0.8, report.DriftRatio(), 0.0001)
+}
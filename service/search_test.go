@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/pkg/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_SearchUsers(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepo(store)
+	service := NewUserService(user.NewOps(userRepo), address.NewOps(memory.NewAddressRepo(store)))
+	service.SetAuditOps(audit.NewOps(memory.NewAuditRepo(store)))
+	ctx := context.Background()
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, userRepo.CreateUser(ctx, &entities.User{ID: id, Name: "Anna Smith"}))
+	}
+
+	// Execute
+	firstPage, more, err := service.SearchUsers(ctx, "anna", 2, 0)
+	require.NoError(t, err)
+	lastPage, lastMore, err := service.SearchUsers(ctx, "anna", 2, 2)
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 2)
+	assert.True(t, more)
+	require.Len(t, lastPage, 1)
+	assert.False(t, lastMore)
+	assert.Equal(t, "3", lastPage[0].ID)
+
+	for _, id := range []string{"1", "2", "3"} {
+		entries, err := service.GetUserAudit(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "user %s", id)
+		assert.Equal(t, audit.ActionView, entries[0].Action)
+	}
+}
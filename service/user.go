@@ -2,26 +2,179 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/history"
 	"sika/internal/user"
 	"sika/pkg/load"
+	"sika/pkg/mergepatch"
+	"sika/pkg/storage"
 	"sika/pkg/storage/entities"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UserService struct {
 	userOps    *user.Ops
 	addressOps *address.Ops
+	auditOps   *audit.Ops
+	historyOps *history.Ops
+	tx         Transactor
 }
 
 func NewUserService(userOps *user.Ops, addressOps *address.Ops) *UserService {
 	return &UserService{
 		userOps:    userOps,
 		addressOps: addressOps,
+		tx:         noTx{},
 	}
 }
 
+// Transactor runs fn in one transaction. The repos built on the same
+// database take it from the context passed to fn.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// noTx runs fn without a transaction.
+type noTx struct{}
+
+func (noTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// SetTransactor makes a change, the version it records and its audit entry
+// commit together. Without it they are written one after the other.
+func (s *UserService) SetTransactor(tx Transactor) {
+	s.tx = tx
+}
+
+// SetAuditOps enables the audit log. Without it actions are not recorded.
+func (s *UserService) SetAuditOps(auditOps *audit.Ops) {
+	s.auditOps = auditOps
+}
+
+// SetHistoryOps enables the change history. Without it versions are not
+// recorded.
+func (s *UserService) SetHistoryOps(historyOps *history.Ops) {
+	s.historyOps = historyOps
+}
+
+// recordVersion stores the user as it is now in the database as its next
+// version.
+func (s *UserService) recordVersion(ctx context.Context, id string) error {
+	if s.historyOps == nil {
+		return nil
+	}
+	u, err := s.userOps.GetUserByIDWithDeleted(storage.WithPrimary(ctx), id)
+	if err != nil {
+		return fmt.Errorf("failed to read user %s for its history: %w", id, err)
+	}
+	if err := s.historyOps.RecordVersion(ctx, u, time.Now()); err != nil {
+		return fmt.Errorf("failed to record history of user %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetUserHistory returns every recorded version of the user, oldest first.
+func (s *UserService) GetUserHistory(ctx context.Context, id string) ([]entities.UserVersion, error) {
+	if s.historyOps == nil {
+		return nil, fmt.Errorf("failed to get history of user %s: %w", id, user.ErrNotFound)
+	}
+	versions, err := s.historyOps.GetVersions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of user %s: %w", id, err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("failed to get history of user %s: %w", id, user.ErrNotFound)
+	}
+	if err := s.audit(ctx, audit.ActionView, id); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetUserAsOf returns the user as it was held at the given time. A user that
+// was soft-deleted at that time is only returned with includeDeleted.
+func (s *UserService) GetUserAsOf(ctx context.Context, id string, at time.Time, includeDeleted bool) (*entities.User, error) {
+	if s.historyOps == nil {
+		return nil, fmt.Errorf("failed to get user by ID %s as of %s: %w", id, at.Format(time.RFC3339), user.ErrNotFound)
+	}
+	v, err := s.historyOps.GetVersionAt(ctx, id, at)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && v.DeletedAt != nil && !includeDeleted) {
+		err = user.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by ID %s as of %s: %w", id, at.Format(time.RFC3339), err)
+	}
+	if err := s.audit(ctx, audit.ActionView, id); err != nil {
+		return nil, err
+	}
+
+	u := &entities.User{
+		ID:          v.UserID,
+		Name:        v.Name,
+		Email:       v.Email,
+		PhoneNumber: v.PhoneNumber,
+		Addresses:   v.Addresses,
+		UpdatedAt:   v.ValidFrom,
+	}
+	if v.DeletedAt != nil {
+		u.DeletedAt = gorm.DeletedAt{Time: *v.DeletedAt, Valid: true}
+	}
+	return u, nil
+}
+
+// audit records action on userID. A failure fails the operation, an action
+// on personal data must not go unrecorded. Entries of changes are recorded
+// in the transaction of the change.
+func (s *UserService) audit(ctx context.Context, action, userID string, changedFields ...string) error {
+	if s.auditOps == nil {
+		return nil
+	}
+	if err := s.auditOps.Record(ctx, action, userID, changedFields...); err != nil {
+		return fmt.Errorf("failed to record %s audit entry for user %s: %w", action, userID, err)
+	}
+	return nil
+}
+
+// auditViews records the users ids as viewed in one write, for the reads
+// returning many users. Like audit, a failure fails the read.
+func (s *UserService) auditViews(ctx context.Context, ids []string) error {
+	if s.auditOps == nil {
+		return nil
+	}
+	if err := s.auditOps.RecordAll(ctx, audit.ActionView, ids); err != nil {
+		return fmt.Errorf("failed to record view audit entries for %d users: %w", len(ids), err)
+	}
+	return nil
+}
+
+// GetUserAudit returns the audit log of the user, oldest first. Entries of
+// purged users are kept.
+func (s *UserService) GetUserAudit(ctx context.Context, id string) ([]entities.AuditEntry, error) {
+	if s.auditOps == nil {
+		return []entities.AuditEntry{}, nil
+	}
+	entries, err := s.auditOps.GetEntriesByUser(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log of user %s: %w", id, err)
+	}
+	if entries == nil {
+		entries = []entities.AuditEntry{}
+	}
+	return entries, nil
+}
+
 type Job struct {
 	user      *entities.User
 	addresses []*entities.Address
@@ -43,33 +196,42 @@ func NewWorkerPool(n int) *WorkerPool {
 	}
 }
 
-func (s *UserService) ImportUsers(usersData []load.User) error {
+// ImportUsers imports each user with its addresses in a transaction of its
+// own. Once ctx is canceled no further user is started and the users in
+// flight are rolled back, so the users imported so far stay consistent.
+func (s *UserService) ImportUsers(ctx context.Context, usersData []load.User) error {
 	wp := NewWorkerPool(10)
-	ctx := context.Background()
 	for i := 0; i < wp.numOfWorkers; i++ {
 		wp.wg.Add(1)
 		go func() {
 			defer wp.wg.Done()
 			for j := range wp.jobs {
-				err := s.userOps.CreateUser(ctx, j.user)
-				if err != nil {
-					wp.results <- fmt.Errorf("user with Id %s insertion to db failed %w", j.user.ID, err)
+				if ctx.Err() != nil {
+					wp.results <- nil // skipped, counted like the others
 					continue
 				}
-
-				if len(j.addresses) > 0 {
-					addresses := make([]entities.Address, len(j.addresses))
-					for i, a := range j.addresses {
-						addresses[i] = *a
+				wp.results <- s.tx.InTx(ctx, func(ctx context.Context) error {
+					err := s.userOps.CreateUser(ctx, j.user)
+					if err != nil {
+						return fmt.Errorf("user with Id %s insertion to db failed %w", j.user.ID, err)
 					}
 
-					err := s.addressOps.CreateBatchAddress(ctx, addresses)
-					if err != nil {
-						wp.results <- fmt.Errorf("batch address insertion for userID %s failed %w", j.user.ID, err)
-						continue
+					if len(j.addresses) > 0 {
+						addresses := make([]entities.Address, len(j.addresses))
+						for i, a := range j.addresses {
+							addresses[i] = *a
+						}
+
+						err := s.addressOps.CreateBatchAddress(ctx, addresses)
+						if err != nil {
+							return fmt.Errorf("batch address insertion for userID %s failed %w", j.user.ID, err)
+						}
 					}
-				}
-				wp.results <- nil
+					if err := s.recordVersion(ctx, j.user.ID); err != nil {
+						return err
+					}
+					return s.audit(ctx, audit.ActionCreate, j.user.ID, writtenFields(j.user, len(j.addresses))...)
+				})
 			}
 		}()
 	}
@@ -113,10 +275,13 @@ func (s *UserService) ImportUsers(usersData []load.User) error {
 			}
 		}
 	}
+	wp.wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("import canceled: %w", err)
+	}
 	if len(errs) > 0 {
 		return fmt.Errorf("encountered %d errors during import %v", len(errs), errs)
 	}
-	wp.wg.Wait()
 	return nil
 }
 
@@ -125,17 +290,426 @@ func (s *UserService) GetUserByID(ctx context.Context, id string) (*entities.Use
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by ID %s: %w", id, err)
 	}
+	if err := s.audit(ctx, audit.ActionView, id); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUserByIDWithDeleted is GetUserByID that also returns soft-deleted users.
+func (s *UserService) GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error) {
+	user, err := s.userOps.GetUserByIDWithDeleted(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by ID %s: %w", id, err)
+	}
+	if err := s.audit(ctx, audit.ActionView, id); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-func (s *UserService) ClearUserAndAddressDataFromDB() error {
-	err := s.addressOps.ClearAllAddressesDataFromDB()
+// GetUserProjection is GetUserByID reading only what p selects, and also
+// finding soft-deleted users when includeDeleted is set.
+func (s *UserService) GetUserProjection(ctx context.Context, id string, p user.Projection, includeDeleted bool) (*entities.User, error) {
+	u, err := s.userOps.GetUserProjection(ctx, id, p, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by ID %s: %w", id, err)
+	}
+	if err := s.audit(ctx, audit.ActionView, id); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// GetUsersByIDs returns the active users among ids in the order of ids and
+// the IDs that were not found. A repeated ID is returned once. The found
+// users are recorded as viewed in one write.
+func (s *UserService) GetUsersByIDs(ctx context.Context, ids []string) (users []entities.User, missing []string, err error) {
+	found, err := s.userOps.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %d users by ID: %w", len(ids), err)
+	}
+	byID := make(map[string]entities.User, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+
+	users = make([]entities.User, 0, len(found))
+	missing = []string{}
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		u, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		users = append(users, u)
+	}
+	if err := s.auditViews(ctx, userIDs(users)); err != nil {
+		return nil, nil, err
+	}
+	return users, missing, nil
+}
+
+// ListUsers returns a page of active users in the order of q and the cursor
+// of the next page, nil on the last page. The returned users are recorded as
+// viewed in one write.
+func (s *UserService) ListUsers(ctx context.Context, q user.ListQuery) ([]entities.User, *user.Cursor, error) {
+	if err := q.Validate(); err != nil {
+		return nil, nil, err
+	}
+	limit := q.Limit
+	// one extra user tells whether there is a next page
+	q.Limit++
+	users, err := s.userOps.ListUsers(ctx, q)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var next *user.Cursor
+	if len(users) > limit {
+		users = users[:limit]
+		c := user.CursorAfter(users[limit-1], q.SortBy, q.Descending)
+		next = &c
+	}
+	if err := s.auditViews(ctx, userIDs(users)); err != nil {
+		return nil, nil, err
+	}
+	return users, next, nil
+}
+
+// SearchUsers returns the page of active users matching query at offset, most
+// relevant first. more reports whether further matches follow the page. The
+// returned users are recorded as viewed in one write.
+func (s *UserService) SearchUsers(ctx context.Context, query string, limit, offset int) (matches []entities.UserMatch, more bool, err error) {
+	// one extra match tells whether there is a next page
+	matches, err = s.userOps.SearchUsers(ctx, query, limit+1, offset)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to search users for %q: %w", query, err)
+	}
+	if len(matches) > limit {
+		matches, more = matches[:limit], true
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	if err := s.auditViews(ctx, ids); err != nil {
+		return nil, false, err
+	}
+	return matches, more, nil
+}
+
+// userIDs returns the IDs of users in order.
+func userIDs(users []entities.User) []string {
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	return ids
+}
+
+// CreateUser validates u and inserts it with its addresses, assigning an ID
+// when it has none. It fails with user.ErrIDTaken when a user with the
+// ID exists, even soft-deleted, and with user.ErrEmailTaken when an active
+// user has the email.
+func (s *UserService) CreateUser(ctx context.Context, u *entities.User) error {
+	user.Normalize(u)
+	if u.ID == "" {
+		u.ID = uuid.NewString()
+	}
+	if err := withAddressErrors(user.Validate(*u), u.Addresses); err != nil {
+		return err
+	}
+	if err := s.checkEmailAvailable(ctx, u.Email, u.ID); err != nil {
+		return err
+	}
+
+	for i := range u.Addresses {
+		u.Addresses[i].UserID = u.ID
+	}
+	users := []entities.User{*u}
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userOps.CreateBatchUser(ctx, users); err != nil {
+			return fmt.Errorf("failed to create user %s: %w", u.ID, err)
+		}
+		*u = users[0]
+
+		if err := s.recordVersion(ctx, u.ID); err != nil {
+			return err
+		}
+		return s.audit(ctx, audit.ActionCreate, u.ID, writtenFields(u, len(u.Addresses))...)
+	})
+}
+
+// ReplaceUser validates u and replaces the name, email and phone number of
+// the active user u.ID. Addresses are not changed. A u.Version other than 0
+// must be the current version of the user, else user.ErrVersionMismatch is
+// returned. On success u holds the stored user with its addresses and new
+// version.
+func (s *UserService) ReplaceUser(ctx context.Context, u *entities.User) error {
+	user.Normalize(u)
+	if len(u.Addresses) > 0 {
+		return &user.ValidationError{Errors: []user.FieldError{
+			{Field: "addresses", Message: "cannot be replaced with the user"},
+		}}
+	}
+	current, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), u.ID)
 	if err != nil {
-		return fmt.Errorf("failed to clear addresses table: %w", err)
+		return fmt.Errorf("failed to replace user %s: %w", u.ID, err)
+	}
+	if err := checkVersion(current, u.Version); err != nil {
+		return err
+	}
+	return s.updateUser(ctx, current, u)
+}
+
+// patchableUser is the document a merge patch of a user applies to.
+type patchableUser struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+// PatchUser applies a JSON Merge Patch to the name, email and phone number
+// of the active user and returns the stored user. null clears a field. A
+// version other than 0 must be the current version of the user.
+func (s *UserService) PatchUser(ctx context.Context, id string, version int64, patch []byte) (*entities.User, error) {
+	if errs := checkPatch(patch, "name", "email", "phone_number"); len(errs) > 0 {
+		return nil, &user.ValidationError{Errors: errs}
 	}
-	err = s.userOps.ClearAllUsersDataFromDB()
+	current, err := s.userOps.GetUserByID(storage.WithPrimary(ctx), id)
 	if err != nil {
-		return fmt.Errorf("failed to clear users table: %w", err)
+		return nil, fmt.Errorf("failed to patch user %s: %w", id, err)
+	}
+	if err := checkVersion(current, version); err != nil {
+		return nil, err
+	}
+	var patched patchableUser
+	errs, err := applyPatch(patchableUser{Name: current.Name, Email: current.Email, PhoneNumber: current.PhoneNumber}, patch, &patched)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &user.ValidationError{Errors: errs}
+	}
+
+	u := &entities.User{ID: id, Name: patched.Name, Email: patched.Email, PhoneNumber: patched.PhoneNumber}
+	user.Normalize(u)
+	if err := s.updateUser(ctx, current, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// checkPatch returns the problems of patch unless it is a JSON object of the
+// given fields only.
+func checkPatch(patch []byte, fields ...string) []user.FieldError {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return []user.FieldError{{Field: "body", Message: "must be a JSON object"}}
+	}
+	var errs []user.FieldError
+	for name := range members {
+		if !slices.Contains(fields, name) {
+			errs = append(errs, user.FieldError{Field: name, Message: "cannot be patched"})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// applyPatch merges patch into the JSON of current and decodes the result
+// into patched. The patchable fields are all strings, a value of another type
+// is returned as a problem.
+func applyPatch(current any, patch []byte, patched any) ([]user.FieldError, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(merged, patched); err != nil {
+		field := "body"
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			field = typeErr.Field
+		}
+		return []user.FieldError{{Field: field, Message: "must be a string or null"}}, nil
+	}
+	return nil, nil
+}
+
+// checkVersion fails with user.ErrVersionMismatch unless version is 0 or the
+// version of current.
+func checkVersion(current *entities.User, version int64) error {
+	if version != 0 && version != current.Version {
+		return fmt.Errorf("failed to write user %s at version %d: %w", current.ID, version, user.ErrVersionMismatch)
 	}
 	return nil
 }
+
+// updateUser validates u and writes it over current, recording a version and
+// the changed fields. The write is made at the version of current, so it
+// fails with user.ErrVersionMismatch when the user changed since it was read.
+func (s *UserService) updateUser(ctx context.Context, current, u *entities.User) error {
+	if err := user.Validate(*u); err != nil {
+		return err
+	}
+	if !strings.EqualFold(u.Email, current.Email) {
+		if err := s.checkEmailAvailable(ctx, u.Email, u.ID); err != nil {
+			return err
+		}
+	}
+	u.Version = current.Version
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userOps.UpdateUser(ctx, u); err != nil {
+			return fmt.Errorf("failed to update user %s: %w", u.ID, err)
+		}
+		u.Addresses = current.Addresses
+
+		if err := s.recordVersion(ctx, u.ID); err != nil {
+			return err
+		}
+		return s.audit(ctx, audit.ActionUpdate, u.ID, changedFields(current, u)...)
+	})
+}
+
+// checkEmailAvailable fails with user.ErrEmailTaken when an active user other
+// than id has the email. Two concurrent writes of the same email can both
+// pass, the repo then fails the second with user.ErrEmailTaken.
+func (s *UserService) checkEmailAvailable(ctx context.Context, email, id string) error {
+	other, err := s.userOps.GetUserByEmail(storage.WithPrimary(ctx), email)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check email of user %s: %w", id, err)
+	}
+	if other.ID != id {
+		return fmt.Errorf("failed to write user %s: %w", id, user.ErrEmailTaken)
+	}
+	return nil
+}
+
+// changedFields lists the fields that differ between before and after, for
+// the audit log.
+func changedFields(before, after *entities.User) []string {
+	var fields []string
+	if before.Name != after.Name {
+		fields = append(fields, "name")
+	}
+	if before.Email != after.Email {
+		fields = append(fields, "email")
+	}
+	if before.PhoneNumber != after.PhoneNumber {
+		fields = append(fields, "phone_number")
+	}
+	return fields
+}
+
+// DeleteUser soft-deletes the user. It disappears from reads but it and its
+// addresses stay in the database and can be brought back with RestoreUser. A
+// version other than 0 must be the current version of the user.
+func (s *UserService) DeleteUser(ctx context.Context, id string, version int64) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userOps.DeleteUser(ctx, id, version); err != nil {
+			return fmt.Errorf("failed to delete user by ID %s: %w", id, err)
+		}
+		if err := s.recordVersion(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, audit.ActionDelete, id)
+	})
+}
+
+// PurgeUser permanently deletes the user, the foreign key cascades the delete
+// to its addresses. The user's history is erased with it, only the audit log
+// keeps a trace.
+func (s *UserService) PurgeUser(ctx context.Context, id string) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userOps.PurgeUser(ctx, id); err != nil {
+			return fmt.Errorf("failed to purge user by ID %s: %w", id, err)
+		}
+		if s.historyOps != nil {
+			if err := s.historyOps.DeleteVersions(ctx, id); err != nil {
+				return fmt.Errorf("failed to erase history of user %s: %w", id, err)
+			}
+		}
+		return s.audit(ctx, audit.ActionPurge, id)
+	})
+}
+
+func (s *UserService) RestoreUser(ctx context.Context, id string) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userOps.RestoreUser(ctx, id); err != nil {
+			return fmt.Errorf("failed to restore user by ID %s: %w", id, err)
+		}
+		if err := s.recordVersion(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, audit.ActionRestore, id)
+	})
+}
+
+// writtenFields lists the fields of u set by an import, for the audit log.
+func writtenFields(u *entities.User, addresses int) []string {
+	var fields []string
+	for _, f := range []struct {
+		name, value string
+	}{
+		{"name", u.Name},
+		{"email", u.Email},
+		{"phone_number", u.PhoneNumber},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.name)
+		}
+	}
+	if addresses > 0 {
+		fields = append(fields, "addresses")
+	}
+	return fields
+}
+
+// ClearUserAndAddressDataFromDB deletes all users, their addresses go with
+// them through the foreign key cascade. Like a purge of each user, it records
+// a user.purged event per user, and it ends their current history versions,
+// all in one transaction. It is not recorded in the audit log.
+func (s *UserService) ClearUserAndAddressDataFromDB(ctx context.Context) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userOps.ClearAllUsersDataFromDB(ctx); err != nil {
+			return fmt.Errorf("failed to clear users table: %w", err)
+		}
+		if s.historyOps != nil {
+			if err := s.historyOps.CloseVersions(ctx, time.Now()); err != nil {
+				return fmt.Errorf("failed to close history of cleared users: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// CheckOrphanAddresses returns addresses that reference a missing user. When
+// repair is set they are deleted and the number of removed rows is returned.
+func (s *UserService) CheckOrphanAddresses(ctx context.Context, repair bool) ([]entities.Address, int64, error) {
+	orphans, err := s.addressOps.GetOrphanAddresses(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find orphan addresses: %w", err)
+	}
+	if !repair || len(orphans) == 0 {
+		return orphans, 0, nil
+	}
+
+	deleted, err := s.addressOps.DeleteOrphanAddresses(ctx)
+	if err != nil {
+		return orphans, 0, fmt.Errorf("failed to repair orphan addresses: %w", err)
+	}
+	return orphans, deleted, nil
+}
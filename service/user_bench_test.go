@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/load"
+	"sika/pkg/storage/memory"
+)
+
+func BenchmarkUserService_ImportUsers(b *testing.B) {
+	usersData := make([]load.User, 1000)
+	for i := range usersData {
+		usersData[i] = load.User{
+			ID:          fmt.Sprint(i),
+			Name:        "Test User",
+			Email:       "test@example.com",
+			PhoneNumber: "1234567890",
+			Addresses: []load.Address{
+				{
+					Street:  "123 Test St",
+					City:    "Test City",
+					State:   "Test State",
+					ZipCode: "12345",
+					Country: "Test Country",
+				},
+			},
+		}
+	}
+
+	for i := 0; i < b.N; i++ {
+		store := memory.NewStore()
+		service := NewUserService(user.NewOps(memory.NewUserRepo(store)), address.NewOps(memory.NewAddressRepo(store)))
+		if err := service.ImportUsers(context.Background(), usersData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -107,7 +109,7 @@ func TestUserService_ImportUsers(t *testing.T) {
 			tt.setupMocks()
 
 			// Execute
-			err := service.ImportUsers(tt.usersData)
+			err := service.ImportUsers(context.Background(), tt.usersData)
 
 			// Assert
 			if tt.wantErr {
@@ -229,7 +231,7 @@ func TestWorkerPool_ConcurrentProcessing(t *testing.T) {
 	// Execute with timeout
 	done := make(chan error)
 	go func() {
-		done <- service.ImportUsers(usersData)
+		done <- service.ImportUsers(context.Background(), usersData)
 	}()
 
 	select {
@@ -239,3 +241,105 @@ func TestWorkerPool_ConcurrentProcessing(t *testing.T) {
 		t.Fatal("Import operation timed out")
 	}
 }
+
+func TestUserService_ImportUsersCanceled(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	service := NewUserService(user.NewOps(mockUserRepo), address.NewOps(mocks.NewMockAddressRepo(ctrl)))
+
+	usersData := make([]load.User, 100)
+	for i := range usersData {
+		usersData[i] = load.User{ID: fmt.Sprint(i + 1), Name: "Test User"}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Setup mocks: the first user cancels the import, only the users already
+	// taken by the other workers follow
+	var imported atomic.Int32
+	mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, *entities.User) error {
+			imported.Add(1)
+			cancel()
+			return nil
+		}).AnyTimes()
+
+	// Execute
+	err := service.ImportUsers(ctx, usersData)
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.LessOrEqual(t, int(imported.Load()), 10, "no user is started after the cancel")
+}
+
+func TestUserService_CheckOrphanAddresses(t *testing.T) {
+	// Setup
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepo(ctrl)
+	mockAddressRepo := mocks.NewMockAddressRepo(ctrl)
+
+	userOps := user.NewOps(mockUserRepo)
+	addressOps := address.NewOps(mockAddressRepo)
+
+	service := NewUserService(userOps, addressOps)
+
+	orphans := []entities.Address{{ID: 7, UserID: "gone"}}
+
+	tests := []struct {
+		name        string
+		repair      bool
+		setupMocks  func()
+		wantDeleted int64
+		wantErr     bool
+	}{
+		{
+			name:   "report only",
+			repair: false,
+			setupMocks: func() {
+				mockAddressRepo.EXPECT().GetOrphanAddresses(gomock.Any()).Return(orphans, nil)
+			},
+			wantDeleted: 0,
+		},
+		{
+			name:   "repair",
+			repair: true,
+			setupMocks: func() {
+				mockAddressRepo.EXPECT().GetOrphanAddresses(gomock.Any()).Return(orphans, nil)
+				mockAddressRepo.EXPECT().DeleteOrphanAddresses(gomock.Any()).Return(int64(1), nil)
+			},
+			wantDeleted: 1,
+		},
+		{
+			name:   "repair fails",
+			repair: true,
+			setupMocks: func() {
+				mockAddressRepo.EXPECT().GetOrphanAddresses(gomock.Any()).Return(orphans, nil)
+				mockAddressRepo.EXPECT().DeleteOrphanAddresses(gomock.Any()).Return(int64(0), assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mocks
+			tt.setupMocks()
+
+			// Execute
+			got, deleted, err := service.CheckOrphanAddresses(context.Background(), tt.repair)
+
+			// Assert
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, orphans, got)
+				assert.Equal(t, tt.wantDeleted, deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/audit"
+	"sika/internal/history"
+	"sika/internal/user"
+	"sika/pkg/storage/entities"
+	"sika/pkg/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_CreateReplacePatchUser(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	service := NewUserService(user.NewOps(memory.NewUserRepo(store)), address.NewOps(memory.NewAddressRepo(store)))
+	service.SetAuditOps(audit.NewOps(memory.NewAuditRepo(store)))
+	service.SetHistoryOps(history.NewOps(memory.NewHistoryRepo(store)))
+	ctx := context.Background()
+
+	// Execute: create, conflicting creates, replace, patch
+	created := &entities.User{ID: "1", Name: "Test User", Email: "test@example.com", Addresses: []entities.Address{{Street: "1 Test St"}}}
+	require.NoError(t, service.CreateUser(ctx, created))
+	assert.ErrorIs(t, service.CreateUser(ctx, &entities.User{ID: "1", Name: "Other", Email: "other@example.com"}), user.ErrIDTaken)
+	assert.ErrorIs(t, service.CreateUser(ctx, &entities.User{ID: "2", Name: "Other", Email: "Test@Example.com"}), user.ErrEmailTaken)
+
+	replaced := &entities.User{ID: "1", Name: "Replaced", Email: "test@example.com", PhoneNumber: "555 0100 123", Version: created.Version}
+	require.NoError(t, service.ReplaceUser(ctx, replaced))
+	_, err := service.PatchUser(ctx, "1", created.Version, []byte(`{"name":"Stale"}`))
+	assert.ErrorIs(t, err, user.ErrVersionMismatch)
+	patched, err := service.PatchUser(ctx, "1", replaced.Version, []byte(`{"email":"new@example.com","phone_number":null}`))
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, int64(1), created.Version)
+	assert.Equal(t, int64(2), replaced.Version)
+	assert.Equal(t, int64(3), patched.Version)
+	assert.Equal(t, "Replaced", patched.Name)
+	assert.Equal(t, "new@example.com", patched.Email)
+	assert.Empty(t, patched.PhoneNumber)
+	assert.Len(t, patched.Addresses, 1)
+
+	stored, err := service.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", stored.Email)
+
+	versions, err := service.GetUserHistory(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+
+	entries, err := service.GetUserAudit(ctx, "1")
+	require.NoError(t, err)
+	var writes [][]string
+	for _, e := range entries {
+		if e.Action != audit.ActionView {
+			writes = append(writes, append([]string{e.Action}, e.ChangedFields...))
+		}
+	}
+	assert.Equal(t, [][]string{
+		{audit.ActionCreate, "name", "email", "addresses"},
+		{audit.ActionUpdate, "name", "phone_number"},
+		{audit.ActionUpdate, "email", "phone_number"},
+	}, writes)
+
+	_, err = service.PatchUser(ctx, "2", 0, []byte(`{"name":"Nobody"}`))
+	assert.ErrorIs(t, err, user.ErrNotFound)
+}
+
+func TestUserService_Addresses(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	service := NewUserService(user.NewOps(memory.NewUserRepo(store)), address.NewOps(memory.NewAddressRepo(store)))
+	service.SetAuditOps(audit.NewOps(memory.NewAuditRepo(store)))
+	service.SetHistoryOps(history.NewOps(memory.NewHistoryRepo(store)))
+	ctx := context.Background()
+	require.NoError(t, service.CreateUser(ctx, &entities.User{ID: "1", Name: "Test User", Email: "test@example.com"}))
+	require.NoError(t, service.CreateUser(ctx, &entities.User{ID: "2", Name: "Other", Email: "other@example.com", Addresses: []entities.Address{{Street: "9 Other St"}}}))
+	otherAddrs, err := service.GetUserAddresses(ctx, "2")
+	require.NoError(t, err)
+	otherID := otherAddrs[0].ID
+
+	// Execute
+	created := &entities.Address{UserID: "1", Street: " 1 Test St "}
+	require.NoError(t, service.CreateAddress(ctx, created, 0))
+	patched, err := service.PatchAddress(ctx, "1", created.ID, 0, []byte(`{"city":"Glendale"}`))
+	require.NoError(t, err)
+	set := []entities.Address{{ID: created.ID, Street: "1 Test St"}, {Street: "2 Test St"}}
+	require.NoError(t, service.ReplaceAddresses(ctx, "1", 0, set))
+	require.NoError(t, service.DeleteAddress(ctx, "1", created.ID, 0))
+
+	// Assert
+	assert.Equal(t, "1 Test St", created.Street)
+	assert.Equal(t, "Glendale", patched.City)
+	addrs, err := service.GetUserAddresses(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+	assert.Equal(t, "2 Test St", addrs[0].Street)
+
+	_, err = service.GetUserAddress(ctx, "1", otherID)
+	assert.ErrorIs(t, err, address.ErrNotFound)
+	assert.ErrorIs(t, service.DeleteAddress(ctx, "1", otherID, 0), address.ErrNotFound)
+	var verr *address.ValidationError
+	assert.ErrorAs(t, service.ReplaceAddresses(ctx, "1", 0, []entities.Address{{ID: otherID, Street: "x"}}), &verr)
+	assert.ErrorAs(t, service.CreateAddress(ctx, &entities.Address{UserID: "1"}, 0), &verr)
+	assert.ErrorIs(t, service.CreateAddress(ctx, &entities.Address{UserID: "3", Street: "x"}, 0), user.ErrNotFound)
+
+	current, err := service.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.DeleteAddress(ctx, "1", addrs[0].ID, current.Version-1), user.ErrVersionMismatch)
+	require.NoError(t, service.DeleteAddress(ctx, "1", addrs[0].ID, current.Version))
+	require.NoError(t, service.CreateAddress(ctx, &entities.Address{UserID: "1", Street: "2 Test St"}, current.Version+1))
+
+	versions, err := service.GetUserHistory(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, versions, 7)
+
+	entries, err := service.GetUserAudit(ctx, "1")
+	require.NoError(t, err)
+	var updates int
+	for _, e := range entries {
+		if e.Action == audit.ActionUpdate {
+			assert.Equal(t, []string{"addresses"}, []string(e.ChangedFields))
+			updates++
+		}
+	}
+	assert.Equal(t, 6, updates)
+}
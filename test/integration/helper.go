@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -8,37 +9,40 @@ import (
 
 	"sika/config"
 	"sika/pkg/storage"
+	"sika/pkg/storage/pgxrepo"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
 type TestDB struct {
 	*gorm.DB
+	Config config.DB
 }
 
-func SetupTestDB(t *testing.T) *TestDB {
+func SetupTestDB(t testing.TB) *TestDB {
 	// Get the project root directory
 	projectRoot, err := os.Getwd()
 	require.NoError(t, err)
 	projectRoot = filepath.Dir(filepath.Dir(projectRoot)) // Go up two levels from test/integration
 
 	// Read test configuration
-	testConfig, err := config.ReadStandard(filepath.Join(projectRoot, "test", "config.yaml"))
+	testConfig, err := config.ReadStandard(getEnvOrDefault("TEST_CONFIG_PATH", filepath.Join(projectRoot, "test", "config.yaml")))
 	require.NoError(t, err)
 
 	// Connect to the database
-	db, err := storage.NewPostgresGormConnection(testConfig.DB)
+	db, err := storage.NewGormConnection(testConfig.DB)
 	require.NoError(t, err)
 
 	// Run migrations
 	err = storage.Migrate(db)
 	require.NoError(t, err)
 
-	return &TestDB{db}
+	return &TestDB{DB: db, Config: testConfig.DB}
 }
 
-func (db *TestDB) Cleanup(t *testing.T) {
+func (db *TestDB) Cleanup(t testing.TB) {
 	// Clear all data from the database
 	err := db.Exec("DELETE FROM addresses").Error
 	require.NoError(t, err)
@@ -54,6 +58,18 @@ func (db *TestDB) Close() error {
 	return sqlDB.Close()
 }
 
+// SetupPgxPool opens a pgx pool on the test database, skipping the test when
+// it is not Postgres.
+func SetupPgxPool(t testing.TB, db *TestDB) *pgxpool.Pool {
+	if db.Config.Driver != "" && db.Config.Driver != config.DriverPostgres {
+		t.Skipf("pgx repository needs the %s driver, test database uses %s", config.DriverPostgres, db.Config.Driver)
+	}
+	pool, err := pgxrepo.NewPool(context.Background(), db.Config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -67,7 +83,7 @@ func WaitForDB(t *testing.T, dbConfig config.DB) {
 	retryInterval := time.Second * 2
 
 	for i := 0; i < maxRetries; i++ {
-		db, err := storage.NewPostgresGormConnection(dbConfig)
+		db, err := storage.NewGormConnection(dbConfig)
 		if err == nil {
 			sqlDB, err := db.DB()
 			if err == nil {
@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"sika/internal/address"
+	"sika/internal/user"
+	"sika/pkg/load"
+	"sika/pkg/storage/pgxrepo"
+	"sika/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPgxRepo_Integration(t *testing.T) {
+	// Setup test database
+	db := SetupTestDB(t)
+	defer db.Close()
+	pool := SetupPgxPool(t, db)
+
+	// Create service on the pgx repositories
+	addressOps := address.NewOps(pgxrepo.NewAddressRepo(pool))
+	userService := service.NewUserService(user.NewOps(pgxrepo.NewUserRepo(pool)), addressOps)
+
+	t.Run("Import and Retrieve User", func(t *testing.T) {
+		// Clean up before test
+		db.Cleanup(t)
+
+		testUser := load.User{
+			ID:          "1",
+			Name:        "Test User",
+			Email:       "test@example.com",
+			PhoneNumber: "1234567890",
+			Addresses: []load.Address{
+				{Street: "123 Test St", City: "Test City", State: "Test State", ZipCode: "12345", Country: "Test Country"},
+				{Street: "456 Test St", City: "Test City", State: "Test State", ZipCode: "12345", Country: "Test Country"},
+			},
+		}
+
+		err := userService.ImportUsers(context.Background(), []load.User{testUser})
+		require.NoError(t, err)
+
+		user, err := userService.GetUserByID(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, testUser.Name, user.Name)
+		assert.Equal(t, testUser.Email, user.Email)
+		require.Len(t, user.Addresses, 2)
+		assert.Equal(t, "123 Test St", user.Addresses[0].Street)
+		assert.Equal(t, testUser.ID, user.Addresses[0].UserID)
+		assert.False(t, user.Addresses[0].CreatedAt.IsZero())
+	})
+
+	t.Run("Delete, Restore and Purge User", func(t *testing.T) {
+		// Clean up before test
+		db.Cleanup(t)
+
+		testUser := load.User{
+			ID:        "1",
+			Name:      "Test User",
+			Addresses: []load.Address{{Street: "123 Test St"}},
+		}
+		err := userService.ImportUsers(context.Background(), []load.User{testUser})
+		require.NoError(t, err)
+
+		err = userService.DeleteUser(context.Background(), testUser.ID, 0)
+		require.NoError(t, err)
+		_, err = userService.GetUserByID(context.Background(), testUser.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		deleted, err := userService.GetUserByIDWithDeleted(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		assert.True(t, deleted.DeletedAt.Valid)
+
+		err = userService.RestoreUser(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		err = userService.RestoreUser(context.Background(), testUser.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		err = userService.PurgeUser(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		addresses, err := addressOps.GetAddressByUserID(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		assert.Empty(t, addresses)
+	})
+}
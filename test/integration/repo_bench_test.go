@@ -0,0 +1,56 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"sika/internal/user"
+	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
+	"sika/pkg/storage/pgxrepo"
+
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkGetUserByID compares the hot read path of the GORM and pgx repos,
+// run with: go test ./test/integration -run '^$' -bench GetUserByID
+func BenchmarkGetUserByID(b *testing.B) {
+	db := SetupTestDB(b)
+	defer db.Close()
+	db.Cleanup(b)
+	defer db.Cleanup(b)
+
+	const users = 100
+	seed := storage.NewUserRepo(db.DB)
+	for i := 0; i < users; i++ {
+		err := seed.CreateUser(context.Background(), &entities.User{
+			ID:   fmt.Sprintf("bench-%d", i),
+			Name: "Bench User",
+			Addresses: []entities.Address{
+				{Street: "1 Bench St", City: "Bench City"},
+				{Street: "2 Bench St", City: "Bench City"},
+				{Street: "3 Bench St", City: "Bench City"},
+			},
+		})
+		require.NoError(b, err)
+	}
+
+	run := func(b *testing.B, repo user.Repo) {
+		ctx := context.Background()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetUserByID(ctx, fmt.Sprintf("bench-%d", i%users)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("gorm", func(b *testing.B) {
+		run(b, storage.NewUserRepo(db.DB))
+	})
+	b.Run("pgx", func(b *testing.B) {
+		run(b, pgxrepo.NewUserRepo(SetupPgxPool(b, db)))
+	})
+}
@@ -2,12 +2,20 @@ package integration
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
 	"testing"
+	"time"
 
 	"sika/internal/address"
+	"sika/internal/history"
+	"sika/internal/outbox"
 	"sika/internal/user"
 	"sika/pkg/load"
 	"sika/pkg/storage"
+	"sika/pkg/storage/entities"
 	"sika/service"
 
 	"github.com/stretchr/testify/assert"
@@ -52,7 +60,7 @@ func TestUserService_Integration(t *testing.T) {
 		}
 
 		// Import user
-		err := userService.ImportUsers([]load.User{testUser})
+		err := userService.ImportUsers(context.Background(), []load.User{testUser})
 		require.NoError(t, err)
 
 		// Retrieve user
@@ -75,7 +83,7 @@ func TestUserService_Integration(t *testing.T) {
 			users[i] = load.User{
 				ID:          string(rune(i + 1)),
 				Name:        "Test User",
-				Email:       "test@example.com",
+				Email:       fmt.Sprintf("test%d@example.com", i),
 				PhoneNumber: "1234567890",
 				Addresses: []load.Address{
 					{
@@ -90,7 +98,7 @@ func TestUserService_Integration(t *testing.T) {
 		}
 
 		// Import users
-		err := userService.ImportUsers(users)
+		err := userService.ImportUsers(context.Background(), users)
 		require.NoError(t, err)
 
 		// Verify all users were imported
@@ -102,6 +110,45 @@ func TestUserService_Integration(t *testing.T) {
 		}
 	})
 
+	t.Run("Delete, Restore and Purge User", func(t *testing.T) {
+		// Clean up before test
+		db.Cleanup(t)
+
+		// Test data
+		testUser := load.User{
+			ID:   "1",
+			Name: "Test User",
+			Addresses: []load.Address{
+				{Street: "123 Test St"},
+				{Street: "456 Test St"},
+			},
+		}
+
+		// Import user
+		err := userService.ImportUsers(context.Background(), []load.User{testUser})
+		require.NoError(t, err)
+
+		// Soft delete keeps the user restorable
+		err = userService.DeleteUser(context.Background(), testUser.ID, 0)
+		require.NoError(t, err)
+		_, err = userService.GetUserByID(context.Background(), testUser.ID)
+		assert.Error(t, err)
+		err = userService.RestoreUser(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		user, err := userService.GetUserByID(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		assert.Len(t, user.Addresses, 2)
+
+		// Purge user
+		err = userService.PurgeUser(context.Background(), testUser.ID)
+		require.NoError(t, err)
+
+		// Addresses were removed by the foreign key cascade
+		addresses, err := addressOps.GetAddressByUserID(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		assert.Empty(t, addresses)
+	})
+
 	t.Run("Get Non-existent User", func(t *testing.T) {
 		// Clean up before test
 		db.Cleanup(t)
@@ -138,7 +185,7 @@ func TestUserService_ConcurrentImport(t *testing.T) {
 		users[i] = load.User{
 			ID:          string(rune(i + 1)),
 			Name:        "Test User",
-			Email:       "test@example.com",
+			Email:       fmt.Sprintf("test%d@example.com", i),
 			PhoneNumber: "1234567890",
 			Addresses: []load.Address{
 				{
@@ -153,7 +200,7 @@ func TestUserService_ConcurrentImport(t *testing.T) {
 	}
 
 	// Import users
-	err := userService.ImportUsers(users)
+	err := userService.ImportUsers(context.Background(), users)
 	require.NoError(t, err)
 
 	// Verify all users were imported
@@ -164,3 +211,127 @@ func TestUserService_ConcurrentImport(t *testing.T) {
 		assert.Equal(t, testUser.ID, user.ID)
 	}
 }
+
+// slowHistoryRepo delays reading the current version, so that concurrent
+// writes interleave between writing the user and appending its version.
+type slowHistoryRepo struct {
+	history.Repo
+}
+
+func (r slowHistoryRepo) GetCurrentVersion(ctx context.Context, userID string) (*entities.UserVersion, error) {
+	time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+	return r.Repo.GetCurrentVersion(ctx, userID)
+}
+
+func TestUserService_ConcurrentWritesHistory(t *testing.T) {
+	// Setup test database
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	// Create service with history, writing in one transaction per change
+	userService := service.NewUserService(user.NewOps(storage.NewUserRepo(db.DB)), address.NewOps(storage.NewAddressRepo(db.DB)))
+	userService.SetHistoryOps(history.NewOps(slowHistoryRepo{storage.NewHistoryRepo(db.DB)}))
+	userService.SetTransactor(storage.NewTransactor(db.DB))
+
+	// Clean up before test
+	db.Cleanup(t)
+	const id = "history-race"
+	require.NoError(t, db.Where("user_id=?", id).Delete(&entities.UserVersion{}).Error)
+	require.NoError(t, db.Where("user_id=?", id).Delete(&entities.OutboxEvent{}).Error)
+	ctx := context.Background()
+	require.NoError(t, userService.CreateUser(ctx, &entities.User{ID: id, Name: "Name", Email: "race@example.com"}))
+
+	// Re-import the user concurrently, each write with another name. Imports
+	// overwrite the user whatever its version.
+	const writes = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writes)
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- userService.ImportUsers(ctx, []load.User{{ID: id, Name: fmt.Sprintf("Name %d", i), Email: "race@example.com"}})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// Every write has a version, in the order the outbox recorded the writes
+	versions, err := userService.GetUserHistory(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, versions, writes+1)
+	var events []entities.OutboxEvent
+	require.NoError(t, db.Where("user_id=? AND event_type=?", id, outbox.EventUserUpdated).Order("sequence").Find(&events).Error)
+	require.Len(t, events, writes)
+	for i, event := range events {
+		var written entities.User
+		require.NoError(t, json.Unmarshal([]byte(event.Payload), &written))
+		v := versions[i+1]
+		assert.Equal(t, written.Name, v.Name, "version %d", v.Version)
+		assert.False(t, v.ValidFrom.Before(versions[i].ValidFrom), "version %d starts before version %d", v.Version, versions[i].Version)
+		require.NotNil(t, versions[i].ValidTo)
+		assert.True(t, versions[i].ValidTo.Equal(v.ValidFrom))
+	}
+
+	stored, err := userService.GetUserByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Name, versions[writes].Name)
+	assert.Nil(t, versions[writes].ValidTo)
+}
+
+// slowEmailUserRepo delays the answer of the email check, so that
+// concurrent writes all pass it before the first is written.
+type slowEmailUserRepo struct {
+	user.Repo
+}
+
+func (r slowEmailUserRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	u, err := r.Repo.GetUserByEmail(ctx, email)
+	time.Sleep(10 * time.Millisecond)
+	return u, err
+}
+
+func TestUserService_ConcurrentCreatesSameEmail(t *testing.T) {
+	// Setup test database
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	userService := service.NewUserService(user.NewOps(slowEmailUserRepo{storage.NewUserRepo(db.DB)}), address.NewOps(storage.NewAddressRepo(db.DB)))
+	userService.SetTransactor(storage.NewTransactor(db.DB))
+
+	// Clean up before test
+	db.Cleanup(t)
+	ctx := context.Background()
+
+	// Create users with the same email concurrently, all passing the check
+	// before the first is written
+	const writes = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writes)
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- userService.CreateUser(ctx, &entities.User{ID: fmt.Sprintf("email-race-%d", i), Name: "Name", Email: "Race@example.com"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	// Exactly one is created, the others are rejected as an email taken
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, user.ErrEmailTaken)
+	}
+	assert.Equal(t, 1, created)
+	var stored int64
+	require.NoError(t, db.Model(&entities.User{}).Where("lower(email) = ?", "race@example.com").Count(&stored).Error)
+	assert.Equal(t, int64(1), stored)
+}
@@ -77,6 +77,50 @@ func (mr *MockAddressRepoMockRecorder) CreateBatchAddresses(ctx, adds interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatchAddresses", reflect.TypeOf((*MockAddressRepo)(nil).CreateBatchAddresses), ctx, adds)
 }
 
+// DeleteAddress mocks base method.
+func (m *MockAddressRepo) DeleteAddress(ctx context.Context, userID string, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAddress", ctx, userID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAddress indicates an expected call of DeleteAddress.
+func (mr *MockAddressRepoMockRecorder) DeleteAddress(ctx, userID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAddress", reflect.TypeOf((*MockAddressRepo)(nil).DeleteAddress), ctx, userID, id)
+}
+
+// DeleteOrphanAddresses mocks base method.
+func (m *MockAddressRepo) DeleteOrphanAddresses(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrphanAddresses", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteOrphanAddresses indicates an expected call of DeleteOrphanAddresses.
+func (mr *MockAddressRepoMockRecorder) DeleteOrphanAddresses(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrphanAddresses", reflect.TypeOf((*MockAddressRepo)(nil).DeleteOrphanAddresses), ctx)
+}
+
+// GetAddress mocks base method.
+func (m *MockAddressRepo) GetAddress(ctx context.Context, userID string, id int) (*entities.Address, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddress", ctx, userID, id)
+	ret0, _ := ret[0].(*entities.Address)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddress indicates an expected call of GetAddress.
+func (mr *MockAddressRepoMockRecorder) GetAddress(ctx, userID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddress", reflect.TypeOf((*MockAddressRepo)(nil).GetAddress), ctx, userID, id)
+}
+
 // GetAddressByUser mocks base method.
 func (m *MockAddressRepo) GetAddressByUser(ctx context.Context, userID string) ([]entities.Address, error) {
 	m.ctrl.T.Helper()
@@ -91,3 +135,46 @@ func (mr *MockAddressRepoMockRecorder) GetAddressByUser(ctx, userID interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressByUser", reflect.TypeOf((*MockAddressRepo)(nil).GetAddressByUser), ctx, userID)
 }
+
+// GetOrphanAddresses mocks base method.
+func (m *MockAddressRepo) GetOrphanAddresses(ctx context.Context) ([]entities.Address, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrphanAddresses", ctx)
+	ret0, _ := ret[0].([]entities.Address)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrphanAddresses indicates an expected call of GetOrphanAddresses.
+func (mr *MockAddressRepoMockRecorder) GetOrphanAddresses(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrphanAddresses", reflect.TypeOf((*MockAddressRepo)(nil).GetOrphanAddresses), ctx)
+}
+
+// ReplaceAddresses mocks base method.
+func (m *MockAddressRepo) ReplaceAddresses(ctx context.Context, userID string, adds []entities.Address) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceAddresses", ctx, userID, adds)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceAddresses indicates an expected call of ReplaceAddresses.
+func (mr *MockAddressRepoMockRecorder) ReplaceAddresses(ctx, userID, adds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceAddresses", reflect.TypeOf((*MockAddressRepo)(nil).ReplaceAddresses), ctx, userID, adds)
+}
+
+// UpdateAddress mocks base method.
+func (m *MockAddressRepo) UpdateAddress(ctx context.Context, a *entities.Address) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAddress", ctx, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAddress indicates an expected call of UpdateAddress.
+func (mr *MockAddressRepoMockRecorder) UpdateAddress(ctx, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAddress", reflect.TypeOf((*MockAddressRepo)(nil).UpdateAddress), ctx, a)
+}
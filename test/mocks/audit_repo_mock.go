@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/audit/type.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	entities "sika/pkg/storage/entities"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRepo is a mock of audit.Repo interface.
+type MockAuditRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditRepoMockRecorder
+}
+
+// MockRepoMockRecorder is the mock recorder for MockRepo.
+type MockAuditRepoMockRecorder struct {
+	mock *MockAuditRepo
+}
+
+// NewMockRepo creates a new mock instance.
+func NewMockAuditRepo(ctrl *gomock.Controller) *MockAuditRepo {
+	mock := &MockAuditRepo{ctrl: ctrl}
+	mock.recorder = &MockAuditRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditRepo) EXPECT() *MockAuditRepoMockRecorder {
+	return m.recorder
+}
+
+// AppendEntries mocks base method.
+func (m *MockAuditRepo) AppendEntries(ctx context.Context, entries []entities.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendEntries", ctx, entries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendEntries indicates an expected call of AppendEntries.
+func (mr *MockAuditRepoMockRecorder) AppendEntries(ctx, entries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendEntries", reflect.TypeOf((*MockAuditRepo)(nil).AppendEntries), ctx, entries)
+}
+
+// AppendEntry mocks base method.
+func (m *MockAuditRepo) AppendEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendEntry", ctx, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendEntry indicates an expected call of AppendEntry.
+func (mr *MockAuditRepoMockRecorder) AppendEntry(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendEntry", reflect.TypeOf((*MockAuditRepo)(nil).AppendEntry), ctx, entry)
+}
+
+// GetEntriesByUser mocks base method.
+func (m *MockAuditRepo) GetEntriesByUser(ctx context.Context, userID string) ([]entities.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEntriesByUser", ctx, userID)
+	ret0, _ := ret[0].([]entities.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEntriesByUser indicates an expected call of GetEntriesByUser.
+func (mr *MockAuditRepoMockRecorder) GetEntriesByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntriesByUser", reflect.TypeOf((*MockAuditRepo)(nil).GetEntriesByUser), ctx, userID)
+}
@@ -0,0 +1,124 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/history/type.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	entities "sika/pkg/storage/entities"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRepo is a mock of history.Repo interface.
+type MockHistoryRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryRepoMockRecorder
+}
+
+// MockRepoMockRecorder is the mock recorder for MockRepo.
+type MockHistoryRepoMockRecorder struct {
+	mock *MockHistoryRepo
+}
+
+// NewMockRepo creates a new mock instance.
+func NewMockHistoryRepo(ctrl *gomock.Controller) *MockHistoryRepo {
+	mock := &MockHistoryRepo{ctrl: ctrl}
+	mock.recorder = &MockHistoryRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryRepo) EXPECT() *MockHistoryRepoMockRecorder {
+	return m.recorder
+}
+
+// AppendVersion mocks base method.
+func (m *MockHistoryRepo) AppendVersion(ctx context.Context, v *entities.UserVersion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendVersion", ctx, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendVersion indicates an expected call of AppendVersion.
+func (mr *MockHistoryRepoMockRecorder) AppendVersion(ctx, v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendVersion", reflect.TypeOf((*MockHistoryRepo)(nil).AppendVersion), ctx, v)
+}
+
+// CloseVersions mocks base method.
+func (m *MockHistoryRepo) CloseVersions(ctx context.Context, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseVersions", ctx, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseVersions indicates an expected call of CloseVersions.
+func (mr *MockHistoryRepoMockRecorder) CloseVersions(ctx, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseVersions", reflect.TypeOf((*MockHistoryRepo)(nil).CloseVersions), ctx, at)
+}
+
+// DeleteVersions mocks base method.
+func (m *MockHistoryRepo) DeleteVersions(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVersions", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteVersions indicates an expected call of DeleteVersions.
+func (mr *MockHistoryRepoMockRecorder) DeleteVersions(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVersions", reflect.TypeOf((*MockHistoryRepo)(nil).DeleteVersions), ctx, userID)
+}
+
+// GetCurrentVersion mocks base method.
+func (m *MockHistoryRepo) GetCurrentVersion(ctx context.Context, userID string) (*entities.UserVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCurrentVersion", ctx, userID)
+	ret0, _ := ret[0].(*entities.UserVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCurrentVersion indicates an expected call of GetCurrentVersion.
+func (mr *MockHistoryRepoMockRecorder) GetCurrentVersion(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrentVersion", reflect.TypeOf((*MockHistoryRepo)(nil).GetCurrentVersion), ctx, userID)
+}
+
+// GetVersionAt mocks base method.
+func (m *MockHistoryRepo) GetVersionAt(ctx context.Context, userID string, at time.Time) (*entities.UserVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersionAt", ctx, userID, at)
+	ret0, _ := ret[0].(*entities.UserVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersionAt indicates an expected call of GetVersionAt.
+func (mr *MockHistoryRepoMockRecorder) GetVersionAt(ctx, userID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersionAt", reflect.TypeOf((*MockHistoryRepo)(nil).GetVersionAt), ctx, userID, at)
+}
+
+// GetVersions mocks base method.
+func (m *MockHistoryRepo) GetVersions(ctx context.Context, userID string) ([]entities.UserVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersions", ctx, userID)
+	ret0, _ := ret[0].([]entities.UserVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersions indicates an expected call of GetVersions.
+func (mr *MockHistoryRepoMockRecorder) GetVersions(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersions", reflect.TypeOf((*MockHistoryRepo)(nil).GetVersions), ctx, userID)
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	user "sika/internal/user"
 	entities "sika/pkg/storage/entities"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,17 +37,17 @@ func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
 }
 
 // ClearAllUsersDataFromDB mocks base method.
-func (m *MockUserRepo) ClearAllUsersDataFromDB() error {
+func (m *MockUserRepo) ClearAllUsersDataFromDB(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ClearAllUsersDataFromDB")
+	ret := m.ctrl.Call(m, "ClearAllUsersDataFromDB", ctx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ClearAllUsersDataFromDB indicates an expected call of ClearAllUsersDataFromDB.
-func (mr *MockUserRepoMockRecorder) ClearAllUsersDataFromDB() *gomock.Call {
+func (mr *MockUserRepoMockRecorder) ClearAllUsersDataFromDB(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearAllUsersDataFromDB", reflect.TypeOf((*MockUserRepo)(nil).ClearAllUsersDataFromDB))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearAllUsersDataFromDB", reflect.TypeOf((*MockUserRepo)(nil).ClearAllUsersDataFromDB), ctx)
 }
 
 // CreateBatchUsers mocks base method.
@@ -77,6 +78,50 @@ func (mr *MockUserRepoMockRecorder) CreateUser(ctx, user interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserRepo)(nil).CreateUser), ctx, user)
 }
 
+// DeleteUser mocks base method.
+func (m *MockUserRepo) DeleteUser(ctx context.Context, id string, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserRepoMockRecorder) DeleteUser(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserRepo)(nil).DeleteUser), ctx, id, version)
+}
+
+// GetAllUserIDs mocks base method.
+func (m *MockUserRepo) GetAllUserIDs(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllUserIDs", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllUserIDs indicates an expected call of GetAllUserIDs.
+func (mr *MockUserRepoMockRecorder) GetAllUserIDs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUserIDs", reflect.TypeOf((*MockUserRepo)(nil).GetAllUserIDs), ctx)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockUserRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockUserRepoMockRecorder) GetUserByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockUserRepo)(nil).GetUserByEmail), ctx, email)
+}
+
 // GetUserByID mocks base method.
 func (m *MockUserRepo) GetUserByID(ctx context.Context, id string) (*entities.User, error) {
 	m.ctrl.T.Helper()
@@ -91,3 +136,120 @@ func (mr *MockUserRepoMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserRepo)(nil).GetUserByID), ctx, id)
 }
+
+// GetUserByIDWithDeleted mocks base method.
+func (m *MockUserRepo) GetUserByIDWithDeleted(ctx context.Context, id string) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByIDWithDeleted", ctx, id)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByIDWithDeleted indicates an expected call of GetUserByIDWithDeleted.
+func (mr *MockUserRepoMockRecorder) GetUserByIDWithDeleted(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByIDWithDeleted", reflect.TypeOf((*MockUserRepo)(nil).GetUserByIDWithDeleted), ctx, id)
+}
+
+// GetUserProjection mocks base method.
+func (m *MockUserRepo) GetUserProjection(ctx context.Context, id string, p user.Projection, includeDeleted bool) (*entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserProjection", ctx, id, p, includeDeleted)
+	ret0, _ := ret[0].(*entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserProjection indicates an expected call of GetUserProjection.
+func (mr *MockUserRepoMockRecorder) GetUserProjection(ctx, id, p, includeDeleted interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserProjection", reflect.TypeOf((*MockUserRepo)(nil).GetUserProjection), ctx, id, p, includeDeleted)
+}
+
+// GetUsersByIDs mocks base method.
+func (m *MockUserRepo) GetUsersByIDs(ctx context.Context, ids []string) ([]entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, ids)
+	ret0, _ := ret[0].([]entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIDs indicates an expected call of GetUsersByIDs.
+func (mr *MockUserRepoMockRecorder) GetUsersByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserRepo)(nil).GetUsersByIDs), ctx, ids)
+}
+
+// ListUsers mocks base method.
+func (m *MockUserRepo) ListUsers(ctx context.Context, q user.ListQuery) ([]entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, q)
+	ret0, _ := ret[0].([]entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockUserRepoMockRecorder) ListUsers(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockUserRepo)(nil).ListUsers), ctx, q)
+}
+
+// PurgeUser mocks base method.
+func (m *MockUserRepo) PurgeUser(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeUser indicates an expected call of PurgeUser.
+func (mr *MockUserRepoMockRecorder) PurgeUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeUser", reflect.TypeOf((*MockUserRepo)(nil).PurgeUser), ctx, id)
+}
+
+// RestoreUser mocks base method.
+func (m *MockUserRepo) RestoreUser(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockUserRepoMockRecorder) RestoreUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockUserRepo)(nil).RestoreUser), ctx, id)
+}
+
+// SearchUsers mocks base method.
+func (m *MockUserRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]entities.UserMatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, query, limit, offset)
+	ret0, _ := ret[0].([]entities.UserMatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockUserRepoMockRecorder) SearchUsers(ctx, query, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockUserRepo)(nil).SearchUsers), ctx, query, limit, offset)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUserRepo) UpdateUser(ctx context.Context, u *entities.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, u)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserRepoMockRecorder) UpdateUser(ctx, u interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserRepo)(nil).UpdateUser), ctx, u)
+}